@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FlushableCache 是支持把未持久化的数据写入后端存储的缓存
+// 持久化缓存应实现此接口，CachedRepository关闭时会调用Flush以保证数据落盘
+type FlushableCache interface {
+	Cache
+
+	// Flush 把当前缓存中的数据写入后端存储
+	Flush() error
+}
+
+// fileCacheEntry 是缓存文件中的一项
+type fileCacheEntry struct {
+	Value      json.RawMessage `json:"value"`
+	Expiration time.Time       `json:"expiration,omitempty"`
+}
+
+// FileCache 是持久化到单个JSON文件的缓存
+// 运行时数据保存在内存中，调用Flush或Close时整体写入文件，创建时从文件中加载未过期的数据。
+// 值在写入时使用JSON序列化，从文件加载的值以json.RawMessage的形式返回，
+// 由使用者反序列化为需要的类型（CachedRepository会自动处理）
+type FileCache struct {
+	*MemoryCache
+	path string
+}
+
+// NewFileCache 创建一个持久化到指定文件的缓存
+// 参数:
+//   - path: 缓存文件路径，文件不存在时会在第一次Flush时创建
+//   - defaultExpiration: 默认的缓存项过期时间
+//   - cleanupInterval: 自动清理过期项目的时间间隔，为0时不自动清理
+func NewFileCache(path string, defaultExpiration, cleanupInterval time.Duration) (*FileCache, error) {
+	c := &FileCache{
+		MemoryCache: NewMemoryCache(defaultExpiration, cleanupInterval),
+		path:        path,
+	}
+	if err := c.load(); err != nil {
+		c.MemoryCache.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Flush 把未过期的缓存项写入文件
+// 先写入临时文件再重命名，避免中途失败时损坏已有的缓存文件
+func (c *FileCache) Flush() error {
+	now := time.Now()
+
+	c.mu.RLock()
+	entries := make(map[string]fileCacheEntry, len(c.items))
+	for key, item := range c.items {
+		if !item.expiration.IsZero() && item.expiration.Before(now) {
+			continue
+		}
+		value, err := json.Marshal(item.value)
+		if err != nil {
+			c.mu.RUnlock()
+			return err
+		}
+		entries[key] = fileCacheEntry{Value: value, Expiration: item.expiration}
+	}
+	c.mu.RUnlock()
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// Close 把数据写入文件并停止自动清理
+// Cache接口的Close不返回错误，需要知道写入是否成功时请先调用Flush
+func (c *FileCache) Close() {
+	_ = c.Flush()
+	c.MemoryCache.Close()
+}
+
+// load 从文件中加载未过期的缓存项
+func (c *FileCache) load() error {
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	entries := make(map[string]fileCacheEntry)
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, entry := range entries {
+		if !entry.Expiration.IsZero() && entry.Expiration.Before(now) {
+			continue
+		}
+		c.items[key] = cacheItem{
+			value:      entry.Value,
+			expiration: entry.Expiration,
+			created:    now,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+
+	c, err := NewFileCache(path, time.Minute, 0)
+	if err != nil {
+		t.Fatalf("创建文件缓存失败: %v", err)
+	}
+	c.Set("name", "rails")
+	c.SetWithExpiration("expired", "old", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	if err := c.Flush(); err != nil {
+		t.Fatalf("写入文件失败: %v", err)
+	}
+	c.Close()
+
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("缓存文件应该存在: %v", err)
+	}
+
+	// 重新打开，数据应该从文件中加载
+	reopened, err := NewFileCache(path, time.Minute, 0)
+	if err != nil {
+		t.Fatalf("重新打开文件缓存失败: %v", err)
+	}
+	defer reopened.Close()
+
+	value, found := reopened.Get("name")
+	if !found {
+		t.Fatal("Expected name to be loaded from file")
+	}
+	var name string
+	if err := json.Unmarshal(value.(json.RawMessage), &name); err != nil || name != "rails" {
+		t.Errorf("Expected name=rails, got %s, err=%v", value, err)
+	}
+	if _, found := reopened.Get("expired"); found {
+		t.Error("Expected expired item to not be persisted")
+	}
+}
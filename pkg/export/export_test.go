@@ -0,0 +1,48 @@
+package export
+
+import (
+	"time"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+)
+
+// testPackages 包含字段齐全的包、大部分字段缺失的包和nil
+func testPackages() []*models.PackageInformation {
+	return []*models.PackageInformation{
+		{
+			Name:             "rails",
+			Version:          "7.0.5",
+			Platform:         "ruby",
+			Authors:          "David Heinemeier Hansson",
+			Info:             "Ruby on Rails is a full-stack web framework.\n\t\"Convention over configuration\" \\ \u0001",
+			Licenses:         []string{"MIT"},
+			Downloads:        436090160,
+			VersionDownloads: 54428,
+			VersionCreatedAt: time.Date(2023, 5, 24, 19, 21, 28, 229000000, time.UTC),
+			Sha:              "57ef2baa4a1f5f954bc6e5a019b1fac8486ece36f79c1cf366e6de33210637fe",
+			ProjectURI:       "https://rubygems.org/gems/rails",
+			GemURI:           "https://rubygems.org/gems/rails-7.0.5.gem",
+			HomepageURI:      "https://rubyonrails.org",
+			SourceCodeURI:    "https://github.com/rails/rails/tree/v7.0.5",
+			Dependencies: models.Dependencies{
+				Runtime: []*models.Dependency{
+					{Name: "actionpack", Requirements: "= 7.0.5"},
+					{Name: "bundler", Requirements: ">= 1.15.0, < 3"},
+					nil,
+				},
+			},
+		},
+		nil,
+		{Name: "tiny", Yanked: true},
+	}
+}
+
+// expectedPackages 是testPackages读回后应该得到的结果
+func expectedPackages() []*models.PackageInformation {
+	packages := testPackages()
+	rails := packages[0]
+	rails.Dependencies.Runtime = rails.Dependencies.Runtime[:2]
+	tiny := packages[2]
+	tiny.Licenses = []string{}
+	return []*models.PackageInformation{rails, tiny}
+}
@@ -0,0 +1,207 @@
+// Package export 把包信息导出为YAML、TOML等格式的文件，适合生成配置风格的清单
+// 所有格式共用同一套字段提取逻辑，保证不同格式导出的字段和取值一致
+package export
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+)
+
+// Field 是导出的一个字段，Value的类型为string、int64、bool或[]string之一
+type Field struct {
+	Name  string
+	Value interface{}
+}
+
+// 导出的字段名，也是各个格式中使用的键
+const (
+	FieldName                = "name"
+	FieldVersion             = "version"
+	FieldPlatform            = "platform"
+	FieldAuthors             = "authors"
+	FieldInfo                = "info"
+	FieldLicenses            = "licenses"
+	FieldDownloads           = "downloads"
+	FieldVersionDownloads    = "version_downloads"
+	FieldVersionCreatedAt    = "version_created_at"
+	FieldYanked              = "yanked"
+	FieldSha                 = "sha"
+	FieldProjectURI          = "project_uri"
+	FieldGemURI              = "gem_uri"
+	FieldHomepageURI         = "homepage_uri"
+	FieldSourceCodeURI       = "source_code_uri"
+	FieldDocumentationURI    = "documentation_uri"
+	FieldBugTrackerURI       = "bug_tracker_uri"
+	FieldChangelogURI        = "changelog_uri"
+	FieldRuntimeDependencies = "runtime_dependencies"
+)
+
+// PackageFields 按固定的顺序提取包信息中要导出的字段
+// 缺失的字段使用零值：字符串为""，列表为空列表，没有发布时间时version_created_at为""；
+// 运行时依赖写成 "name (requirements)" 的形式，例如 "rack (>= 2.2.4)"
+func PackageFields(pkg *models.PackageInformation) []Field {
+	createdAt := ""
+	if !pkg.VersionCreatedAt.IsZero() {
+		createdAt = pkg.VersionCreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	licenses := make([]string, 0, len(pkg.Licenses))
+	licenses = append(licenses, pkg.Licenses...)
+
+	dependencies := make([]string, 0, len(pkg.Dependencies.Runtime))
+	for _, dependency := range pkg.Dependencies.Runtime {
+		if dependency != nil {
+			dependencies = append(dependencies, formatDependency(dependency))
+		}
+	}
+
+	return []Field{
+		{FieldName, pkg.Name},
+		{FieldVersion, pkg.Version},
+		{FieldPlatform, pkg.Platform},
+		{FieldAuthors, pkg.Authors},
+		{FieldInfo, pkg.Info},
+		{FieldLicenses, licenses},
+		{FieldDownloads, int64(pkg.Downloads)},
+		{FieldVersionDownloads, int64(pkg.VersionDownloads)},
+		{FieldVersionCreatedAt, createdAt},
+		{FieldYanked, pkg.Yanked},
+		{FieldSha, pkg.Sha},
+		{FieldProjectURI, pkg.ProjectURI},
+		{FieldGemURI, pkg.GemURI},
+		{FieldHomepageURI, pkg.HomepageURI},
+		{FieldSourceCodeURI, pkg.SourceCodeURI},
+		{FieldDocumentationURI, pkg.DocumentationURI},
+		{FieldBugTrackerURI, pkg.BugTrackerURI},
+		{FieldChangelogURI, pkg.ChangelogURI},
+		{FieldRuntimeDependencies, dependencies},
+	}
+}
+
+// packageFromFields 是PackageFields的逆过程，用于读取导出的文件
+// 未知的字段被忽略，缺失的字段保持零值，字段类型与导出时不一致时返回错误
+func packageFromFields(values map[string]interface{}) (*models.PackageInformation, error) {
+	pkg := &models.PackageInformation{}
+	strs := map[string]*string{
+		FieldName:             &pkg.Name,
+		FieldVersion:          &pkg.Version,
+		FieldPlatform:         &pkg.Platform,
+		FieldAuthors:          &pkg.Authors,
+		FieldInfo:             &pkg.Info,
+		FieldSha:              &pkg.Sha,
+		FieldProjectURI:       &pkg.ProjectURI,
+		FieldGemURI:           &pkg.GemURI,
+		FieldHomepageURI:      &pkg.HomepageURI,
+		FieldSourceCodeURI:    &pkg.SourceCodeURI,
+		FieldDocumentationURI: &pkg.DocumentationURI,
+		FieldBugTrackerURI:    &pkg.BugTrackerURI,
+		FieldChangelogURI:     &pkg.ChangelogURI,
+	}
+
+	for name, value := range values {
+		var err error
+		switch name {
+		case FieldLicenses:
+			pkg.Licenses, err = stringsValue(name, value)
+		case FieldRuntimeDependencies:
+			var dependencies []string
+			if dependencies, err = stringsValue(name, value); err == nil {
+				for _, dependency := range dependencies {
+					pkg.Dependencies.Runtime = append(pkg.Dependencies.Runtime, parseDependency(dependency))
+				}
+			}
+		case FieldDownloads:
+			var downloads int64
+			downloads, err = intValue(name, value)
+			pkg.Downloads = models.Count(downloads)
+		case FieldVersionDownloads:
+			var downloads int64
+			downloads, err = intValue(name, value)
+			pkg.VersionDownloads = int(downloads)
+		case FieldYanked:
+			yanked, ok := value.(bool)
+			if !ok {
+				err = fmt.Errorf("field %s: expected bool, got %T", name, value)
+			}
+			pkg.Yanked = yanked
+		case FieldVersionCreatedAt:
+			var createdAt string
+			if createdAt, err = stringValue(name, value); err == nil && createdAt != "" {
+				pkg.VersionCreatedAt, err = time.Parse(time.RFC3339Nano, createdAt)
+			}
+		default:
+			if target, ok := strs[name]; ok {
+				*target, err = stringValue(name, value)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pkg, nil
+}
+
+func formatDependency(dependency *models.Dependency) string {
+	if dependency.Requirements == "" {
+		return dependency.Name
+	}
+	return fmt.Sprintf("%s (%s)", dependency.Name, dependency.Requirements)
+}
+
+func parseDependency(s string) *models.Dependency {
+	name, requirements, found := strings.Cut(s, " (")
+	if !found {
+		return &models.Dependency{Name: s}
+	}
+	return &models.Dependency{Name: name, Requirements: strings.TrimSuffix(requirements, ")")}
+}
+
+func stringValue(name string, value interface{}) (string, error) {
+	if value == nil {
+		return "", nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("field %s: expected string, got %T", name, value)
+	}
+	return s, nil
+}
+
+func intValue(name string, value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint64:
+		return int64(v), nil
+	default:
+		return 0, fmt.Errorf("field %s: expected integer, got %T", name, value)
+	}
+}
+
+func stringsValue(name string, value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("field %s: expected list of strings, got %T element", name, item)
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("field %s: expected list of strings, got %T", name, value)
+	}
+}
@@ -0,0 +1,243 @@
+package export
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+)
+
+// tomlTableName 是WritePackagesTOML中每个包所在的表数组的名称
+const tomlTableName = "packages"
+
+// WritePackagesTOML 把包信息写成TOML的表数组，每个包是一个 [[packages]] 表，键按PackageFields的顺序排列
+// TOML没有null，缺失的字段写成零值；packages中的nil会被跳过
+func WritePackagesTOML(w io.Writer, packages []*models.PackageInformation) error {
+	bw := bufio.NewWriter(w)
+	first := true
+	for _, pkg := range packages {
+		if pkg == nil {
+			continue
+		}
+		if !first {
+			bw.WriteString("\n")
+		}
+		first = false
+
+		fmt.Fprintf(bw, "[[%s]]\n", tomlTableName)
+		for _, field := range PackageFields(pkg) {
+			value, err := tomlValue(field.Value)
+			if err != nil {
+				return fmt.Errorf("encode field %s of %s: %w", field.Name, pkg.Name, err)
+			}
+			fmt.Fprintf(bw, "%s = %s\n", field.Name, value)
+		}
+	}
+	return bw.Flush()
+}
+
+func tomlValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return tomlQuote(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case []string:
+		quoted := make([]string, len(v))
+		for i, s := range v {
+			quoted[i] = tomlQuote(s)
+		}
+		return "[" + strings.Join(quoted, ", ") + "]", nil
+	default:
+		return "", fmt.Errorf("unsupported value type %T", value)
+	}
+}
+
+// tomlQuote 把字符串写成TOML的基本字符串，控制字符使用转义
+// strconv.Quote产生的\x和\a等转义在TOML中不合法，所以不能直接使用
+func tomlQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// ReadPackagesTOML 读取WritePackagesTOML写出的文件
+// 只支持WritePackagesTOML用到的TOML子集：[[packages]] 表数组，以及值为基本字符串、整数、布尔值或单行字符串数组的键值对
+func ReadPackagesTOML(r io.Reader) ([]*models.PackageInformation, error) {
+	var packages []*models.PackageInformation
+	var record map[string]interface{}
+	flush := func() error {
+		if record == nil {
+			return nil
+		}
+		pkg, err := packageFromFields(record)
+		if err != nil {
+			return fmt.Errorf("package %d: %w", len(packages), err)
+		}
+		packages = append(packages, pkg)
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line == "[["+tomlTableName+"]]" {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			record = make(map[string]interface{})
+			continue
+		}
+
+		key, rawValue, found := strings.Cut(line, "=")
+		if !found || record == nil {
+			return nil, fmt.Errorf("line %d: unexpected %q", lineNumber, line)
+		}
+		value, rest, err := parseTOMLValue(strings.TrimSpace(rawValue))
+		if err == nil && rest != "" && !strings.HasPrefix(rest, "#") {
+			err = fmt.Errorf("unexpected %q after value", rest)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		record[strings.TrimSpace(key)] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return packages, nil
+}
+
+// parseTOMLValue 解析s开头的一个值，返回值和去掉前导空白的剩余部分
+func parseTOMLValue(s string) (interface{}, string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		return parseTOMLString(s)
+	case strings.HasPrefix(s, "["):
+		values := make([]string, 0)
+		s = strings.TrimSpace(s[1:])
+		for !strings.HasPrefix(s, "]") {
+			value, rest, err := parseTOMLString(s)
+			if err != nil {
+				return nil, "", err
+			}
+			values = append(values, value)
+			if strings.HasPrefix(rest, ",") {
+				rest = strings.TrimSpace(rest[1:])
+			} else if !strings.HasPrefix(rest, "]") {
+				return nil, "", errors.New("unterminated array")
+			}
+			s = rest
+		}
+		return values, strings.TrimSpace(s[1:]), nil
+	default:
+		token, rest, _ := strings.Cut(s, " ")
+		rest = strings.TrimSpace(rest)
+		switch token {
+		case "true":
+			return true, rest, nil
+		case "false":
+			return false, rest, nil
+		}
+		n, err := strconv.ParseInt(strings.ReplaceAll(token, "_", ""), 10, 64)
+		if err != nil {
+			return nil, "", fmt.Errorf("unsupported value %q", token)
+		}
+		return n, rest, nil
+	}
+}
+
+// parseTOMLString 解析s开头的基本字符串，返回字符串和去掉前导空白的剩余部分
+func parseTOMLString(s string) (string, string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", "", fmt.Errorf("expected string, got %q", s)
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '"':
+			return b.String(), strings.TrimSpace(s[i+1:]), nil
+		case c != '\\':
+			b.WriteByte(c)
+			i++
+			continue
+		}
+
+		if i+1 >= len(s) {
+			break
+		}
+		escape := s[i+1]
+		i += 2
+		switch escape {
+		case '"', '\\':
+			b.WriteByte(escape)
+		case 'b':
+			b.WriteByte('\b')
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'f':
+			b.WriteByte('\f')
+		case 'r':
+			b.WriteByte('\r')
+		case 'u', 'U':
+			size := 4
+			if escape == 'U' {
+				size = 8
+			}
+			if i+size > len(s) {
+				return "", "", errors.New("invalid unicode escape")
+			}
+			code, err := strconv.ParseUint(s[i:i+size], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(code)) {
+				return "", "", fmt.Errorf("invalid unicode escape %q", s[i:i+size])
+			}
+			b.WriteRune(rune(code))
+			i += size
+		default:
+			return "", "", fmt.Errorf("invalid escape \\%c", escape)
+		}
+	}
+	return "", "", errors.New("unterminated string")
+}
@@ -0,0 +1,49 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWritePackagesTOML_RoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WritePackagesTOML(&buf, testPackages()))
+	output := buf.String()
+	assert.True(t, strings.HasPrefix(output, "[[packages]]\nname = \"rails\"\n"), output)
+	assert.Contains(t, output, `licenses = ["MIT"]`)
+	assert.Contains(t, output, `runtime_dependencies = ["actionpack (= 7.0.5)", "bundler (>= 1.15.0, < 3)"]`)
+	assert.Contains(t, output, `\n\t\"Convention over configuration\" \\ \u0001"`)
+	assert.Contains(t, output, "downloads = 436090160\n")
+
+	packages, err := ReadPackagesTOML(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedPackages(), packages)
+}
+
+func TestWritePackagesTOML_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WritePackagesTOML(&buf, nil))
+	assert.Empty(t, buf.String())
+
+	packages, err := ReadPackagesTOML(&buf)
+	assert.NoError(t, err)
+	assert.Empty(t, packages)
+}
+
+func TestReadPackagesTOML_Invalid(t *testing.T) {
+	testCases := []string{
+		"name = \"rails\"\n",
+		"[[packages]]\nname = \"rails\n",
+		"[[packages]]\nlicenses = [\"MIT\"\n",
+		"[[packages]]\ndownloads = many\n",
+		"[[packages]]\nname = \"\\q\"\n",
+		"[[packages]]\ndownloads = \"many\"\n",
+	}
+	for _, input := range testCases {
+		_, err := ReadPackagesTOML(strings.NewReader(input))
+		assert.Error(t, err, input)
+	}
+}
@@ -0,0 +1,54 @@
+package export
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+// WritePackagesYAML 把包信息写成一个YAML列表，每个包是一个按PackageFields顺序排列键的映射
+// packages中的nil会被跳过
+func WritePackagesYAML(w io.Writer, packages []*models.PackageInformation) error {
+	list := &yaml.Node{Kind: yaml.SequenceNode}
+	for _, pkg := range packages {
+		if pkg == nil {
+			continue
+		}
+		mapping := &yaml.Node{Kind: yaml.MappingNode}
+		for _, field := range PackageFields(pkg) {
+			value := &yaml.Node{}
+			if err := value.Encode(field.Value); err != nil {
+				return fmt.Errorf("encode field %s of %s: %w", field.Name, pkg.Name, err)
+			}
+			mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: field.Name}, value)
+		}
+		list.Content = append(list.Content, mapping)
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(list); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// ReadPackagesYAML 读取WritePackagesYAML写出的文件
+func ReadPackagesYAML(r io.Reader) ([]*models.PackageInformation, error) {
+	var records []map[string]interface{}
+	if err := yaml.NewDecoder(r).Decode(&records); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	packages := make([]*models.PackageInformation, 0, len(records))
+	for i, record := range records {
+		pkg, err := packageFromFields(record)
+		if err != nil {
+			return nil, fmt.Errorf("package %d: %w", i, err)
+		}
+		packages = append(packages, pkg)
+	}
+	return packages, nil
+}
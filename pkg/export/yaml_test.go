@@ -0,0 +1,33 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWritePackagesYAML_RoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WritePackagesYAML(&buf, testPackages()))
+	assert.True(t, strings.HasPrefix(buf.String(), "- name: rails\n  version: 7.0.5\n"), buf.String())
+
+	packages, err := ReadPackagesYAML(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedPackages(), packages)
+}
+
+func TestWritePackagesYAML_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WritePackagesYAML(&buf, nil))
+
+	packages, err := ReadPackagesYAML(&buf)
+	assert.NoError(t, err)
+	assert.Empty(t, packages)
+}
+
+func TestReadPackagesYAML_InvalidField(t *testing.T) {
+	_, err := ReadPackagesYAML(strings.NewReader("- name: rails\n  downloads: many\n"))
+	assert.ErrorContains(t, err, "downloads")
+}
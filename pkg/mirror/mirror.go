@@ -0,0 +1,232 @@
+// Package mirror 提供把RubyGems仓库同步到本地目录的镜像工具
+// 同步的状态保存在目标目录中，中断的同步可以在下一次运行时继续
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+	"github.com/scagogogo/rubygems-crawler/pkg/repository"
+)
+
+// StateFileName 同步状态文件的文件名，保存在目标目录中
+const StateFileName = ".mirror-state.json"
+
+// stateSaveInterval 每同步这么多个gem保存一次状态文件，避免同步全部gem时每个gem都重写整个状态文件
+const stateSaveInterval = 100
+
+// validGemName 匹配合法的gem名称，gem名称会用作本地文件名，不能包含路径分隔符
+var validGemName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// Mirror 把仓库中的gem包文件和版本元数据同步到本地目录
+// 目标目录的结构为:
+//   - gems/[GEM NAME]-[VERSION].gem: gem包文件
+//   - api/v1/versions/[GEM NAME].json: 版本列表，与NewFixtureRepository使用的路径一致
+//   - .mirror-state.json: 已经同步的文件及其校验和
+type Mirror struct {
+	repo *repository.RepositoryImpl
+}
+
+// NewMirror 创建从repo同步的镜像
+func NewMirror(repo *repository.RepositoryImpl) *Mirror {
+	return &Mirror{repo: repo}
+}
+
+// SyncOptions 同步选项
+type SyncOptions struct {
+	// 只同步这些gem，为空时通过compact index同步仓库中的全部gem
+	Gems []string
+
+	// 下载gem包文件时的批量选项，控制并发数，为nil时使用默认选项
+	BulkOptions *repository.BulkOptions
+}
+
+// SyncReport 一次同步的结果统计，计数的单位是gem包文件
+type SyncReport struct {
+	// 新下载或者内容变化后重新下载的文件数
+	Downloaded int
+
+	// 已经同步过、不需要下载的文件数
+	Skipped int
+
+	// 下载或校验失败的文件数，获取版本列表失败的gem计为一个失败
+	Failed int
+
+	// 每个失败的原因
+	Errors []error
+}
+
+// syncState 保存在状态文件中的同步进度，键为gem文件名，值为文件的SHA256
+type syncState struct {
+	Files map[string]string `json:"files"`
+}
+
+// Sync 把仓库同步到dest目录
+// 依次获取每个gem的版本列表，下载本地还没有或者校验和发生变化的gem包文件（下载时校验SHA256），
+// 并写入版本列表。每同步完一批gem、同步结束以及ctx被取消时都会更新状态文件，所以被中断的同步再次运行时会跳过已经完成的文件。
+// 单个gem或文件的失败记录在SyncReport中，不会中止同步；名称或版本号不能安全地用作文件名的gem计为失败。
+// 获取compact index或读写状态文件失败、以及ctx被取消时返回错误，此时SyncReport包含已经完成的部分
+func (m *Mirror) Sync(ctx context.Context, dest string, opts SyncOptions) (*SyncReport, error) {
+	report := &SyncReport{}
+
+	gems := opts.Gems
+	if len(gems) == 0 {
+		if _, err := m.repo.RefreshIndex(ctx); err != nil {
+			return report, fmt.Errorf("refresh compact index: %w", err)
+		}
+		gems = m.repo.CompactIndex().GemNames()
+	}
+
+	gemsDir := filepath.Join(dest, "gems")
+	metadataDir := filepath.Join(dest, "api", "v1", "versions")
+	for _, dir := range []string{gemsDir, metadataDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return report, err
+		}
+	}
+
+	statePath := filepath.Join(dest, StateFileName)
+	state, err := loadState(statePath)
+	if err != nil {
+		return report, err
+	}
+
+	for i, gemName := range gems {
+		if err := ctx.Err(); err != nil {
+			return report, finishSync(statePath, state, err)
+		}
+		m.syncGem(ctx, gemName, gemsDir, metadataDir, state, opts.BulkOptions, report)
+		if (i+1)%stateSaveInterval == 0 {
+			if err := saveState(statePath, state); err != nil {
+				return report, err
+			}
+		}
+	}
+	return report, finishSync(statePath, state, ctx.Err())
+}
+
+// finishSync 在同步结束或者被取消时保存状态文件，syncErr优先于保存失败的错误返回
+func finishSync(statePath string, state *syncState, syncErr error) error {
+	if err := saveState(statePath, state); err != nil && syncErr == nil {
+		return err
+	}
+	return syncErr
+}
+
+// syncGem 同步一个gem的版本列表和全部gem包文件，结果记录在report和state中
+func (m *Mirror) syncGem(ctx context.Context, gemName, gemsDir, metadataDir string, state *syncState, bulkOptions *repository.BulkOptions, report *SyncReport) {
+	// 名称来自服务器的compact index，不合法的名称可能让文件写到dest之外
+	if !isSafeFileComponent(gemName) {
+		report.Failed++
+		report.Errors = append(report.Errors, fmt.Errorf("%q: invalid gem name", gemName))
+		return
+	}
+
+	versions, err := m.repo.GetGemVersions(ctx, gemName)
+	if err != nil {
+		report.Failed++
+		report.Errors = append(report.Errors, fmt.Errorf("%s: versions: %w", gemName, err))
+		return
+	}
+
+	refs := make([]repository.GemVersionRef, 0, len(versions))
+	shas := make(map[string]string, len(versions))
+	for _, version := range versions {
+		if version == nil || version.Number == "" {
+			continue
+		}
+		ref := gemVersionRef(gemName, version)
+		if !isSafeFileComponent(ref.Version) {
+			report.Failed++
+			report.Errors = append(report.Errors, fmt.Errorf("%s: invalid version %q", gemName, ref.Version))
+			continue
+		}
+		fileName := ref.FileName()
+		if sha, ok := state.Files[fileName]; ok && sha == ref.Sha && fileExists(filepath.Join(gemsDir, fileName)) {
+			report.Skipped++
+			continue
+		}
+		refs = append(refs, ref)
+		shas[fileName] = ref.Sha
+	}
+
+	for _, result := range m.repo.DownloadGems(ctx, refs, gemsDir, bulkOptions) {
+		switch {
+		case result == nil:
+			// 被取消时没有处理的文件，下次同步时继续
+		case result.Error != nil:
+			report.Failed++
+			report.Errors = append(report.Errors, fmt.Errorf("%s: %w", result.Key, result.Error))
+		default:
+			report.Downloaded++
+			state.Files[result.Key] = shas[result.Key]
+		}
+	}
+
+	if err := writeJSON(filepath.Join(metadataDir, gemName+".json"), versions); err != nil {
+		report.Failed++
+		report.Errors = append(report.Errors, fmt.Errorf("%s: metadata: %w", gemName, err))
+	}
+}
+
+// gemVersionRef 把版本列表中的版本转换为下载引用，非ruby平台的版本号带上平台后缀
+func gemVersionRef(gemName string, version *models.Version) repository.GemVersionRef {
+	number := version.Number
+	if version.Platform != "" && version.Platform != "ruby" {
+		number += "-" + version.Platform
+	}
+	return repository.GemVersionRef{Name: gemName, Version: number, Sha: version.Sha}
+}
+
+// isSafeFileComponent 判断gem名称或版本号能否安全地用作文件名的一部分
+// 只允许RubyGems名称使用的字符，并且不能包含".."
+func isSafeFileComponent(name string) bool {
+	return validGemName.MatchString(name) && !strings.Contains(name, "..")
+}
+
+func loadState(path string) (*syncState, error) {
+	state := &syncState{Files: make(map[string]string)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("read mirror state %s: %w", path, err)
+	}
+	if state.Files == nil {
+		state.Files = make(map[string]string)
+	}
+	return state, nil
+}
+
+func saveState(path string, state *syncState) error {
+	return writeJSON(path, state)
+}
+
+// writeJSON 先写入临时文件再重命名，避免中断时留下不完整的文件
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,171 @@
+package mirror
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func sha(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestMirror_Sync(t *testing.T) {
+	var mu sync.Mutex
+	requested := make(map[string]int)
+	routes := map[string]string{
+		"/names":                     "---\nrack\ntiny\n",
+		"/versions":                  "created_at: 2024-01-01T00:00:00Z\n---\nrack 3.0.7,3.0.8 abc\ntiny 0.1.0 def\n",
+		"/api/v1/versions/rack.json": `[{"number": "3.0.8", "platform": "ruby", "sha": "` + sha("rack-3.0.8") + `"}, {"number": "3.0.7", "platform": "ruby", "sha": "` + sha("rack-3.0.7") + `"}]`,
+		"/api/v1/versions/tiny.json": `[{"number": "0.1.0", "platform": "java", "sha": "` + sha("tiny-0.1.0-java") + `"}]`,
+		"/gems/rack-3.0.8.gem":       "rack-3.0.8",
+		"/gems/rack-3.0.7.gem":       "rack-3.0.7",
+		"/gems/tiny-0.1.0-java.gem":  "corrupted",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested[r.URL.Path]++
+		body, ok := routes[r.URL.Path]
+		mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	repo := repository.NewRepository(repository.NewOptions().SetServerURL(server.URL).DisableRetry())
+	mirror := NewMirror(repo)
+	dest := t.TempDir()
+
+	report, err := mirror.Sync(context.Background(), dest, SyncOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Downloaded)
+	assert.Equal(t, 0, report.Skipped)
+	// tiny的文件校验和不一致
+	assert.Equal(t, 1, report.Failed)
+	if assert.Len(t, report.Errors, 1) {
+		assert.ErrorIs(t, report.Errors[0], repository.ErrChecksumMismatch)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dest, "gems", "rack-3.0.8.gem"))
+	assert.NoError(t, err)
+	assert.Equal(t, "rack-3.0.8", string(content))
+	assert.FileExists(t, filepath.Join(dest, "api", "v1", "versions", "rack.json"))
+	assert.FileExists(t, filepath.Join(dest, StateFileName))
+
+	// 同步出的目录可以直接作为离线仓库使用
+	versions, err := repository.NewFixtureRepository(os.DirFS(dest)).GetGemVersions(context.Background(), "rack")
+	assert.NoError(t, err)
+	assert.Len(t, versions, 2)
+
+	// 第二次同步跳过已经完成的文件，只重试失败的文件
+	mu.Lock()
+	routes["/gems/tiny-0.1.0-java.gem"] = "tiny-0.1.0-java"
+	mu.Unlock()
+	report, err = NewMirror(repo).Sync(context.Background(), dest, SyncOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Downloaded)
+	assert.Equal(t, 2, report.Skipped)
+	assert.Equal(t, 0, report.Failed)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, requested["/gems/rack-3.0.8.gem"])
+	assert.Equal(t, 2, requested["/gems/tiny-0.1.0-java.gem"])
+}
+
+func TestMirror_SyncSelectedGems(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/versions/rack.json":
+			_, _ = w.Write([]byte(`[{"number": "3.0.8", "platform": "ruby", "sha": "` + sha("rack-3.0.8") + `"}]`))
+		case "/gems/rack-3.0.8.gem":
+			_, _ = w.Write([]byte("rack-3.0.8"))
+		default:
+			// 指定了gem时不需要compact index
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	repo := repository.NewRepository(repository.NewOptions().SetServerURL(server.URL).DisableRetry())
+	report, err := NewMirror(repo).Sync(context.Background(), t.TempDir(), SyncOptions{Gems: []string{"rack", "missing"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Downloaded)
+	assert.Equal(t, 1, report.Failed)
+}
+
+func TestMirror_SyncRejectsUnsafeNames(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/names":
+			_, _ = w.Write([]byte("---\n../../evil\nrack\n"))
+		case "/api/v1/versions/rack.json":
+			_, _ = w.Write([]byte(`[{"number": "3.0.8", "platform": "ruby", "sha": "` + sha("rack-3.0.8") + `"}, {"number": "../../../evil", "platform": "ruby", "sha": "` + sha("evil") + `"}]`))
+		case "/gems/rack-3.0.8.gem":
+			_, _ = w.Write([]byte("rack-3.0.8"))
+		default:
+			_, _ = w.Write([]byte(`[{"number": "1.0.0", "platform": "ruby", "sha": "` + sha("evil") + `"}]`))
+		}
+	}))
+	defer server.Close()
+
+	root := t.TempDir()
+	dest := filepath.Join(root, "mirror")
+	repo := repository.NewRepository(repository.NewOptions().SetServerURL(server.URL).DisableRetry())
+	report, err := NewMirror(repo).Sync(context.Background(), dest, SyncOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Downloaded)
+	// 不合法的gem名称和版本号各计为一个失败
+	assert.Equal(t, 2, report.Failed)
+
+	// dest之外没有写入任何文件
+	entries, err := os.ReadDir(root)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "mirror", entries[0].Name())
+	}
+	assert.NoFileExists(t, filepath.Join(dest, "api", "evil.json"))
+	assert.NoFileExists(t, filepath.Join(dest, "evil.gem"))
+}
+
+func TestMirror_SyncSavesStateWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/versions/rack.json":
+			_, _ = w.Write([]byte(`[{"number": "3.0.8", "platform": "ruby", "sha": "` + sha("rack-3.0.8") + `"}]`))
+		case "/gems/rack-3.0.8.gem":
+			_, _ = w.Write([]byte("rack-3.0.8"))
+		default:
+			// 第一个gem同步完成后，在同步第二个gem时取消
+			cancel()
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	dest := t.TempDir()
+	repo := repository.NewRepository(repository.NewOptions().SetServerURL(server.URL).DisableRetry())
+	_, err := NewMirror(repo).Sync(ctx, dest, SyncOptions{Gems: []string{"rack", "tiny"}})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// 不到一批也会在取消时保存状态
+	state, err := loadState(filepath.Join(dest, StateFileName))
+	assert.NoError(t, err)
+	assert.Equal(t, sha("rack-3.0.8"), state.Files["rack-3.0.8.gem"])
+}
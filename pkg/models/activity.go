@@ -0,0 +1,115 @@
+package models
+
+import "time"
+
+// ActivityItem 是/api/v1/activity/latest.json和just_updated.json动态中的一项
+// 动态的每一项描述的是刚刚发布的那个版本，与/api/v1/gems/[GEM NAME].json返回的包信息相似但不完全相同，
+// 例如多了spec_sha，wiki_uri、funding_uri等字段经常为null
+// Example:
+//
+//	{
+//	   "name": "rails",
+//	   "downloads": 436090160,
+//	   "version": "7.0.5",
+//	   "version_created_at": "2023-05-24T19:21:28.229Z",
+//	   "version_downloads": 54428,
+//	   "platform": "ruby",
+//	   "authors": "David Heinemeier Hansson",
+//	   "info": "Ruby on Rails is a full-stack web framework optimized for programmer happiness and sustainable productivity.",
+//	   "licenses": ["MIT"],
+//	   "metadata": {"changelog_uri": "https://github.com/rails/rails/releases/tag/v7.0.5"},
+//	   "yanked": false,
+//	   "sha": "57ef2baa4a1f5f954bc6e5a019b1fac8486ece36f79c1cf366e6de33210637fe",
+//	   "spec_sha": "3f3f07a5bdb3b2b4c4bbbe6e5d0fa4c8f4e4f9d5f6b3f1c4c7a43a6f5b2d2d8e",
+//	   "project_uri": "https://rubygems.org/gems/rails",
+//	   "gem_uri": "https://rubygems.org/gems/rails-7.0.5.gem",
+//	   "homepage_uri": "https://rubyonrails.org",
+//	   "wiki_uri": null,
+//	   "documentation_uri": null,
+//	   "mailing_list_uri": null,
+//	   "source_code_uri": "https://github.com/rails/rails/tree/v7.0.5",
+//	   "bug_tracker_uri": "https://github.com/rails/rails/issues",
+//	   "changelog_uri": null,
+//	   "funding_uri": null,
+//	   "dependencies": {"development": [], "runtime": [{"name": "actioncable", "requirements": "= 7.0.5"}]}
+//	}
+type ActivityItem struct {
+	Name string `json:"name"`
+
+	// Version 刚刚发布的版本号
+	Version string `json:"version"`
+
+	// VersionCreatedAt 这个版本的发布时间
+	VersionCreatedAt time.Time `json:"version_created_at"`
+
+	// VersionDownloads 这个版本的下载量，刚发布的版本通常很小
+	VersionDownloads int `json:"version_downloads"`
+
+	// Downloads 所有版本的总下载量
+	Downloads Count `json:"downloads"`
+
+	Platform     string       `json:"platform"`
+	Authors      string       `json:"authors"`
+	Info         string       `json:"info"`
+	Licenses     []string     `json:"licenses"`
+	Metadata     Metadata     `json:"metadata"`
+	Yanked       bool         `json:"yanked"`
+	Sha          string       `json:"sha"`
+	SpecSha      string       `json:"spec_sha"`
+	ProjectURI   string       `json:"project_uri"`
+	GemURI       string       `json:"gem_uri"`
+	Dependencies Dependencies `json:"dependencies"`
+
+	// 以下链接在动态中经常为null
+	HomepageURI      *string `json:"homepage_uri"`
+	WikiURI          *string `json:"wiki_uri"`
+	DocumentationURI *string `json:"documentation_uri"`
+	MailingListURI   *string `json:"mailing_list_uri"`
+	SourceCodeURI    *string `json:"source_code_uri"`
+	BugTrackerURI    *string `json:"bug_tracker_uri"`
+	ChangelogURI     *string `json:"changelog_uri"`
+	FundingURI       *string `json:"funding_uri"`
+}
+
+// AsPackageInformation 把动态项转换为PackageInformation，兼容以前直接返回包信息的用法
+// 为null的链接转换为空字符串，再按PackageInformation.Normalize的规则用metadata补全
+func (a *ActivityItem) AsPackageInformation() *PackageInformation {
+	pkg := &PackageInformation{
+		Name:             a.Name,
+		Downloads:        a.Downloads,
+		Version:          a.Version,
+		VersionCreatedAt: a.VersionCreatedAt,
+		VersionDownloads: a.VersionDownloads,
+		Platform:         a.Platform,
+		Authors:          a.Authors,
+		Info:             a.Info,
+		Licenses:         a.Licenses,
+		Metadata:         a.Metadata,
+		Yanked:           a.Yanked,
+		Sha:              a.Sha,
+		ProjectURI:       a.ProjectURI,
+		GemURI:           a.GemURI,
+		HomepageURI:      derefString(a.HomepageURI),
+		DocumentationURI: derefString(a.DocumentationURI),
+		MailingListURI:   derefString(a.MailingListURI),
+		SourceCodeURI:    derefString(a.SourceCodeURI),
+		BugTrackerURI:    derefString(a.BugTrackerURI),
+		ChangelogURI:     derefString(a.ChangelogURI),
+		Dependencies:     a.Dependencies,
+	}
+	if a.WikiURI != nil {
+		pkg.WikiURI = *a.WikiURI
+	}
+	if a.FundingURI != nil {
+		pkg.FundingURI = *a.FundingURI
+	}
+	pkg.Normalize()
+	return pkg
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
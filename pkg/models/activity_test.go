@@ -0,0 +1,71 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 来自/api/v1/activity/latest.json的一项
+const activityFixture = `{
+	"name": "sidekiq",
+	"downloads": 301456789,
+	"version": "7.1.2",
+	"version_created_at": "2023-08-04T15:51:47.332Z",
+	"version_downloads": 128,
+	"platform": "ruby",
+	"authors": "Mike Perham",
+	"info": "Simple, efficient background processing for Ruby.",
+	"licenses": ["LGPL-3.0"],
+	"metadata": {
+		"homepage_uri": "https://sidekiq.org",
+		"changelog_uri": "https://github.com/sidekiq/sidekiq/blob/main/Changes.md",
+		"source_code_uri": "https://github.com/sidekiq/sidekiq",
+		"wiki_uri": "https://github.com/sidekiq/sidekiq/wiki"
+	},
+	"yanked": false,
+	"sha": "2b0e5d6a0d4e1d8c7e2f1f0a9c8b7a6d5e4f3c2b1a09f8e7d6c5b4a39281706f",
+	"spec_sha": "9a8b7c6d5e4f30211f2e3d4c5b6a79880f1e2d3c4b5a69788f7e6d5c4b3a2910",
+	"project_uri": "https://rubygems.org/gems/sidekiq",
+	"gem_uri": "https://rubygems.org/gems/sidekiq-7.1.2.gem",
+	"homepage_uri": "https://sidekiq.org",
+	"wiki_uri": null,
+	"documentation_uri": null,
+	"mailing_list_uri": null,
+	"source_code_uri": null,
+	"bug_tracker_uri": "https://github.com/sidekiq/sidekiq/issues",
+	"changelog_uri": null,
+	"funding_uri": null,
+	"dependencies": {
+		"development": [],
+		"runtime": [
+			{"name": "concurrent-ruby", "requirements": "< 2"},
+			{"name": "connection_pool", "requirements": ">= 2.3.0"},
+			{"name": "rack", "requirements": ">= 2.2.4"},
+			{"name": "redis-client", "requirements": ">= 0.14.0"}
+		]
+	}
+}`
+
+func TestActivityItem_AsPackageInformation(t *testing.T) {
+	var item ActivityItem
+	assert.NoError(t, json.Unmarshal([]byte(activityFixture), &item))
+	assert.Equal(t, "7.1.2", item.Version)
+	assert.Equal(t, 128, item.VersionDownloads)
+	assert.Nil(t, item.SourceCodeURI)
+	assert.NotEmpty(t, item.SpecSha)
+
+	pkg := item.AsPackageInformation()
+	assert.Equal(t, "sidekiq", pkg.Name)
+	assert.Equal(t, "7.1.2", pkg.Version)
+	assert.Equal(t, Count(301456789), pkg.Downloads)
+	assert.Equal(t, "https://sidekiq.org", pkg.HomepageURI)
+	assert.Equal(t, "https://github.com/sidekiq/sidekiq/issues", pkg.BugTrackerURI)
+	// 动态中为null的链接会用metadata补全
+	assert.Equal(t, "https://github.com/sidekiq/sidekiq", pkg.SourceCodeURI)
+	assert.Equal(t, "https://github.com/sidekiq/sidekiq/blob/main/Changes.md", pkg.ChangelogURI)
+	assert.Equal(t, "https://github.com/sidekiq/sidekiq/wiki", pkg.WikiURI)
+	assert.Nil(t, pkg.FundingURI)
+	assert.Len(t, pkg.Dependencies.Runtime, 4)
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CompactVersion 是compact index中/info/[GEM]文件的一行，描述一个版本的依赖和校验和
+// 参考: https://guides.rubygems.org/rubygems-org-compact-index-api/
+type CompactVersion struct {
+	// 版本号，不包含平台后缀
+	Number string `json:"number"`
+
+	// 平台，ruby平台的版本为空，例如 "1.2.3-java" 的平台为 "java"
+	Platform string `json:"platform,omitempty"`
+
+	// 运行时依赖，多个版本约束用 ", " 连接，与API返回的格式一致
+	Dependencies []*Dependency `json:"dependencies,omitempty"`
+
+	// .gem文件的sha256校验和
+	Checksum string `json:"checksum"`
+
+	// 要求的ruby和rubygems版本，没有声明时为空
+	RequiredRubyVersion     string `json:"required_ruby_version,omitempty"`
+	RequiredRubygemsVersion string `json:"required_rubygems_version,omitempty"`
+}
+
+// ParseCompactInfo 逐行解析/info/[GEM]文件，跳过开头的 "---" 和空行
+// 每一行的格式为 "VERSION[-PLATFORM] DEP:REQ&REQ,DEP:REQ|checksum:SHA,ruby:REQ,rubygems:REQ"，
+// 没有依赖时依赖部分为空，例如 "1.0.0 |checksum:abc"
+func ParseCompactInfo(r io.Reader) ([]*CompactVersion, error) {
+	versions := make([]*CompactVersion, 0)
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line == "---" {
+			continue
+		}
+		version, err := parseCompactVersion(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		versions = append(versions, version)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// parseCompactVersion 解析/info文件中的一行
+func parseCompactVersion(line string) (*CompactVersion, error) {
+	spec, rest, ok := strings.Cut(line, " ")
+	if !ok || spec == "" {
+		return nil, fmt.Errorf("invalid compact index line %q", line)
+	}
+	dependencies, requirements, ok := strings.Cut(rest, "|")
+	if !ok {
+		return nil, fmt.Errorf("invalid compact index line %q: missing requirements", line)
+	}
+
+	version := &CompactVersion{Number: spec}
+	// 版本号中不会出现 "-"，第一个 "-" 之后都是平台，例如 "1.15.4-x86_64-linux"
+	if number, platform, found := strings.Cut(spec, "-"); found {
+		version.Number, version.Platform = number, platform
+	}
+
+	for _, dependency := range strings.Split(dependencies, ",") {
+		if strings.TrimSpace(dependency) == "" {
+			continue
+		}
+		name, requirement, found := strings.Cut(dependency, ":")
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid dependency %q in compact index line %q", dependency, line)
+		}
+		version.Dependencies = append(version.Dependencies, &Dependency{
+			Name:         name,
+			Requirements: compactRequirement(requirement),
+		})
+	}
+
+	for _, field := range strings.Split(requirements, ",") {
+		key, value, found := strings.Cut(field, ":")
+		if !found {
+			continue
+		}
+		switch key {
+		case "checksum":
+			version.Checksum = value
+		case "ruby":
+			version.RequiredRubyVersion = compactRequirement(value)
+		case "rubygems":
+			version.RequiredRubygemsVersion = compactRequirement(value)
+		}
+	}
+	if version.Checksum == "" {
+		return nil, fmt.Errorf("invalid compact index line %q: missing checksum", line)
+	}
+	return version, nil
+}
+
+// compactRequirement 把compact index中用 "&" 连接的多个约束转换为 ", " 连接
+func compactRequirement(requirement string) string {
+	constraints := strings.Split(requirement, "&")
+	for i, constraint := range constraints {
+		constraints[i] = strings.TrimSpace(constraint)
+	}
+	return strings.Join(constraints, ", ")
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCompactInfo(t *testing.T) {
+	info := `---
+1.0.0 |checksum:aaa
+1.1.0 rack:>= 1.0&< 3,json:~> 2.0|checksum:bbb,ruby:>= 2.7.0
+1.2.3-java rack:>= 2.2|checksum:ccc,ruby:>= 2.7.0&< 4,rubygems:>= 3.3.22
+1.15.4-x86_64-linux mini_portile2:~> 2.8.2,racc:~> 1.4|checksum:ddd
+`
+	versions, err := ParseCompactInfo(strings.NewReader(info))
+	assert.NoError(t, err)
+	if !assert.Len(t, versions, 4) {
+		return
+	}
+
+	assert.Equal(t, "1.0.0", versions[0].Number)
+	assert.Empty(t, versions[0].Platform)
+	assert.Empty(t, versions[0].Dependencies)
+	assert.Equal(t, "aaa", versions[0].Checksum)
+
+	assert.Equal(t, []*Dependency{
+		{Name: "rack", Requirements: ">= 1.0, < 3"},
+		{Name: "json", Requirements: "~> 2.0"},
+	}, versions[1].Dependencies)
+	assert.Equal(t, ">= 2.7.0", versions[1].RequiredRubyVersion)
+
+	// 带平台后缀的版本
+	assert.Equal(t, "1.2.3", versions[2].Number)
+	assert.Equal(t, "java", versions[2].Platform)
+	assert.Equal(t, ">= 2.7.0, < 4", versions[2].RequiredRubyVersion)
+	assert.Equal(t, ">= 3.3.22", versions[2].RequiredRubygemsVersion)
+
+	assert.Equal(t, "1.15.4", versions[3].Number)
+	assert.Equal(t, "x86_64-linux", versions[3].Platform)
+	assert.Len(t, versions[3].Dependencies, 2)
+}
+
+func TestParseCompactInfo_Invalid(t *testing.T) {
+	for _, info := range []string{
+		"---\n1.0.0\n",
+		"---\n1.0.0 rack:>= 1.0\n",
+		"---\n1.0.0 |ruby:>= 2.7\n",
+		"---\n1.0.0 :>= 1.0|checksum:aaa\n",
+	} {
+		_, err := ParseCompactInfo(strings.NewReader(info))
+		assert.Error(t, err, info)
+	}
+}
@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// 元数据完整度评分中各项的权重，总和为100
+const (
+	CompletenessWeightHomepage      = 15
+	CompletenessWeightSourceCode    = 20
+	CompletenessWeightChangelog     = 15
+	CompletenessWeightLicense       = 20
+	CompletenessWeightDocumentation = 15
+	CompletenessWeightRecentRelease = 15
+)
+
+// RecentReleaseWindow 最新版本在这个时间范围内发布才算作近期发布
+const RecentReleaseWindow = 365 * 24 * time.Hour
+
+// MetadataCompleteness 根据已有字段估算包元数据的完整度，返回0-100的分数
+// 每一项满足时加上对应的权重：
+//   - 主页 CompletenessWeightHomepage
+//   - 源码地址 CompletenessWeightSourceCode
+//   - 变更日志 CompletenessWeightChangelog
+//   - 可识别的许可证 CompletenessWeightLicense
+//   - 文档地址 CompletenessWeightDocumentation
+//   - 最新版本在RecentReleaseWindow内发布 CompletenessWeightRecentRelease
+//
+// 链接字段在顶层为空时使用metadata中的同名字段。这只是启发式的评分，用于找出文档不全的依赖
+func (p *PackageInformation) MetadataCompleteness() int {
+	return p.metadataCompleteness(time.Now())
+}
+
+func (p *PackageInformation) metadataCompleteness(now time.Time) int {
+	score := 0
+	if firstNonEmpty(p.HomepageURI, p.Metadata.HomepageURI) != "" {
+		score += CompletenessWeightHomepage
+	}
+	if firstNonEmpty(p.SourceCodeURI, p.Metadata.SourceCodeURI) != "" {
+		score += CompletenessWeightSourceCode
+	}
+	if firstNonEmpty(p.ChangelogURI, p.Metadata.ChangelogURI) != "" {
+		score += CompletenessWeightChangelog
+	}
+	if hasKnownLicense(p.Licenses) {
+		score += CompletenessWeightLicense
+	}
+	if firstNonEmpty(p.DocumentationURI, p.Metadata.DocumentationURI) != "" {
+		score += CompletenessWeightDocumentation
+	}
+	if !p.VersionCreatedAt.IsZero() && now.Sub(p.VersionCreatedAt) <= RecentReleaseWindow {
+		score += CompletenessWeightRecentRelease
+	}
+	return score
+}
+
+func hasKnownLicense(licenses []string) bool {
+	for _, license := range NormalizeLicenses(licenses) {
+		if license != UnknownLicense {
+			return true
+		}
+	}
+	return false
+}
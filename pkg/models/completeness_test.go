@@ -0,0 +1,46 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackageInformation_MetadataCompleteness(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	full := &PackageInformation{
+		Name:             "rails",
+		VersionCreatedAt: now.Add(-30 * 24 * time.Hour),
+		Licenses:         []string{"MIT"},
+		HomepageURI:      "https://rubyonrails.org",
+		DocumentationURI: "https://api.rubyonrails.org/v7.1.2/",
+		SourceCodeURI:    "https://github.com/rails/rails/tree/v7.1.2",
+		ChangelogURI:     "https://github.com/rails/rails/releases/tag/v7.1.2",
+	}
+	assert.Equal(t, 100, full.metadataCompleteness(now))
+
+	// 链接只出现在metadata中也算数
+	metadataOnly := &PackageInformation{
+		Licenses: []string{"mit"},
+		Metadata: Metadata{
+			SourceCodeURI: "https://github.com/rack/rack",
+			ChangelogURI:  "https://github.com/rack/rack/blob/main/CHANGELOG.md",
+		},
+	}
+	score := metadataOnly.metadataCompleteness(now)
+	assert.Equal(t, CompletenessWeightLicense+CompletenessWeightSourceCode+CompletenessWeightChangelog, score)
+
+	// 多年没有发布、只有主页的包得分很低
+	sparse := &PackageInformation{
+		VersionCreatedAt: now.AddDate(-5, 0, 0),
+		Licenses:         []string{""},
+		HomepageURI:      "http://example.com",
+	}
+	score = sparse.metadataCompleteness(now)
+	assert.Equal(t, CompletenessWeightHomepage, score)
+	assert.Less(t, score, 50)
+
+	assert.Equal(t, 0, (&PackageInformation{}).MetadataCompleteness())
+}
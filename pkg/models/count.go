@@ -0,0 +1,45 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Count 表示下载量等可能非常大的计数
+// 使用int64避免在32位平台上溢出：整个仓库和热门gem的累计下载量都早已超过2^31。
+// 反序列化时既接受JSON数字也接受数字字符串（部分镜像会把大数序列化为字符串），序列化时总是输出数字
+type Count int64
+
+// UnmarshalJSON 解析JSON数字或者数字字符串，null保持为0
+func (c *Count) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		data = []byte(s)
+		if len(bytes.TrimSpace(data)) == 0 {
+			*c = 0
+			return nil
+		}
+	}
+
+	value, err := strconv.ParseInt(string(bytes.TrimSpace(data)), 10, 64)
+	if err != nil {
+		// 兼容以浮点数形式序列化的整数，例如 1.0e+10
+		f, floatErr := strconv.ParseFloat(string(bytes.TrimSpace(data)), 64)
+		if floatErr != nil || f != float64(int64(f)) {
+			return fmt.Errorf("invalid count %s: %w", data, err)
+		}
+		value = int64(f)
+	}
+	*c = Count(value)
+	return nil
+}
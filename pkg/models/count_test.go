@@ -0,0 +1,49 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCount_UnmarshalJSON(t *testing.T) {
+	cases := map[string]Count{
+		`0`:              0,
+		`436090160`:      436090160,
+		`"436090160"`:    436090160,
+		`null`:           0,
+		`""`:             0,
+		`1.0e+10`:        10000000000,
+		`" 5000000000 "`: 5000000000,
+	}
+	for input, expected := range cases {
+		var count Count
+		assert.NoError(t, json.Unmarshal([]byte(input), &count), input)
+		assert.Equal(t, expected, count, input)
+	}
+
+	for _, input := range []string{`"many"`, `1.5`, `true`} {
+		var count Count
+		assert.Error(t, json.Unmarshal([]byte(input), &count), input)
+	}
+}
+
+func TestRepositoryDownloadCount_LargeTotal(t *testing.T) {
+	// 超过2^31的下载量在所有平台上都不会溢出
+	var downloadCount RepositoryDownloadCount
+	err := json.Unmarshal([]byte(`{"total": 187654321098}`), &downloadCount)
+	assert.NoError(t, err)
+	assert.Equal(t, Count(187654321098), downloadCount.TotalDownloads)
+	assert.Greater(t, int64(downloadCount.TotalDownloads), int64(1)<<31)
+
+	// 字符串形式的数字也能解析，重新序列化后输出为数字
+	var versionDownloadCount VersionDownloadCount
+	err = json.Unmarshal([]byte(`{"version_downloads": 54428, "total_downloads": "3000000000"}`), &versionDownloadCount)
+	assert.NoError(t, err)
+	assert.Equal(t, Count(3000000000), versionDownloadCount.TotalDownloads)
+
+	data, err := json.Marshal(versionDownloadCount)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"version_downloads": 54428, "total_downloads": 3000000000}`, string(data))
+}
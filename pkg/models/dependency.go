@@ -1,5 +1,11 @@
 package models
 
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
 // DependencyInfo 用于/api/v1/dependencies接口
 // 参考: https://guides.rubygems.org/rubygems-org-api-v2/#dependencies
 type DependencyInfo struct {
@@ -13,5 +19,93 @@ type DependencyInfo struct {
 	Requirements string `json:"requirements"`
 
 	// 依赖类型，常见值: "runtime", "development"
-	DependentType string `json:"dependent_type"`
+	DependentType DependencyType `json:"dependent_type"`
+}
+
+// DependencyType 表示依赖的类型
+type DependencyType string
+
+const (
+	// DependencyTypeRuntime 运行时依赖
+	DependencyTypeRuntime DependencyType = "runtime"
+
+	// DependencyTypeDevelopment 开发依赖
+	DependencyTypeDevelopment DependencyType = "development"
+)
+
+// ParseDependencyType 解析依赖类型，忽略大小写和首尾空白，不是已知类型时返回错误
+func ParseDependencyType(s string) (DependencyType, error) {
+	switch t := DependencyType(strings.ToLower(strings.TrimSpace(s))); t {
+	case DependencyTypeRuntime, DependencyTypeDevelopment:
+		return t, nil
+	default:
+		return DependencyType(s), fmt.Errorf("unknown dependency type: %q", s)
+	}
+}
+
+// Valid 判断是否为已知的依赖类型
+func (t DependencyType) Valid() bool {
+	return t == DependencyTypeRuntime || t == DependencyTypeDevelopment
+}
+
+// IsRuntime 判断是否为运行时依赖
+func (t DependencyType) IsRuntime() bool {
+	return t == DependencyTypeRuntime
+}
+
+// IsDevelopment 判断是否为开发依赖
+func (t DependencyType) IsDevelopment() bool {
+	return t == DependencyTypeDevelopment
+}
+
+// UnmarshalJSON 解析依赖类型，已知类型统一为小写，未知的值原样保留而不是报错，null解析为空字符串
+func (t *DependencyType) UnmarshalJSON(data []byte) error {
+	var s *string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == nil {
+		*t = ""
+		return nil
+	}
+	*t, _ = ParseDependencyType(*s)
+	return nil
+}
+
+// ParsedRequirements 把版本要求解析为约束列表
+// 复合要求（例如 ">= 3.0.0, < 4.0"）会被拆分为多个约束，空的版本要求解析为 ">= 0"
+func (d *DependencyInfo) ParsedRequirements() ([]*Constraint, error) {
+	requirement, err := ParseRequirement(d.Requirements)
+	if err != nil {
+		return nil, err
+	}
+	return requirement.Constraints, nil
+}
+
+// DependencyNode 表示依赖树中的一个节点
+// 每条依赖边对应一个节点，同一个gem的同一个版本在不同位置出现时共享同一个Children切片
+type DependencyNode struct {
+	// 包名
+	Name string `json:"name"`
+
+	// 父节点对这个依赖声明的版本要求，根节点为空
+	Requirement string `json:"requirement,omitempty"`
+
+	// 解析出的具体版本
+	Version string `json:"version"`
+
+	// 这个版本的运行时依赖
+	Children []*DependencyNode `json:"children,omitempty"`
+}
+
+// Cycle 表示一条循环依赖，按依赖方向列出组成循环的包名，最后一个包又依赖第一个包
+// 例如 a -> b -> a 表示为 ["a", "b"]，列表总是从字典序最小的包名开始
+type Cycle []string
+
+// String 返回循环依赖的可读形式，例如 "a -> b -> a"
+func (c Cycle) String() string {
+	if len(c) == 0 {
+		return ""
+	}
+	return strings.Join(append(append([]string{}, c...), c[0]), " -> ")
 }
@@ -50,7 +50,7 @@ func TestDependencyInfo_JsonUnmarshal(t *testing.T) {
 	assert.Equal(t, "rails", dep.Name)
 	assert.Equal(t, "activerecord", dep.DependentName)
 	assert.Equal(t, ">= 5.0.0", dep.Requirements)
-	assert.Equal(t, "runtime", dep.DependentType)
+	assert.Equal(t, DependencyTypeRuntime, dep.DependentType)
 }
 
 func TestDependency_MarshalUnmarshal(t *testing.T) {
@@ -114,3 +114,66 @@ func TestDependencies_MarshalUnmarshal(t *testing.T) {
 	assert.Equal(t, deps.Runtime[0].Name, unmarshaledDeps.Runtime[0].Name)
 	assert.Equal(t, deps.Runtime[1].Requirements, unmarshaledDeps.Runtime[1].Requirements)
 }
+
+func TestDependencyInfo_ParsedRequirements(t *testing.T) {
+	single := &DependencyInfo{Name: "rails", DependentName: "rack", Requirements: "~> 2.2"}
+	constraints, err := single.ParsedRequirements()
+	assert.NoError(t, err)
+	assert.Equal(t, []*Constraint{{Operator: OperatorPessimistic, Version: "2.2"}}, constraints)
+
+	compound := &DependencyInfo{Name: "rails", DependentName: "rack", Requirements: ">= 3.0.0, < 4.0"}
+	constraints, err = compound.ParsedRequirements()
+	assert.NoError(t, err)
+	assert.Equal(t, []*Constraint{
+		{Operator: OperatorGreaterOrEqual, Version: "3.0.0"},
+		{Operator: OperatorLess, Version: "4.0"},
+	}, constraints)
+
+	empty := &DependencyInfo{Name: "rails", DependentName: "rack"}
+	constraints, err = empty.ParsedRequirements()
+	assert.NoError(t, err)
+	assert.Equal(t, []*Constraint{{Operator: OperatorGreaterOrEqual, Version: "0"}}, constraints)
+
+	invalid := &DependencyInfo{Name: "rails", DependentName: "rack", Requirements: ">= 1.0, whatever"}
+	_, err = invalid.ParsedRequirements()
+	assert.Error(t, err)
+}
+
+func TestDependencyType(t *testing.T) {
+	dependencyType, err := ParseDependencyType(" Development ")
+	assert.NoError(t, err)
+	assert.Equal(t, DependencyTypeDevelopment, dependencyType)
+	assert.True(t, dependencyType.IsDevelopment())
+	assert.False(t, dependencyType.IsRuntime())
+
+	dependencyType, err = ParseDependencyType("optional")
+	assert.Error(t, err)
+	assert.Equal(t, DependencyType("optional"), dependencyType)
+	assert.False(t, dependencyType.Valid())
+
+	// 已知类型统一为小写，未知类型原样保留
+	var deps []*DependencyInfo
+	err = json.Unmarshal([]byte(`[
+		{"name": "rails", "dependent_type": "RUNTIME"},
+		{"name": "rails", "dependent_type": "development"},
+		{"name": "rails", "dependent_type": "optional"},
+		{"name": "rails", "dependent_type": null},
+		{"name": "rails"}
+	]`), &deps)
+	assert.NoError(t, err)
+	if assert.Len(t, deps, 5) {
+		assert.True(t, deps[0].DependentType.IsRuntime())
+		assert.True(t, deps[1].DependentType.IsDevelopment())
+		assert.Equal(t, DependencyType("optional"), deps[2].DependentType)
+		assert.Equal(t, DependencyType(""), deps[3].DependentType)
+		assert.False(t, deps[4].DependentType.Valid())
+	}
+
+	// 序列化后仍然是普通字符串
+	data, err := json.Marshal(deps[0])
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"dependent_type":"runtime"`)
+
+	err = json.Unmarshal([]byte(`{"dependent_type": 1}`), &DependencyInfo{})
+	assert.Error(t, err)
+}
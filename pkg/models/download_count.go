@@ -1,10 +1,10 @@
 package models
 
 type RepositoryDownloadCount struct {
-	TotalDownloads int `json:"total"`
+	TotalDownloads Count `json:"total"`
 }
 
 type VersionDownloadCount struct {
-	VersionDownloads int `json:"version_downloads"`
-	TotalDownloads   int `json:"total_downloads"`
+	VersionDownloads int   `json:"version_downloads"`
+	TotalDownloads   Count `json:"total_downloads"`
 }
@@ -38,7 +38,7 @@ func TestRepositoryDownloadCount_JsonUnmarshal(t *testing.T) {
 	assert.NoError(t, err)
 
 	// Verify parsed data
-	assert.Equal(t, 436090160, downloadCount.TotalDownloads)
+	assert.Equal(t, Count(436090160), downloadCount.TotalDownloads)
 }
 
 func TestVersionDownloadCount_MarshalUnmarshal(t *testing.T) {
@@ -76,5 +76,5 @@ func TestVersionDownloadCount_JsonUnmarshal(t *testing.T) {
 
 	// Verify parsed data
 	assert.Equal(t, 54428, versionDownloadCount.VersionDownloads)
-	assert.Equal(t, 436090160, versionDownloadCount.TotalDownloads)
+	assert.Equal(t, Count(436090160), versionDownloadCount.TotalDownloads)
 }
@@ -0,0 +1,101 @@
+package models
+
+import "strings"
+
+// UnknownLicense 表示没有声明许可证，或者声明为空
+const UnknownLicense = "UNKNOWN"
+
+// spdxLicenses gem中常见的SPDX许可证标识，键为小写形式
+var spdxLicenses = map[string]string{}
+
+func init() {
+	for _, id := range []string{
+		"0BSD", "AGPL-3.0", "Apache-2.0", "Artistic-2.0", "BSD-2-Clause", "BSD-3-Clause",
+		"BSL-1.0", "CC0-1.0", "CC-BY-4.0", "EPL-1.0", "EPL-2.0", "GPL-2.0", "GPL-3.0",
+		"ISC", "LGPL-2.1", "LGPL-3.0", "MIT", "MPL-2.0", "Ruby", "Unlicense", "WTFPL", "Zlib",
+	} {
+		spdxLicenses[strings.ToLower(id)] = id
+	}
+}
+
+// licenseAliases gemspec中常见的非标准写法到SPDX标识的映射，键为小写形式
+var licenseAliases = map[string]string{
+	"mit license":                 "MIT",
+	"the mit license":             "MIT",
+	"expat":                       "MIT",
+	"apache":                      "Apache-2.0",
+	"apache 2":                    "Apache-2.0",
+	"apache 2.0":                  "Apache-2.0",
+	"apache-2":                    "Apache-2.0",
+	"apache2":                     "Apache-2.0",
+	"apache license 2.0":          "Apache-2.0",
+	"apache license, version 2.0": "Apache-2.0",
+	"apache license version 2.0":  "Apache-2.0",
+	"apache software license":     "Apache-2.0",
+	"bsd-2":                       "BSD-2-Clause",
+	"bsd 2-clause":                "BSD-2-Clause",
+	"2-clause bsd":                "BSD-2-Clause",
+	"simplified bsd":              "BSD-2-Clause",
+	"bsd-3":                       "BSD-3-Clause",
+	"bsd 3-clause":                "BSD-3-Clause",
+	"3-clause bsd":                "BSD-3-Clause",
+	"new bsd":                     "BSD-3-Clause",
+	"gpl-2":                       "GPL-2.0",
+	"gplv2":                       "GPL-2.0",
+	"gpl2":                        "GPL-2.0",
+	"gpl-2.0-only":                "GPL-2.0",
+	"gpl-3":                       "GPL-3.0",
+	"gplv3":                       "GPL-3.0",
+	"gpl3":                        "GPL-3.0",
+	"gpl-3.0-only":                "GPL-3.0",
+	"lgpl-2.1-only":               "LGPL-2.1",
+	"lgplv2.1":                    "LGPL-2.1",
+	"lgpl-3":                      "LGPL-3.0",
+	"lgplv3":                      "LGPL-3.0",
+	"lgpl-3.0-only":               "LGPL-3.0",
+	"agpl-3.0-only":               "AGPL-3.0",
+	"agplv3":                      "AGPL-3.0",
+	"mpl-2":                       "MPL-2.0",
+	"mpl 2.0":                     "MPL-2.0",
+	"ruby license":                "Ruby",
+	"ruby's":                      "Ruby",
+	"public domain":               "Unlicense",
+}
+
+// NormalizeLicense 把gemspec中声明的许可证规范化为SPDX标识
+// 大小写不同或者属于常见别名的写法会被映射到对应的标识，例如 "MIT License" => "MIT"，"Apache 2.0" => "Apache-2.0"
+// 空字符串返回UnknownLicense，无法识别的写法去掉首尾空白后原样返回
+func NormalizeLicense(license string) string {
+	license = strings.TrimSpace(license)
+	if license == "" {
+		return UnknownLicense
+	}
+
+	key := strings.ToLower(license)
+	if id, ok := spdxLicenses[key]; ok {
+		return id
+	}
+	if id, ok := licenseAliases[key]; ok {
+		return id
+	}
+	return license
+}
+
+// NormalizeLicenses 规范化一组许可证并去除重复，保持原有顺序
+// 列表为空或者只有空字符串时返回只包含UnknownLicense的列表
+func NormalizeLicenses(licenses []string) []string {
+	seen := make(map[string]bool, len(licenses))
+	normalized := make([]string, 0, len(licenses))
+	for _, license := range licenses {
+		id := NormalizeLicense(license)
+		if id == UnknownLicense || seen[id] {
+			continue
+		}
+		seen[id] = true
+		normalized = append(normalized, id)
+	}
+	if len(normalized) == 0 {
+		normalized = append(normalized, UnknownLicense)
+	}
+	return normalized
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeLicense(t *testing.T) {
+	testCases := map[string]string{
+		"MIT":            "MIT",
+		"mit":            "MIT",
+		"MIT License":    "MIT",
+		"Apache 2.0":     "Apache-2.0",
+		"apache-2.0":     "Apache-2.0",
+		"BSD-3":          "BSD-3-Clause",
+		"GPLv3":          "GPL-3.0",
+		"ruby":           "Ruby",
+		" ":              UnknownLicense,
+		"Custom License": "Custom License",
+	}
+	for license, expected := range testCases {
+		assert.Equal(t, expected, NormalizeLicense(license), license)
+	}
+}
+
+func TestNormalizeLicenses(t *testing.T) {
+	assert.Equal(t, []string{"MIT", "Ruby"}, NormalizeLicenses([]string{"MIT License", "Ruby", "mit"}))
+	assert.Equal(t, []string{UnknownLicense}, NormalizeLicenses(nil))
+	assert.Equal(t, []string{UnknownLicense}, NormalizeLicenses([]string{""}))
+}
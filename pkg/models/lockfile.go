@@ -0,0 +1,35 @@
+package models
+
+import "sort"
+
+// Lockfile 表示一次依赖解析的结果，对应Gemfile.lock中的GEM和DEPENDENCIES两部分
+type Lockfile struct {
+	// 解析出的所有gem，包括传递依赖，按包名排序
+	Specs []*LockedSpec `json:"specs"`
+
+	// 顶层依赖：包名 => Gemfile中声明的版本要求
+	Dependencies map[string]string `json:"dependencies"`
+}
+
+// LockedSpec 表示锁定到具体版本的一个gem
+type LockedSpec struct {
+	// 包名
+	Name string `json:"name"`
+
+	// 锁定的版本
+	Version string `json:"version"`
+
+	// 这个版本的运行时依赖
+	Dependencies []*Dependency `json:"dependencies,omitempty"`
+}
+
+// Spec 返回指定包名的锁定结果，不存在时返回nil
+func (l *Lockfile) Spec(name string) *LockedSpec {
+	index := sort.Search(len(l.Specs), func(i int) bool {
+		return l.Specs[i].Name >= name
+	})
+	if index < len(l.Specs) && l.Specs[index].Name == name {
+		return l.Specs[index]
+	}
+	return nil
+}
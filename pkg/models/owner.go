@@ -0,0 +1,13 @@
+package models
+
+// Owner 是有权限向仓库推送某个gem新版本的用户
+type Owner struct {
+	// 用户ID
+	ID int `json:"id"`
+
+	// 用户名
+	Handle string `json:"handle"`
+
+	// 公开的邮箱，大多数用户没有公开邮箱，接口返回null，这里为空字符串
+	Email string `json:"email"`
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // PackageInformation
 // Example:
@@ -96,7 +99,7 @@ import "time"
 //}
 type PackageInformation struct {
 	Name             string       `json:"name"`
-	Downloads        int          `json:"downloads"`
+	Downloads        Count        `json:"downloads"`
 	Version          string       `json:"version"`
 	VersionCreatedAt time.Time    `json:"version_created_at"`
 	VersionDownloads int          `json:"version_downloads"`
@@ -129,3 +132,80 @@ type Dependency struct {
 	Name         string `json:"name"`
 	Requirements string `json:"requirements"`
 }
+
+// Normalize 统一顶层和metadata中重复出现的链接字段
+// API响应中顶层的homepage_uri、source_code_uri等字段与metadata中的同名字段可能不一致，
+// 这里优先保留非空的顶层值，顶层为空时使用metadata中的值作为回退
+func (p *PackageInformation) Normalize() {
+	p.HomepageURI = firstNonEmpty(p.HomepageURI, p.Metadata.HomepageURI)
+	p.DocumentationURI = firstNonEmpty(p.DocumentationURI, p.Metadata.DocumentationURI)
+	p.MailingListURI = firstNonEmpty(p.MailingListURI, p.Metadata.MailingListURI)
+	p.SourceCodeURI = firstNonEmpty(p.SourceCodeURI, p.Metadata.SourceCodeURI)
+	p.BugTrackerURI = firstNonEmpty(p.BugTrackerURI, p.Metadata.BugTrackerURI)
+	p.ChangelogURI = firstNonEmpty(p.ChangelogURI, p.Metadata.ChangelogURI)
+
+	// wiki_uri在顶层可能是null
+	if wiki, _ := p.WikiURI.(string); strings.TrimSpace(wiki) == "" && p.Metadata.WikiURI != "" {
+		p.WikiURI = p.Metadata.WikiURI
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// ShortInfo 返回适合在表格或命令行中单行展示的简介
+// 连续的空白（包括换行）会被合并为一个空格，超过maxLen个字符时在单词边界处截断并加上省略号，
+// 省略号也计入长度。maxLen小于等于0时只合并空白不截断
+func (p *PackageInformation) ShortInfo(maxLen int) string {
+	info := strings.Join(strings.Fields(p.Info), " ")
+	runes := []rune(info)
+	if maxLen <= 0 || len(runes) <= maxLen {
+		return info
+	}
+
+	const ellipsis = "…"
+	cut := runes[:maxLen-1]
+	// 下一个字符是空格时说明正好在单词边界上，否则回退到最后一个空格
+	if runes[maxLen-1] != ' ' {
+		for i := len(cut) - 1; i > 0; i-- {
+			if cut[i] == ' ' {
+				cut = cut[:i]
+				break
+			}
+		}
+	}
+	return strings.TrimRight(string(cut), " ") + ellipsis
+}
+
+// DuplicateDependencies 返回在运行时依赖和开发依赖中总共出现了不止一次的依赖包名，按第一次出现的顺序排列
+// 同一个依赖在gemspec中声明两次（例如使用不同的版本约束，或同时作为运行时依赖和开发依赖）通常是gemspec的错误。
+// 没有重复时返回空切片
+func (p *PackageInformation) DuplicateDependencies() []string {
+	counts := make(map[string]int)
+	var order []string
+	for _, group := range [][]*Dependency{p.Dependencies.Runtime, p.Dependencies.Development} {
+		for _, dependency := range group {
+			if dependency == nil || dependency.Name == "" {
+				continue
+			}
+			if counts[dependency.Name] == 0 {
+				order = append(order, dependency.Name)
+			}
+			counts[dependency.Name]++
+		}
+	}
+
+	duplicates := make([]string, 0)
+	for _, name := range order {
+		if counts[name] > 1 {
+			duplicates = append(duplicates, name)
+		}
+	}
+	return duplicates
+}
@@ -120,7 +120,7 @@ func TestPackageInformation_JsonUnmarshal(t *testing.T) {
 
 	// Verify parsed data
 	assert.Equal(t, "rails", pkg.Name)
-	assert.Equal(t, 436090160, pkg.Downloads)
+	assert.Equal(t, Count(436090160), pkg.Downloads)
 	assert.Equal(t, "7.0.5", pkg.Version)
 	assert.Equal(t, 54428, pkg.VersionDownloads)
 	assert.Equal(t, "ruby", pkg.Platform)
@@ -131,3 +131,48 @@ func TestPackageInformation_JsonUnmarshal(t *testing.T) {
 	assert.Equal(t, "actioncable", pkg.Dependencies.Runtime[0].Name)
 	assert.Equal(t, "= 7.0.5", pkg.Dependencies.Runtime[0].Requirements)
 }
+
+func TestPackageInformation_ShortInfo(t *testing.T) {
+	pkg := &PackageInformation{Info: "Rack provides a minimal, modular and adaptable interface for developing\nweb applications in Ruby.  By wrapping HTTP requests\n\tand responses\n"}
+
+	assert.Equal(t, "Rack provides a minimal, modular and adaptable interface for developing web applications in Ruby. By wrapping HTTP requests and responses", pkg.ShortInfo(0))
+
+	// 在单词边界处截断，省略号计入长度
+	short := pkg.ShortInfo(30)
+	assert.Equal(t, "Rack provides a minimal,…", short)
+	assert.LessOrEqual(t, len([]rune(short)), 30)
+
+	// 截断位置正好是单词结尾
+	assert.Equal(t, "Rack provides…", pkg.ShortInfo(14))
+
+	// 没有空格可以回退时直接截断
+	assert.Equal(t, "Supercalifrag…", (&PackageInformation{Info: "Supercalifragilisticexpialidocious"}).ShortInfo(14))
+
+	// 不需要截断
+	assert.Equal(t, "Ruby on Rails", (&PackageInformation{Info: " Ruby\non   Rails "}).ShortInfo(20))
+	assert.Equal(t, "", (&PackageInformation{}).ShortInfo(10))
+}
+
+func TestPackageInformation_DuplicateDependencies(t *testing.T) {
+	pkg := PackageInformation{
+		Dependencies: Dependencies{
+			Runtime: []*Dependency{
+				{Name: "sprockets", Requirements: ">= 3.0"},
+				{Name: "railties", Requirements: ">= 5.2"},
+				nil,
+				{Name: "sprockets", Requirements: "< 5"},
+				{Name: "tilt", Requirements: ">= 1.1"},
+			},
+			Development: []*Dependency{
+				{Name: "railties", Requirements: ">= 5.2"},
+				{Name: "rake", Requirements: ">= 0"},
+			},
+		},
+	}
+	assert.Equal(t, []string{"sprockets", "railties"}, pkg.DuplicateDependencies())
+
+	// 没有重复时返回空切片
+	pkg = PackageInformation{Dependencies: Dependencies{Runtime: []*Dependency{{Name: "rack"}}}}
+	assert.NotNil(t, pkg.DuplicateDependencies())
+	assert.Empty(t, pkg.DuplicateDependencies())
+}
@@ -0,0 +1,10 @@
+package models
+
+// PackageSummary 是PackageInformation的精简版本，只保留名称、版本和下载量
+// 批量处理成千上万个包时，用它代替完整的包信息可以显著减少内存占用
+type PackageSummary struct {
+	Name             string `json:"name"`
+	Version          string `json:"version"`
+	Downloads        Count  `json:"downloads"`
+	VersionDownloads int    `json:"version_downloads"`
+}
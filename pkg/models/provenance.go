@@ -0,0 +1,18 @@
+package models
+
+// Provenance 表示某个gem版本的构建来源信息，来自发布时附带的sigstore证明
+// 这里只是对证明内容的解析结果，并没有校验签名和透明日志
+type Provenance struct {
+	// SignerIdentity 签名证书中的身份，通常是发布用的CI工作流，
+	// 例如 https://github.com/rails/rails/.github/workflows/release.yml@refs/tags/v7.1.0
+	SignerIdentity string `json:"signer_identity"`
+
+	// Issuer 签发签名身份的OIDC提供方，例如 https://token.actions.githubusercontent.com
+	Issuer string `json:"issuer,omitempty"`
+
+	// SourceRepository 构建所用的源码仓库地址
+	SourceRepository string `json:"source_repository,omitempty"`
+
+	// SourceCommit 构建所用的源码提交
+	SourceCommit string `json:"source_commit,omitempty"`
+}
@@ -0,0 +1,168 @@
+package models
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// 支持的版本约束操作符
+const (
+	OperatorEqual              = "="
+	OperatorNotEqual           = "!="
+	OperatorGreater            = ">"
+	OperatorLess               = "<"
+	OperatorGreaterOrEqual     = ">="
+	OperatorLessOrEqual        = "<="
+	OperatorPessimistic        = "~>"
+	defaultRequirementOperator = OperatorEqual
+)
+
+var constraintPattern = regexp.MustCompile(`^\s*(=|!=|>=|<=|~>|>|<)?\s*([0-9]+(?:[.\-]?[0-9A-Za-z]+)*)\s*$`)
+
+// Constraint 表示单个版本约束，例如 ">= 1.2.0"
+type Constraint struct {
+	Operator string
+	Version  string
+}
+
+// Requirement 表示一组同时生效的版本约束，例如 ">= 1.2, < 2.0"
+type Requirement struct {
+	Constraints []*Constraint
+}
+
+// ParseConstraint 解析单个版本约束，省略操作符时按 "=" 处理
+func ParseConstraint(s string) (*Constraint, error) {
+	matches := constraintPattern.FindStringSubmatch(s)
+	if matches == nil {
+		return nil, fmt.Errorf("invalid version constraint: %q", s)
+	}
+	operator := matches[1]
+	if operator == "" {
+		operator = defaultRequirementOperator
+	}
+	return &Constraint{Operator: operator, Version: matches[2]}, nil
+}
+
+// ParseRequirement 解析RubyGems风格的版本要求，多个约束之间用逗号分隔
+// 空字符串等价于 ">= 0"，即接受任意版本
+func ParseRequirement(s string) (*Requirement, error) {
+	requirement := &Requirement{}
+	if strings.TrimSpace(s) == "" {
+		requirement.Constraints = append(requirement.Constraints, &Constraint{Operator: OperatorGreaterOrEqual, Version: "0"})
+		return requirement, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		constraint, err := ParseConstraint(part)
+		if err != nil {
+			return nil, err
+		}
+		requirement.Constraints = append(requirement.Constraints, constraint)
+	}
+	return requirement, nil
+}
+
+// Satisfies 判断给定版本是否满足这个约束
+func (c *Constraint) Satisfies(version string) bool {
+	cmp := CompareVersions(version, c.Version)
+	switch c.Operator {
+	case OperatorEqual:
+		return cmp == 0
+	case OperatorNotEqual:
+		return cmp != 0
+	case OperatorGreater:
+		return cmp > 0
+	case OperatorLess:
+		return cmp < 0
+	case OperatorGreaterOrEqual:
+		return cmp >= 0
+	case OperatorLessOrEqual:
+		return cmp <= 0
+	case OperatorPessimistic:
+		// "~> 1.2.3" 等价于 ">= 1.2.3, < 1.3"，比较上界时忽略被检查版本的预发布部分
+		return cmp >= 0 && CompareVersions(releaseVersion(version), bumpVersion(c.Version)) < 0
+	default:
+		return false
+	}
+}
+
+// String 返回约束的规范写法，例如 ">= 1.2.0"
+func (c *Constraint) String() string {
+	return c.Operator + " " + c.Version
+}
+
+// Satisfies 判断给定版本是否同时满足所有约束
+func (r *Requirement) Satisfies(version string) bool {
+	for _, constraint := range r.Constraints {
+		if !constraint.Satisfies(version) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsPrerelease 判断约束中是否显式引用了预发布版本
+// 与RubyGems一致，只有这种情况下才应该考虑预发布版本
+func (r *Requirement) IsPrerelease() bool {
+	for _, constraint := range r.Constraints {
+		if IsPrereleaseVersion(constraint.Version) {
+			return true
+		}
+	}
+	return false
+}
+
+// String 返回用逗号连接的约束列表
+func (r *Requirement) String() string {
+	parts := make([]string, 0, len(r.Constraints))
+	for _, constraint := range r.Constraints {
+		parts = append(parts, constraint.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// releaseSegments 返回版本号中第一个字母段之前的数字段
+func releaseSegments(version string) []versionSegment {
+	segments := splitSegments(version)
+	for i, s := range segments {
+		if !s.numeric {
+			return segments[:i]
+		}
+	}
+	return segments
+}
+
+// releaseVersion 去掉版本号的预发布部分，对应Gem::Version#release
+func releaseVersion(version string) string {
+	return joinSegments(releaseSegments(version))
+}
+
+// bumpVersion 去掉最后一段并把新的最后一段加一，对应Gem::Version#bump
+// 例如 1.2.3 => 1.3，1.2 => 2，1 => 2
+func bumpVersion(version string) string {
+	segments := releaseSegments(version)
+	if len(segments) > 1 {
+		segments = segments[:len(segments)-1]
+	}
+	if len(segments) == 0 {
+		return "1"
+	}
+
+	last, ok := new(big.Int).SetString(segments[len(segments)-1].value, 10)
+	if !ok {
+		return joinSegments(segments)
+	}
+	bumped := append([]versionSegment{}, segments...)
+	bumped[len(bumped)-1] = versionSegment{value: last.Add(last, big.NewInt(1)).String(), numeric: true}
+	return joinSegments(bumped)
+}
+
+func joinSegments(segments []versionSegment) string {
+	values := make([]string, 0, len(segments))
+	for _, s := range segments {
+		values = append(values, s.value)
+	}
+	return strings.Join(values, ".")
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRequirement(t *testing.T) {
+	requirement, err := ParseRequirement(">= 1.2, < 2.0")
+	assert.NoError(t, err)
+	assert.Len(t, requirement.Constraints, 2)
+	assert.Equal(t, OperatorGreaterOrEqual, requirement.Constraints[0].Operator)
+	assert.Equal(t, "1.2", requirement.Constraints[0].Version)
+	assert.Equal(t, ">= 1.2, < 2.0", requirement.String())
+
+	// 省略操作符按 "=" 处理
+	requirement, err = ParseRequirement("7.0.5")
+	assert.NoError(t, err)
+	assert.Equal(t, "= 7.0.5", requirement.String())
+
+	// 空字符串接受任意版本
+	requirement, err = ParseRequirement("")
+	assert.NoError(t, err)
+	assert.True(t, requirement.Satisfies("0.0.1"))
+
+	_, err = ParseRequirement(">= abc")
+	assert.Error(t, err)
+}
+
+func TestRequirement_Satisfies(t *testing.T) {
+	testCases := []struct {
+		requirement string
+		version     string
+		expected    bool
+	}{
+		{"= 1.0.0", "1.0", true},
+		{"!= 1.0.0", "1.0.1", true},
+		{"> 1.0", "1.0.1", true},
+		{"< 1.0", "1.0.0.pre", true},
+		{">= 2.7.0", "2.6.10", false},
+		{"<= 2.0", "2.0.0", true},
+		{"~> 1.2.3", "1.2.9", true},
+		{"~> 1.2.3", "1.3.0", false},
+		{"~> 1.2", "1.9.0", true},
+		{"~> 1.2", "2.0.0", false},
+		{"~> 1.2", "2.0.0.pre", false},
+		{">= 1.2, < 2.0", "1.5", true},
+		{">= 1.2, < 2.0", "2.0", false},
+	}
+
+	for _, tc := range testCases {
+		requirement, err := ParseRequirement(tc.requirement)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, requirement.Satisfies(tc.version), "%q satisfies %q", tc.version, tc.requirement)
+	}
+}
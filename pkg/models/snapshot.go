@@ -0,0 +1,180 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// GemSnapshot 是某个时间点上一个gem的完整状态，包括包信息、版本列表和反向依赖
+// 快照可以直接序列化为JSON保存，之后与新的快照比较得到这段时间内的变化
+type GemSnapshot struct {
+	// 包名
+	Name string `json:"name"`
+
+	// 快照的采集时间
+	CapturedAt time.Time `json:"captured_at"`
+
+	// 包的基础信息
+	Package *PackageInformation `json:"package"`
+
+	// 所有未被撤回的版本
+	Versions []*Version `json:"versions"`
+
+	// 依赖这个gem的包名
+	ReverseDependencies []string `json:"reverse_dependencies"`
+}
+
+// FieldChange 表示一个字段从旧值变为新值
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// GemSnapshotDiff 是两个快照之间的差异，From是旧快照的采集时间，To是新快照的采集时间
+// 版本使用版本号表示，非ruby平台的版本带上平台后缀，例如 "1.15.4-x86_64-linux"
+type GemSnapshotDiff struct {
+	Name string    `json:"name"`
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+
+	// 新快照中新出现的版本
+	NewVersions []string `json:"new_versions,omitempty"`
+
+	// 旧快照中存在、新快照中消失的版本，版本列表接口不返回被撤回的版本，所以这些版本视为已撤回
+	YankedVersions []string `json:"yanked_versions,omitempty"`
+
+	// 总下载量的增量
+	DownloadsDelta int64 `json:"downloads_delta"`
+
+	// 两个快照中都存在的版本的下载量增量，没有变化的版本不记录
+	VersionDownloadsDeltas map[string]int `json:"version_downloads_deltas,omitempty"`
+
+	// 发生变化的包信息字段，例如简介、许可证和各种链接
+	MetadataChanges []*FieldChange `json:"metadata_changes,omitempty"`
+
+	// 新增和移除的反向依赖
+	AddedReverseDependencies   []string `json:"added_reverse_dependencies,omitempty"`
+	RemovedReverseDependencies []string `json:"removed_reverse_dependencies,omitempty"`
+}
+
+// HasChanges 判断两个快照之间是否有任何变化
+func (d *GemSnapshotDiff) HasChanges() bool {
+	return len(d.NewVersions) > 0 ||
+		len(d.YankedVersions) > 0 ||
+		d.DownloadsDelta != 0 ||
+		len(d.VersionDownloadsDeltas) > 0 ||
+		len(d.MetadataChanges) > 0 ||
+		len(d.AddedReverseDependencies) > 0 ||
+		len(d.RemovedReverseDependencies) > 0
+}
+
+// Diff 比较当前快照与一个更新的快照，返回从当前快照到other发生的变化
+func (s *GemSnapshot) Diff(other *GemSnapshot) *GemSnapshotDiff {
+	diff := &GemSnapshotDiff{
+		Name: s.Name,
+		From: s.CapturedAt,
+		To:   other.CapturedAt,
+	}
+
+	oldVersions := snapshotVersions(s.Versions)
+	newVersions := snapshotVersions(other.Versions)
+	for _, version := range other.Versions {
+		if version == nil {
+			continue
+		}
+		key := snapshotVersionKey(version)
+		old, ok := oldVersions[key]
+		if !ok {
+			diff.NewVersions = append(diff.NewVersions, key)
+			continue
+		}
+		if delta := version.DownloadsCount - old.DownloadsCount; delta != 0 {
+			if diff.VersionDownloadsDeltas == nil {
+				diff.VersionDownloadsDeltas = make(map[string]int)
+			}
+			diff.VersionDownloadsDeltas[key] = delta
+		}
+	}
+	for _, version := range s.Versions {
+		if version == nil {
+			continue
+		}
+		if key := snapshotVersionKey(version); newVersions[key] == nil {
+			diff.YankedVersions = append(diff.YankedVersions, key)
+		}
+	}
+
+	if s.Package != nil && other.Package != nil {
+		diff.DownloadsDelta = int64(other.Package.Downloads) - int64(s.Package.Downloads)
+		diff.MetadataChanges = packageChanges(s.Package, other.Package)
+	}
+
+	diff.AddedReverseDependencies = stringsMissingFrom(other.ReverseDependencies, s.ReverseDependencies)
+	diff.RemovedReverseDependencies = stringsMissingFrom(s.ReverseDependencies, other.ReverseDependencies)
+	return diff
+}
+
+// packageChanges 比较包信息中值得审计的字段
+func packageChanges(before, after *PackageInformation) []*FieldChange {
+	fields := []struct {
+		name          string
+		before, after string
+	}{
+		{"version", before.Version, after.Version},
+		{"info", before.Info, after.Info},
+		{"authors", before.Authors, after.Authors},
+		{"licenses", strings.Join(before.Licenses, ", "), strings.Join(after.Licenses, ", ")},
+		{"homepage_uri", before.HomepageURI, after.HomepageURI},
+		{"source_code_uri", before.SourceCodeURI, after.SourceCodeURI},
+		{"documentation_uri", before.DocumentationURI, after.DocumentationURI},
+		{"bug_tracker_uri", before.BugTrackerURI, after.BugTrackerURI},
+		{"changelog_uri", before.ChangelogURI, after.ChangelogURI},
+		{"mailing_list_uri", before.MailingListURI, after.MailingListURI},
+		{"rubygems_mfa_required", before.Metadata.RubygemsMfaRequired, after.Metadata.RubygemsMfaRequired},
+	}
+
+	var changes []*FieldChange
+	for _, field := range fields {
+		if field.before != field.after {
+			changes = append(changes, &FieldChange{Field: field.name, Old: field.before, New: field.after})
+		}
+	}
+	return changes
+}
+
+func snapshotVersions(versions []*Version) map[string]*Version {
+	byKey := make(map[string]*Version, len(versions))
+	for _, version := range versions {
+		if version != nil {
+			byKey[snapshotVersionKey(version)] = version
+		}
+	}
+	return byKey
+}
+
+// snapshotVersionKey 用版本号和平台标识一个版本，ruby平台省略平台后缀
+func snapshotVersionKey(version *Version) string {
+	if version.Platform == "" || version.Platform == "ruby" {
+		return version.Number
+	}
+	return version.Number + "-" + version.Platform
+}
+
+// stringsMissingFrom 返回values中不在others里的元素，按字典序排序
+func stringsMissingFrom(values, others []string) []string {
+	seen := make(map[string]bool, len(others))
+	for _, value := range others {
+		seen[value] = true
+	}
+	var missing []string
+	for _, value := range values {
+		if !seen[value] {
+			missing = append(missing, value)
+			seen[value] = true
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGemSnapshot_Diff(t *testing.T) {
+	before := &GemSnapshot{
+		Name:       "rack",
+		CapturedAt: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC),
+		Package: &PackageInformation{
+			Name:        "rack",
+			Version:     "3.0.7",
+			Downloads:   700000000,
+			Licenses:    []string{"MIT"},
+			HomepageURI: "https://github.com/rack/rack",
+		},
+		Versions: []*Version{
+			{Number: "3.0.7", Platform: "ruby", DownloadsCount: 4000000},
+			{Number: "3.0.6", Platform: "ruby", DownloadsCount: 900000},
+			{Number: "2.2.7", Platform: "ruby", DownloadsCount: 48000000},
+		},
+		ReverseDependencies: []string{"actionpack", "sinatra", "rack-legacy"},
+	}
+	after := &GemSnapshot{
+		Name:       "rack",
+		CapturedAt: time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC),
+		Package: &PackageInformation{
+			Name:        "rack",
+			Version:     "3.0.8",
+			Downloads:   702634115,
+			Licenses:    []string{"MIT"},
+			HomepageURI: "https://rack.github.io",
+		},
+		Versions: []*Version{
+			{Number: "3.0.8", Platform: "ruby", DownloadsCount: 21394},
+			{Number: "3.0.7", Platform: "ruby", DownloadsCount: 4321987},
+			{Number: "2.2.7", Platform: "ruby", DownloadsCount: 48000000},
+		},
+		ReverseDependencies: []string{"actionpack", "sinatra", "puma"},
+	}
+
+	diff := before.Diff(after)
+	assert.Equal(t, "rack", diff.Name)
+	assert.Equal(t, before.CapturedAt, diff.From)
+	assert.Equal(t, after.CapturedAt, diff.To)
+	assert.Equal(t, []string{"3.0.8"}, diff.NewVersions)
+	assert.Equal(t, []string{"3.0.6"}, diff.YankedVersions)
+	assert.Equal(t, int64(2634115), diff.DownloadsDelta)
+	assert.Equal(t, map[string]int{"3.0.7": 321987}, diff.VersionDownloadsDeltas)
+	assert.Equal(t, []*FieldChange{
+		{Field: "version", Old: "3.0.7", New: "3.0.8"},
+		{Field: "homepage_uri", Old: "https://github.com/rack/rack", New: "https://rack.github.io"},
+	}, diff.MetadataChanges)
+	assert.Equal(t, []string{"puma"}, diff.AddedReverseDependencies)
+	assert.Equal(t, []string{"rack-legacy"}, diff.RemovedReverseDependencies)
+	assert.True(t, diff.HasChanges())
+
+	// 与自身比较没有变化
+	assert.False(t, after.Diff(after).HasChanges())
+}
+
+func TestGemSnapshot_JSONRoundTrip(t *testing.T) {
+	snapshot := &GemSnapshot{
+		Name:                "rack",
+		CapturedAt:          time.Date(2023, 7, 1, 12, 30, 0, 0, time.UTC),
+		Package:             &PackageInformation{Name: "rack", Version: "3.0.8", Downloads: 702634115},
+		Versions:            []*Version{{Number: "3.0.8", Platform: "ruby", DownloadsCount: 21394}},
+		ReverseDependencies: []string{"puma"},
+	}
+
+	data, err := json.Marshal(snapshot)
+	assert.NoError(t, err)
+
+	var restored GemSnapshot
+	assert.NoError(t, json.Unmarshal(data, &restored))
+	assert.True(t, snapshot.CapturedAt.Equal(restored.CapturedAt))
+	assert.False(t, snapshot.Diff(&restored).HasChanges())
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimestampFormats 是解析API中时间字段时依次尝试的格式
+// 官方源返回带毫秒的RFC3339时间，部分镜像会返回Ruby的Time#to_s格式或者只有日期，
+// 遇到其它格式的镜像时可以向这个列表追加格式。没有时区信息的格式按UTC处理
+var TimestampFormats = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// ParseTimestamp 按TimestampFormats中的格式依次尝试解析时间，空字符串返回零值
+// 所有格式都无法解析时返回错误，而不是悄悄返回零值
+func ParseTimestamp(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range TimestampFormats {
+		if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported timestamp format: %q", s)
+}
+
+// flexibleTime 用ParseTimestamp反序列化时间，null保持为零值
+type flexibleTime time.Time
+
+func (t *flexibleTime) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil
+	}
+	// 时间字符串中通常没有转义字符，可以直接去掉引号，避免再调用一次json.Unmarshal
+	var s string
+	if n := len(data); n >= 2 && data[0] == '"' && data[n-1] == '"' && bytes.IndexByte(data, '\\') < 0 {
+		s = string(data[1 : n-1])
+	} else if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseTimestamp(s)
+	if err != nil {
+		return err
+	}
+	*t = flexibleTime(parsed)
+	return nil
+}
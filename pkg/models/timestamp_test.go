@@ -0,0 +1,56 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	expected := time.Date(2023, 5, 24, 19, 21, 28, 0, time.UTC)
+	cases := map[string]time.Time{
+		"2023-05-24T19:21:28Z":         expected,
+		"2023-05-24T19:21:28.229Z":     expected.Add(229 * time.Millisecond),
+		"2023-05-24T21:21:28+02:00":    expected,
+		"2023-05-24T19:21:28":          expected,
+		"2023-05-24 19:21:28 UTC":      expected,
+		"2023-05-24 19:21:28 +0000":    expected,
+		"2023-05-24":                   time.Date(2023, 5, 24, 0, 0, 0, 0, time.UTC),
+		"  2023-05-24T19:21:28.229Z  ": expected.Add(229 * time.Millisecond),
+		"":                             {},
+	}
+	for input, want := range cases {
+		got, err := ParseTimestamp(input)
+		assert.NoError(t, err, input)
+		assert.True(t, want.Equal(got), "%q: expected %v, got %v", input, want, got)
+	}
+
+	_, err := ParseTimestamp("yesterday")
+	assert.Error(t, err)
+}
+
+func TestVersion_UnmarshalTimestampFormats(t *testing.T) {
+	for _, input := range []string{
+		`"2023-05-24T19:21:28.229Z"`,
+		`"2023-05-24T19:21:28Z"`,
+		`"2023-05-24"`,
+	} {
+		var version Version
+		err := json.Unmarshal([]byte(`{"number": "7.0.5", "created_at": `+input+`, "built_at": `+input+`}`), &version)
+		assert.NoError(t, err, input)
+		assert.Equal(t, "7.0.5", version.Number)
+		assert.Equal(t, 2023, version.CreatedAt.Year(), input)
+		assert.Equal(t, 24, version.BuiltAt.Day(), input)
+	}
+
+	// null和缺失的时间保持零值
+	var version Version
+	assert.NoError(t, json.Unmarshal([]byte(`{"number": "1.0.0", "built_at": null}`), &version))
+	assert.True(t, version.BuiltAt.IsZero())
+	assert.True(t, version.CreatedAt.IsZero())
+
+	// 无法识别的格式返回错误，而不是悄悄变成零值
+	assert.Error(t, json.Unmarshal([]byte(`{"created_at": "last tuesday"}`), &version))
+}
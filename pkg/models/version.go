@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Version struct {
 	Authors         string    `json:"authors"`
@@ -21,6 +24,66 @@ type Version struct {
 	Requirements []interface{} `json:"requirements"`
 
 	Sha string `json:"sha"`
+
+	// 版本被撤回的时间和原因，单个版本的详情接口在版本被撤回时可能返回，没有撤回时为nil和空字符串
+	YankedAt     *time.Time `json:"yanked_at,omitempty"`
+	YankedReason string     `json:"yanked_reason,omitempty"`
+}
+
+// IsYanked 判断版本是否已经被撤回，依据是YankedAt或YankedReason是否有值
+func (v *Version) IsYanked() bool {
+	return v.YankedAt != nil || v.YankedReason != ""
+}
+
+// UnmarshalJSON 解析版本信息，created_at和built_at兼容TimestampFormats中的多种时间格式
+func (v *Version) UnmarshalJSON(data []byte) error {
+	var aux versionJSON
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	aux.into(v)
+	return nil
+}
+
+// UnmarshalVersions 解析版本列表，结果与json.Unmarshal到[]*Version相同
+// 逐个元素调用UnmarshalJSON时每个元素都要被扫描两次，版本列表通常有数百个元素，这里一次解析整个列表
+func UnmarshalVersions(data []byte) ([]*Version, error) {
+	var aux []*versionJSON
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return nil, err
+	}
+	if aux == nil {
+		return nil, nil
+	}
+	versions := make([]*Version, len(aux))
+	for i, a := range aux {
+		if a != nil {
+			versions[i] = new(Version)
+			a.into(versions[i])
+		}
+	}
+	return versions, nil
+}
+
+// plainVersion 与Version的字段相同但没有UnmarshalJSON方法，避免解析时递归
+type plainVersion Version
+
+// versionJSON 是版本信息的JSON形式，时间字段用flexibleTime解析，覆盖plainVersion中的同名字段
+type versionJSON struct {
+	plainVersion
+	BuiltAt   flexibleTime `json:"built_at"`
+	CreatedAt flexibleTime `json:"created_at"`
+	YankedAt  flexibleTime `json:"yanked_at"`
+}
+
+func (a *versionJSON) into(v *Version) {
+	*v = Version(a.plainVersion)
+	v.BuiltAt = time.Time(a.BuiltAt)
+	v.CreatedAt = time.Time(a.CreatedAt)
+	v.YankedAt = nil
+	if yankedAt := time.Time(a.YankedAt); !yankedAt.IsZero() {
+		v.YankedAt = &yankedAt
+	}
 }
 
 type LatestVersion struct {
@@ -0,0 +1,172 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// versionSegment 是版本号中的一个分段，要么是数字，要么是字母串
+type versionSegment struct {
+	value   string
+	numeric bool
+}
+
+// CompareVersions 按照RubyGems的Gem::Version规则比较两个版本号
+// a < b 返回-1，a == b 返回0，a > b 返回1
+// 规则要点:
+//   - 版本号按数字和字母切分成段，逐段比较，数字段按数值比较（1.10 > 1.9）
+//   - 含字母的段表示预发布版本，字母段排在任何数字段之前（1.0.0.pre < 1.0.0）
+//   - 末尾的0会被忽略（1.0 == 1.0.0）
+//   - "-" 等价于 ".pre."（1.0.0-rc1 == 1.0.0.pre.rc1）
+func CompareVersions(a, b string) int {
+	lhs := canonicalSegments(a)
+	rhs := canonicalSegments(b)
+
+	limit := len(lhs)
+	if len(rhs) > limit {
+		limit = len(rhs)
+	}
+
+	zero := versionSegment{value: "0", numeric: true}
+	for i := 0; i < limit; i++ {
+		l, r := zero, zero
+		if i < len(lhs) {
+			l = lhs[i]
+		}
+		if i < len(rhs) {
+			r = rhs[i]
+		}
+
+		if l == r {
+			continue
+		}
+		if !l.numeric && r.numeric {
+			return -1
+		}
+		if l.numeric && !r.numeric {
+			return 1
+		}
+		if l.numeric {
+			return compareNumeric(l.value, r.value)
+		}
+		return compareString(l.value, r.value)
+	}
+	return 0
+}
+
+// SortVersions 按CompareVersions把版本从新到旧原地排序
+// 版本列表中的撤回和重新发布会打乱CreatedAt的顺序，需要按版本号排序时应该使用这个函数。
+// 版本号相同的条目（例如同一版本的不同平台）保持原来的相对顺序，nil排在最后
+func SortVersions(versions []*Version) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		if versions[i] == nil || versions[j] == nil {
+			return versions[j] == nil && versions[i] != nil
+		}
+		return CompareVersions(versions[i].Number, versions[j].Number) > 0
+	})
+}
+
+// IsPrereleaseVersion 判断版本号是否为预发布版本，RubyGems中只要包含字母即视为预发布
+func IsPrereleaseVersion(version string) bool {
+	for _, r := range version {
+		if unicode.IsLetter(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitSegments 把版本号切分成数字段和字母段
+func splitSegments(version string) []versionSegment {
+	version = strings.ReplaceAll(strings.TrimSpace(version), "-", ".pre.")
+
+	segments := make([]versionSegment, 0, 4)
+	start := -1
+	numeric := false
+	flush := func(end int) {
+		if start >= 0 {
+			segments = append(segments, versionSegment{value: version[start:end], numeric: numeric})
+			start = -1
+		}
+	}
+	for i, r := range version {
+		isDigit := r >= '0' && r <= '9'
+		isLetter := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		switch {
+		case isDigit || isLetter:
+			if start >= 0 && numeric != isDigit {
+				flush(i)
+			}
+			if start < 0 {
+				start = i
+				numeric = isDigit
+			}
+		default:
+			flush(i)
+		}
+	}
+	flush(len(version))
+
+	for i := range segments {
+		if segments[i].numeric {
+			segments[i].value = trimLeadingZeros(segments[i].value)
+		}
+	}
+	return segments
+}
+
+// canonicalSegments 去掉发布部分和预发布部分各自末尾的0，与Gem::Version#canonical_segments一致
+func canonicalSegments(version string) []versionSegment {
+	segments := splitSegments(version)
+
+	stringStart := len(segments)
+	for i, s := range segments {
+		if !s.numeric {
+			stringStart = i
+			break
+		}
+	}
+
+	release := dropTrailingZeros(segments[:stringStart])
+	prerelease := dropTrailingZeros(segments[stringStart:])
+	return append(append([]versionSegment{}, release...), prerelease...)
+}
+
+func dropTrailingZeros(segments []versionSegment) []versionSegment {
+	end := len(segments)
+	for end > 0 && segments[end-1].numeric && segments[end-1].value == "0" {
+		end--
+	}
+	return segments[:end]
+}
+
+func trimLeadingZeros(s string) string {
+	s = strings.TrimLeft(s, "0")
+	if s == "" {
+		return "0"
+	}
+	return s
+}
+
+// compareNumeric 比较两个不含前导0的数字串，避免大数溢出
+func compareNumeric(a, b string) int {
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return compareString(a, b)
+}
+
+func compareString(a, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareVersions(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.0", "1.0.0", 0},
+		{"1.10", "1.9", 1},
+		{"1.9", "1.10", -1},
+		{"2.0.0", "10.0.0", -1},
+		{"1.0.0.pre", "1.0.0", -1},
+		{"1.0.0", "1.0.0.rc1", 1},
+		{"1.0.0.alpha", "1.0.0.beta", -1},
+		{"1.0.0.rc1", "1.0.0.rc2", -1},
+		{"1.0.0-rc1", "1.0.0.pre.rc1", 0},
+		{"1.0.0.a", "0.9", 1},
+		{"7.0.5", "7.0.4.3", 1},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, CompareVersions(tc.a, tc.b), "CompareVersions(%q, %q)", tc.a, tc.b)
+	}
+}
+
+func TestIsPrereleaseVersion(t *testing.T) {
+	assert.True(t, IsPrereleaseVersion("7.1.0.beta1"))
+	assert.True(t, IsPrereleaseVersion("1.0.0-rc1"))
+	assert.False(t, IsPrereleaseVersion("7.0.5"))
+}
+
+func TestSortVersions(t *testing.T) {
+	versions := []*Version{
+		{Number: "1.9.0"},
+		{Number: "1.0.0"},
+		nil,
+		{Number: "1.10.0", Platform: "ruby"},
+		{Number: "1.0.0.pre"},
+		{Number: "1.10.0", Platform: "java"},
+		{Number: "1.0.0.rc1"},
+	}
+	SortVersions(versions)
+
+	var numbers []string
+	for _, version := range versions[:6] {
+		numbers = append(numbers, version.Number)
+	}
+	assert.Equal(t, []string{"1.10.0", "1.10.0", "1.9.0", "1.0.0", "1.0.0.rc1", "1.0.0.pre"}, numbers)
+	// 相同版本号保持原来的顺序
+	assert.Equal(t, "ruby", versions[0].Platform)
+	assert.Equal(t, "java", versions[1].Platform)
+	assert.Nil(t, versions[6])
+}
@@ -135,3 +135,57 @@ func TestLatestVersion_JsonUnmarshal(t *testing.T) {
 	// Verify parsed data
 	assert.Equal(t, "7.0.5", latestVersion.Version)
 }
+
+func TestUnmarshalVersions(t *testing.T) {
+	data := []byte(`[
+		{"number": "7.1.2", "created_at": "2023-11-10T21:50:16.357Z", "built_at": null, "metadata": {"source_code_uri": "https://github.com/rails/rails"}},
+		null,
+		{"number": "0.9.0", "created_at": "2004-07-24 00:00:00 UTC", "platform": "ruby"}
+	]`)
+
+	versions, err := UnmarshalVersions(data)
+	assert.NoError(t, err)
+
+	// 结果与逐个元素调用UnmarshalJSON相同
+	var expected []*Version
+	assert.NoError(t, json.Unmarshal(data, &expected))
+	assert.Equal(t, expected, versions)
+	if assert.Len(t, versions, 3) {
+		assert.Nil(t, versions[1])
+		assert.Equal(t, time.Date(2004, 7, 24, 0, 0, 0, 0, time.UTC), versions[2].CreatedAt)
+	}
+
+	versions, err = UnmarshalVersions([]byte(`null`))
+	assert.NoError(t, err)
+	assert.Nil(t, versions)
+
+	_, err = UnmarshalVersions([]byte(`[{"created_at": "yesterday"}]`))
+	assert.Error(t, err)
+}
+
+func TestVersion_Yanked(t *testing.T) {
+	var version Version
+	err := json.Unmarshal([]byte(`{"number": "1.6.13", "yanked_at": "2019-08-19T16:02:13Z", "yanked_reason": "compromised"}`), &version)
+	assert.NoError(t, err)
+	assert.True(t, version.IsYanked())
+	if assert.NotNil(t, version.YankedAt) {
+		assert.Equal(t, time.Date(2019, 8, 19, 16, 2, 13, 0, time.UTC), version.YankedAt.UTC())
+	}
+	assert.Equal(t, "compromised", version.YankedReason)
+
+	// 重新序列化后保持不变
+	data, err := json.Marshal(&version)
+	assert.NoError(t, err)
+	var decoded Version
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, version.YankedAt.UTC(), decoded.YankedAt.UTC())
+
+	// 没有撤回的版本
+	version = Version{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"number": "2.1.0", "yanked_at": null}`), &version))
+	assert.False(t, version.IsYanked())
+	assert.Nil(t, version.YankedAt)
+	data, err = json.Marshal(&version)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "yanked")
+}
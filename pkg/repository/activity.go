@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+)
+
+// JustUpdatedActivity 获取仓库上最近更新的gem包动态，包括已有gem包发布的新版本
+// GET - /api/v1/activity/just_updated.json
+func (x *RepositoryImpl) JustUpdatedActivity(ctx context.Context) ([]*models.ActivityItem, error) {
+	targetUrl := fmt.Sprintf("%s/api/v1/activity/just_updated.json", x.options.ServerURL)
+	return getJson[[]*models.ActivityItem](ctx, x, OperationJustUpdatedGems, targetUrl)
+}
+
+// JustUpdatedGems 获取仓库上最近更新的gem包，包括已有gem包发布的新版本
+// 与LatestGems配合使用可以发现依赖的gem发布了新版本
+// GET - /api/v1/activity/just_updated.json
+func (x *RepositoryImpl) JustUpdatedGems(ctx context.Context) ([]*models.PackageInformation, error) {
+	items, err := x.JustUpdatedActivity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return activityPackages(items), nil
+}
+
+// activityPackages 把动态项转换为包信息
+func activityPackages(items []*models.ActivityItem) []*models.PackageInformation {
+	gems := make([]*models.PackageInformation, 0, len(items))
+	for _, item := range items {
+		gems = append(gems, item.AsPackageInformation())
+	}
+	return gems
+}
+
+// RecentlyActiveGems 获取最近一段时间内发布过新版本的gem包，按最近一次发布时间降序排列
+// 结果合并自latest和just_updated两个动态，同一个gem的多个版本只保留发布时间最新的那个，
+// 两个动态都只包含最近几十条发布记录，所以within很长时结果也不会覆盖整个时间段。
+// 参数:
+//   - ctx: 上下文，用于控制请求超时和取消
+//   - within: 只保留VersionCreatedAt在这个时间段之内的版本，小于等于0时不按时间过滤
+//   - limit: 最多返回的gem数量，小于等于0时不限制
+func (x *RepositoryImpl) RecentlyActiveGems(ctx context.Context, within time.Duration, limit int) ([]*models.PackageInformation, error) {
+	latest, err := x.LatestActivity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := x.JustUpdatedActivity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return recentlyActive(append(latest, updated...), time.Now(), within, limit), nil
+}
+
+// recentlyActive 对动态项去重、过滤并按发布时间排序
+func recentlyActive(items []*models.ActivityItem, now time.Time, within time.Duration, limit int) []*models.PackageInformation {
+	newest := make(map[string]*models.ActivityItem, len(items))
+	for _, item := range items {
+		if item == nil || item.Name == "" {
+			continue
+		}
+		if within > 0 && item.VersionCreatedAt.Before(now.Add(-within)) {
+			continue
+		}
+		if current, ok := newest[item.Name]; !ok || item.VersionCreatedAt.After(current.VersionCreatedAt) {
+			newest[item.Name] = item
+		}
+	}
+
+	gems := make([]*models.PackageInformation, 0, len(newest))
+	for _, item := range newest {
+		gems = append(gems, item.AsPackageInformation())
+	}
+	sort.Slice(gems, func(i, j int) bool {
+		if !gems[i].VersionCreatedAt.Equal(gems[j].VersionCreatedAt) {
+			return gems[i].VersionCreatedAt.After(gems[j].VersionCreatedAt)
+		}
+		return gems[i].Name < gems[j].Name
+	})
+
+	if limit > 0 && len(gems) > limit {
+		gems = gems[:limit]
+	}
+	return gems
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecentlyActiveGems(t *testing.T) {
+	now := time.Now().UTC()
+	at := func(ago time.Duration) string {
+		return now.Add(-ago).Format(time.RFC3339)
+	}
+
+	repo := newTestRepository(t, map[string]string{
+		"/api/v1/activity/latest.json": fmt.Sprintf(`[
+			{"name": "brand-new", "version": "0.1.0", "version_created_at": %q},
+			{"name": "ancient", "version": "1.0.0", "version_created_at": %q}
+		]`, at(3*time.Hour), at(30*24*time.Hour)),
+		"/api/v1/activity/just_updated.json": fmt.Sprintf(`[
+			{"name": "rails", "version": "7.1.1", "version_created_at": %q},
+			{"name": "rails", "version": "7.1.0", "version_created_at": %q},
+			{"name": "rack", "version": "3.0.8", "version_created_at": %q}
+		]`, at(time.Hour), at(5*time.Hour), at(2*time.Hour)),
+	})
+
+	gems, err := repo.RecentlyActiveGems(context.Background(), 24*time.Hour, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, gems, 3) {
+		assert.Equal(t, "rails", gems[0].Name)
+		// 同一个gem只保留最新发布的版本
+		assert.Equal(t, "7.1.1", gems[0].Version)
+		assert.Equal(t, "rack", gems[1].Name)
+		assert.Equal(t, "brand-new", gems[2].Name)
+	}
+
+	gems, err = repo.RecentlyActiveGems(context.Background(), 24*time.Hour, 2)
+	assert.NoError(t, err)
+	assert.Len(t, gems, 2)
+
+	// 不按时间过滤时保留已经过时的动态
+	gems, err = repo.RecentlyActiveGems(context.Background(), 0, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, gems, 4) {
+		assert.Equal(t, "ancient", gems[3].Name)
+	}
+}
+
+func TestRepository_JustUpdatedGems(t *testing.T) {
+	repo := newTestRepository(t, map[string]string{
+		"/api/v1/activity/just_updated.json": `[
+			{"name": "rack", "version": "3.0.9", "version_downloads": 12, "homepage_uri": null, "metadata": {"homepage_uri": "https://github.com/rack/rack"}},
+			{"name": "puma", "version": "6.4.2", "version_downloads": 3}
+		]`,
+	})
+
+	gems, err := repo.JustUpdatedGems(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, gems, 2) {
+		assert.Equal(t, "rack", gems[0].Name)
+		assert.Equal(t, "3.0.9", gems[0].Version)
+		assert.Equal(t, "https://github.com/rack/rack", gems[0].HomepageURI)
+		assert.Equal(t, "puma", gems[1].Name)
+	}
+
+	_, err = newTestRepository(t, nil).JustUpdatedGems(context.Background())
+	assert.Error(t, err)
+}
@@ -1,4 +0,0 @@
-package repository
-
-// This file intentionally left empty
-// The bulk operations functionality is now implemented in bulk_operations.go
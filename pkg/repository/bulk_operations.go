@@ -2,7 +2,12 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"math"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/scagogogo/rubygems-crawler/pkg/models"
 )
@@ -15,6 +20,110 @@ type BulkResult[T any] struct {
 	Error error  // 操作过程中可能发生的错误
 }
 
+// ErrorClass 表示批量结果中错误的类别，用于决定哪些失败的请求值得重试
+type ErrorClass int
+
+const (
+	// ErrorClassNone 没有错误
+	ErrorClassNone ErrorClass = iota
+
+	// ErrorClassTransient 暂时性错误，例如限流、5xx和超时，稍后重试可能成功
+	ErrorClassTransient
+
+	// ErrorClassNotFound 资源不存在，重试没有意义
+	ErrorClassNotFound
+
+	// ErrorClassUnauthorized 未授权，需要检查Token后再重试
+	ErrorClassUnauthorized
+
+	// ErrorClassOther 其它无法归类的错误
+	ErrorClassOther
+)
+
+// String 返回错误类别的名称
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorClassNone:
+		return "None"
+	case ErrorClassTransient:
+		return "Transient"
+	case ErrorClassNotFound:
+		return "NotFound"
+	case ErrorClassUnauthorized:
+		return "Unauthorized"
+	default:
+		return "Other"
+	}
+}
+
+// ClassifyError 使用IsNotFound、IsUnauthorized、IsTransient等判断函数对错误归类
+func ClassifyError(err error) ErrorClass {
+	switch {
+	case err == nil:
+		return ErrorClassNone
+	case IsNotFound(err):
+		return ErrorClassNotFound
+	case IsUnauthorized(err):
+		return ErrorClassUnauthorized
+	case IsTransient(err):
+		return ErrorClassTransient
+	default:
+		return ErrorClassOther
+	}
+}
+
+// Classify 返回这个结果中错误的类别，没有错误时返回ErrorClassNone
+func (r *BulkResult[T]) Classify() ErrorClass {
+	return ClassifyError(r.Error)
+}
+
+// RetriableKeys 返回因暂时性错误而失败的键，可以直接作为下一批次的输入
+// 顺序与结果切片相同，未被处理的空结果会被忽略
+func RetriableKeys[T any](results []*BulkResult[T]) []string {
+	keys := make([]string, 0)
+	for _, result := range results {
+		if result != nil && result.Classify() == ErrorClassTransient {
+			keys = append(keys, result.Key)
+		}
+	}
+	return keys
+}
+
+// BulkSummary 是一次批量操作的完成情况，适合在进度界面或日志中展示
+// Total = Succeeded + Failed + Aborted
+type BulkSummary struct {
+	// 结果总数
+	Total int
+
+	// 成功完成的数量
+	Succeeded int
+
+	// 请求发出后失败的数量
+	Failed int
+
+	// 因上下文取消或超时而中止的数量，包括还没来得及处理的空结果
+	Aborted int
+}
+
+// SummarizeBulk 统计批量操作结果中成功、失败和中止的数量
+// 错误为context.Canceled或context.DeadlineExceeded的结果，以及因取消或提前停止而没有被处理的空结果都计为中止
+func SummarizeBulk[T any](results []*BulkResult[T]) BulkSummary {
+	summary := BulkSummary{Total: len(results)}
+	for _, result := range results {
+		switch {
+		case result == nil:
+			summary.Aborted++
+		case result.Error == nil:
+			summary.Succeeded++
+		case errors.Is(result.Error, context.Canceled) || errors.Is(result.Error, context.DeadlineExceeded):
+			summary.Aborted++
+		default:
+			summary.Failed++
+		}
+	}
+	return summary
+}
+
 // BulkOptions 定义批量操作的配置选项
 type BulkOptions struct {
 	// MaxConcurrency 定义最大并发请求数量
@@ -27,8 +136,22 @@ type BulkOptions struct {
 	// 如果为false，遇到第一个错误时会立即停止处理
 	// 默认为true
 	ContinueOnError bool
+
+	// DependencyBatchSize 是BulkGetDependencies合并到一个请求中的包数量
+	// 依赖接口支持用逗号分隔一次查询多个包，合并之后请求数大约是包数量除以这个值
+	// 小于等于0时使用DefaultDependencyBatchSize，设置为1时每个包单独请求
+	DependencyBatchSize int
+
+	// MaxDuration 是整个批量操作的最长运行时间，小于等于0时不限制
+	// 超时后不再开始新的请求，正在进行的请求被取消，还没有完成的键的错误为ErrMaxDurationExceeded，
+	// 已经完成的结果照常返回。调用方不需要为此单独创建带超时的ctx
+	MaxDuration time.Duration
 }
 
+// DefaultDependencyBatchSize 是BulkGetDependencies默认合并到一个请求中的包数量
+// 包太多时URL和响应都会变得很大，单个请求失败的影响范围也更大
+const DefaultDependencyBatchSize = 50
+
 // NewBulkOptions 创建具有默认值的批量操作选项
 // 默认配置：最大并发数10，遇到错误时继续执行
 func NewBulkOptions() *BulkOptions {
@@ -54,6 +177,28 @@ func (o *BulkOptions) WithContinueOnError(continueOnError bool) *BulkOptions {
 	return o
 }
 
+// WithDependencyBatchSize 设置BulkGetDependencies合并到一个请求中的包数量
+// 返回选项对象自身，支持链式调用
+func (o *BulkOptions) WithDependencyBatchSize(batchSize int) *BulkOptions {
+	o.DependencyBatchSize = batchSize
+	return o
+}
+
+// WithMaxDuration 设置批量操作的最长运行时间
+// 返回选项对象自身，支持链式调用
+func (o *BulkOptions) WithMaxDuration(maxDuration time.Duration) *BulkOptions {
+	o.MaxDuration = maxDuration
+	return o
+}
+
+// dependencyBatchSize 返回实际使用的合并数量
+func (o *BulkOptions) dependencyBatchSize() int {
+	if o == nil || o.DependencyBatchSize <= 0 {
+		return DefaultDependencyBatchSize
+	}
+	return o.DependencyBatchSize
+}
+
 // BulkGetPackages 批量获取多个包的信息
 // 并发执行GetPackage请求，提高大规模数据获取效率
 // 参数:
@@ -64,46 +209,7 @@ func (o *BulkOptions) WithContinueOnError(continueOnError bool) *BulkOptions {
 // 返回:
 //   - 包含每个包请求结果的切片，顺序与输入包名相同
 func (r *RepositoryImpl) BulkGetPackages(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[*models.PackageInformation] {
-	if options == nil {
-		options = NewBulkOptions()
-	}
-
-	results := make([]*BulkResult[*models.PackageInformation], len(gemNames))
-
-	// 创建工作池
-	worker := func(wg *sync.WaitGroup, jobs <-chan int, results []*BulkResult[*models.PackageInformation]) {
-		defer wg.Done()
-
-		for i := range jobs {
-			select {
-			case <-ctx.Done():
-				// 上下文被取消，停止处理
-				results[i] = &BulkResult[*models.PackageInformation]{
-					Key:   gemNames[i],
-					Error: ctx.Err(),
-				}
-				return
-			default:
-				// 获取包信息
-				pkg, err := r.GetPackage(ctx, gemNames[i])
-				results[i] = &BulkResult[*models.PackageInformation]{
-					Key:   gemNames[i],
-					Value: pkg,
-					Error: err,
-				}
-
-				// 如果设置了遇到错误停止，并且发生了错误
-				if !options.ContinueOnError && err != nil {
-					return
-				}
-			}
-		}
-	}
-
-	// 运行工作池
-	runWorkerPool(options.MaxConcurrency, len(gemNames), results, worker)
-
-	return results
+	return bulkExecute(ctx, gemNames, options, r.GetPackage)
 }
 
 // BulkGetVersions 批量获取多个包的版本信息
@@ -116,97 +222,69 @@ func (r *RepositoryImpl) BulkGetPackages(ctx context.Context, gemNames []string,
 // 返回:
 //   - 包含每个包版本请求结果的切片，顺序与输入包名相同
 func (r *RepositoryImpl) BulkGetVersions(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[[]*models.Version] {
-	if options == nil {
-		options = NewBulkOptions()
-	}
-
-	results := make([]*BulkResult[[]*models.Version], len(gemNames))
-
-	// 创建工作池
-	worker := func(wg *sync.WaitGroup, jobs <-chan int, results []*BulkResult[[]*models.Version]) {
-		defer wg.Done()
-
-		for i := range jobs {
-			select {
-			case <-ctx.Done():
-				// 上下文被取消，停止处理
-				results[i] = &BulkResult[[]*models.Version]{
-					Key:   gemNames[i],
-					Error: ctx.Err(),
-				}
-				return
-			default:
-				// 获取版本信息
-				versions, err := r.GetGemVersions(ctx, gemNames[i])
-				results[i] = &BulkResult[[]*models.Version]{
-					Key:   gemNames[i],
-					Value: versions,
-					Error: err,
-				}
-
-				// 如果设置了遇到错误停止，并且发生了错误
-				if !options.ContinueOnError && err != nil {
-					return
-				}
-			}
-		}
-	}
-
-	// 运行工作池
-	runWorkerPool(options.MaxConcurrency, len(gemNames), results, worker)
-
-	return results
+	return bulkExecute(ctx, gemNames, options, r.GetGemVersions)
 }
 
 // BulkGetDependencies 批量获取多个包的依赖信息
-// 并发执行GetDependencies请求，提高大规模数据获取效率
+// 依赖接口支持一次查询多个包，这里把包名按options.DependencyBatchSize分组，
+// 每组只发送一个请求，再按DependencyInfo.Name把响应拆分到各个包的结果中。
+// 同一组的包共享请求的错误，某个包在响应中没有任何版本时Value为空切片
 // 参数:
 //   - ctx: 上下文，用于控制请求超时和取消
 //   - gemNames: 要获取的包名列表
-//   - options: 批量操作选项，控制并发数等
+//   - options: 批量操作选项，控制并发数和每个请求合并的包数量
 //
 // 返回:
 //   - 包含每个包依赖请求结果的切片，顺序与输入包名相同
 func (r *RepositoryImpl) BulkGetDependencies(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[[]*models.DependencyInfo] {
-	if options == nil {
-		options = NewBulkOptions()
-	}
-
-	results := make([]*BulkResult[[]*models.DependencyInfo], len(gemNames))
+	return bulkGetDependencies(ctx, gemNames, options, r.GetDependencies)
+}
 
-	// 创建工作池
-	worker := func(wg *sync.WaitGroup, jobs <-chan int, results []*BulkResult[[]*models.DependencyInfo]) {
-		defer wg.Done()
+// bulkGetDependencies 分组调用getDependencies，再把每组的响应按包名拆分为每个包的结果
+func bulkGetDependencies(ctx context.Context, gemNames []string, options *BulkOptions, getDependencies func(context.Context, ...string) ([]*models.DependencyInfo, error)) []*BulkResult[[]*models.DependencyInfo] {
+	batchSize := options.dependencyBatchSize()
+	var batches [][]string
+	for start := 0; start < len(gemNames); start += batchSize {
+		end := start + batchSize
+		if end > len(gemNames) {
+			end = len(gemNames)
+		}
+		batches = append(batches, gemNames[start:end])
+	}
 
-		for i := range jobs {
-			select {
-			case <-ctx.Done():
-				// 上下文被取消，停止处理
-				results[i] = &BulkResult[[]*models.DependencyInfo]{
-					Key:   gemNames[i],
-					Error: ctx.Err(),
-				}
-				return
-			default:
-				// 获取依赖信息
-				deps, err := r.GetDependencies(ctx, gemNames[i])
-				results[i] = &BulkResult[[]*models.DependencyInfo]{
-					Key:   gemNames[i],
-					Value: deps,
-					Error: err,
-				}
+	// 分组的键使用分组的序号，包名原样传给getDependencies，不会因为包名中的逗号被错误拆分
+	keys := make([]string, len(batches))
+	for i := range batches {
+		keys[i] = strconv.Itoa(i)
+	}
+	batchResults := bulkExecute(ctx, keys, options, func(ctx context.Context, key string) ([]*models.DependencyInfo, error) {
+		i, _ := strconv.Atoi(key)
+		return getDependencies(ctx, batches[i]...)
+	})
 
-				// 如果设置了遇到错误停止，并且发生了错误
-				if !options.ContinueOnError && err != nil {
-					return
+	results := make([]*BulkResult[[]*models.DependencyInfo], len(gemNames))
+	for i, batch := range batchResults {
+		// 停止处理后没有执行的分组保持为nil，与bulkExecute的表现一致
+		if batch == nil {
+			continue
+		}
+		byName := make(map[string][]*models.DependencyInfo)
+		for _, info := range batch.Value {
+			if info != nil {
+				byName[info.Name] = append(byName[info.Name], info)
+			}
+		}
+		for j := i * batchSize; j < len(gemNames) && j < (i+1)*batchSize; j++ {
+			result := &BulkResult[[]*models.DependencyInfo]{Key: gemNames[j], Error: batch.Error}
+			if batch.Error == nil {
+				result.Value = byName[gemNames[j]]
+				if result.Value == nil {
+					result.Value = []*models.DependencyInfo{}
 				}
 			}
+			results[j] = result
 		}
 	}
-
-	// 运行工作池
-	runWorkerPool(options.MaxConcurrency, len(gemNames), results, worker)
-
 	return results
 }
 
@@ -220,33 +298,149 @@ func (r *RepositoryImpl) BulkGetDependencies(ctx context.Context, gemNames []str
 // 返回:
 //   - 包含每个包反向依赖请求结果的切片，顺序与输入包名相同
 func (r *RepositoryImpl) BulkGetReverseDependencies(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[[]string] {
+	return bulkExecute(ctx, gemNames, options, r.GetReverseDependencies)
+}
+
+// BulkGemExists 批量判断多个包是否存在
+// 并发执行GemExists请求，适合在获取详细信息之前先校验大量候选包名
+// 参数:
+//   - ctx: 上下文，用于控制请求超时和取消
+//   - gemNames: 要检查的包名列表
+//   - options: 批量操作选项，控制并发数等
+//
+// 返回:
+//   - 包含每个包是否存在的切片，顺序与输入包名相同；包不存在时Value为false且Error为nil
+func (r *RepositoryImpl) BulkGemExists(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[bool] {
+	return bulkExecute(ctx, gemNames, options, r.GemExists)
+}
+
+// BulkSearch 批量执行多个搜索查询
+// 并发执行Search请求，所有查询使用相同的页码
+// 参数:
+//   - ctx: 上下文，用于控制请求超时和取消
+//   - queries: 要搜索的查询字符串列表
+//   - page: 页码，小于等于0时视为第一页
+//   - options: 批量操作选项，控制并发数等
+//
+// 返回:
+//   - 包含每个查询结果的切片，Key为查询字符串，顺序与输入查询相同
+func (r *RepositoryImpl) BulkSearch(ctx context.Context, queries []string, page int, options *BulkOptions) []*BulkResult[[]*models.PackageInformation] {
+	return bulkExecute(ctx, queries, options, func(ctx context.Context, query string) ([]*models.PackageInformation, error) {
+		return r.Search(ctx, query, page)
+	})
+}
+
+// DownloadAggregate 是一组gem总下载量的汇总
+type DownloadAggregate struct {
+	// 成功获取下载量的gem数量
+	Count int64
+
+	// 获取失败而被跳过的gem数量
+	Failed int64
+
+	// 下载量的总和、最大值和最小值，没有成功的gem时都为0
+	Sum int64
+	Max int64
+	Min int64
+}
+
+// Mean 返回平均下载量，没有成功的gem时返回0
+func (a *DownloadAggregate) Mean() float64 {
+	if a.Count == 0 {
+		return 0
+	}
+	return float64(a.Sum) / float64(a.Count)
+}
+
+// BulkAggregateDownloads 并发获取多个包的信息，在结果返回的同时累加总下载量，不需要保存每个包的结果再统计一遍
+// 获取失败的包会被跳过并计入Failed，不受options.ContinueOnError影响；只有ctx被取消时才返回错误，
+// 这时返回的汇总只包含取消之前已经完成的包
+func (r *RepositoryImpl) BulkAggregateDownloads(ctx context.Context, gemNames []string, options *BulkOptions) (*DownloadAggregate, error) {
+	return bulkAggregateDownloads(ctx, gemNames, options, r.GetPackage)
+}
+
+func bulkAggregateDownloads(ctx context.Context, gemNames []string, options *BulkOptions, getPackage func(context.Context, string) (*models.PackageInformation, error)) (*DownloadAggregate, error) {
+	continueOnError := NewBulkOptions()
+	if options != nil {
+		*continueOnError = *options
+	}
+	continueOnError.ContinueOnError = true
+
+	aggregate := &DownloadAggregate{Max: math.MinInt64, Min: math.MaxInt64}
+	bulkExecute(ctx, gemNames, continueOnError, func(ctx context.Context, gemName string) (struct{}, error) {
+		pkg, err := getPackage(ctx, gemName)
+		if err != nil || pkg == nil {
+			if ctx.Err() == nil {
+				atomic.AddInt64(&aggregate.Failed, 1)
+			}
+			return struct{}{}, err
+		}
+		downloads := int64(pkg.Downloads)
+		atomic.AddInt64(&aggregate.Count, 1)
+		atomic.AddInt64(&aggregate.Sum, downloads)
+		for max := atomic.LoadInt64(&aggregate.Max); downloads > max; max = atomic.LoadInt64(&aggregate.Max) {
+			if atomic.CompareAndSwapInt64(&aggregate.Max, max, downloads) {
+				break
+			}
+		}
+		for min := atomic.LoadInt64(&aggregate.Min); downloads < min; min = atomic.LoadInt64(&aggregate.Min) {
+			if atomic.CompareAndSwapInt64(&aggregate.Min, min, downloads) {
+				break
+			}
+		}
+		return struct{}{}, nil
+	})
+
+	if aggregate.Count == 0 {
+		aggregate.Max, aggregate.Min = 0, 0
+	}
+	return aggregate, ctx.Err()
+}
+
+// bulkExecute 是所有批量方法共用的实现，通过工作池对每个键并发调用fn
+// 结果切片的顺序与keys相同，options为nil时使用默认选项
+func bulkExecute[T any](ctx context.Context, keys []string, options *BulkOptions, fn func(context.Context, string) (T, error)) []*BulkResult[T] {
 	if options == nil {
 		options = NewBulkOptions()
 	}
 
-	results := make([]*BulkResult[[]string], len(gemNames))
+	// 超过MaxDuration时取消派生的ctx，正在进行的请求随之结束
+	parent := ctx
+	if options.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.MaxDuration)
+		defer cancel()
+	}
+	maxDurationExceeded := func() bool {
+		return options.MaxDuration > 0 && parent.Err() == nil && ctx.Err() != nil
+	}
+
+	results := make([]*BulkResult[T], len(keys))
+	// 因为超过MaxDuration而中止的键，只有整体的ctx已经过期时失败的键才算，单个操作自己的超时不算
+	aborted := make([]bool, len(keys))
 
 	// 创建工作池
-	worker := func(wg *sync.WaitGroup, jobs <-chan int, results []*BulkResult[[]string]) {
+	worker := func(wg *sync.WaitGroup, jobs <-chan int, results []*BulkResult[T]) {
 		defer wg.Done()
 
 		for i := range jobs {
 			select {
 			case <-ctx.Done():
 				// 上下文被取消，停止处理
-				results[i] = &BulkResult[[]string]{
-					Key:   gemNames[i],
+				results[i] = &BulkResult[T]{
+					Key:   keys[i],
 					Error: ctx.Err(),
 				}
+				aborted[i] = maxDurationExceeded()
 				return
 			default:
-				// 获取反向依赖信息
-				deps, err := r.GetReverseDependencies(ctx, gemNames[i])
-				results[i] = &BulkResult[[]string]{
-					Key:   gemNames[i],
-					Value: deps,
+				value, err := fn(ctx, keys[i])
+				results[i] = &BulkResult[T]{
+					Key:   keys[i],
+					Value: value,
 					Error: err,
 				}
+				aborted[i] = err != nil && maxDurationExceeded()
 
 				// 如果设置了遇到错误停止，并且发生了错误
 				if !options.ContinueOnError && err != nil {
@@ -257,8 +451,16 @@ func (r *RepositoryImpl) BulkGetReverseDependencies(ctx context.Context, gemName
 	}
 
 	// 运行工作池
-	runWorkerPool(options.MaxConcurrency, len(gemNames), results, worker)
+	runWorkerPool(options.MaxConcurrency, len(keys), results, worker)
 
+	// 因为超过MaxDuration而没有完成的键都标记为中止，在此之前因为Options.Timeouts等单个操作的超时失败的键保留原来的错误
+	if maxDurationExceeded() {
+		for i, result := range results {
+			if result == nil || (aborted[i] && errors.Is(result.Error, context.DeadlineExceeded)) {
+				results[i] = &BulkResult[T]{Key: keys[i], Error: ErrMaxDurationExceeded}
+			}
+		}
+	}
 	return results
 }
 
@@ -3,6 +3,12 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -90,6 +96,15 @@ func (m *mockRepository) GetPackage(ctx context.Context, gemName string) (*model
 	return pkg, nil
 }
 
+// 实现GemExists方法
+func (m *mockRepository) GemExists(ctx context.Context, gemName string) (bool, error) {
+	if err, ok := m.failOn[gemName]; ok {
+		return false, err
+	}
+	_, ok := m.mockPackages[gemName]
+	return ok, nil
+}
+
 // 实现GetGemVersions方法
 func (m *mockRepository) GetGemVersions(ctx context.Context, gemName string) ([]*models.Version, error) {
 	// 检查是否应该失败
@@ -124,6 +139,18 @@ func (m *mockRepository) GetGemLatestVersion(ctx context.Context, gemName string
 	return nil, errors.New("not implemented")
 }
 
+func (m *mockRepository) SearchByLicense(ctx context.Context, license string, page int) ([]*models.PackageInformation, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockRepository) GetGemVersion(ctx context.Context, gemName, version string) (*models.Version, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockRepository) FindVersionMatching(ctx context.Context, gemName, constraint string) (*models.Version, error) {
+	return findVersionMatching(ctx, gemName, constraint, m.GetGemVersions)
+}
+
 func (m *mockRepository) GetTimeFrameVersions(ctx context.Context, from, to time.Time) ([]*models.Version, error) {
 	return nil, errors.New("not implemented")
 }
@@ -144,51 +171,21 @@ func (m *mockRepository) LatestGems(ctx context.Context) ([]*models.PackageInfor
 	return nil, errors.New("not implemented")
 }
 
+func (m *mockRepository) JustUpdatedGems(ctx context.Context) ([]*models.PackageInformation, error) {
+	return nil, errors.New("not implemented")
+}
+
 func (m *mockRepository) GetReverseDependencies(ctx context.Context, gemName string) ([]string, error) {
 	return nil, errors.New("not implemented")
 }
 
-// 实现批量操作方法
+// 实现批量操作方法，与RepositoryImpl共用同一个工作池实现
 func (m *mockRepository) BulkGetPackages(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[*models.PackageInformation] {
-	// 只检查 options 是否为 nil，不再重新赋值
-	if options == nil {
-		options = NewBulkOptions()
-	}
-
-	results := make([]*BulkResult[*models.PackageInformation], 0, len(gemNames))
-	for _, gemName := range gemNames {
-		pkg, err := m.GetPackage(ctx, gemName)
-		results = append(results, &BulkResult[*models.PackageInformation]{
-			Key:   gemName,
-			Value: pkg,
-			Error: err,
-		})
-		if err != nil && !options.ContinueOnError {
-			break
-		}
-	}
-	return results
+	return bulkExecute(ctx, gemNames, options, m.GetPackage)
 }
 
 func (m *mockRepository) BulkGetVersions(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[[]*models.Version] {
-	// 只检查 options 是否为 nil，不再重新赋值
-	if options == nil {
-		options = NewBulkOptions()
-	}
-
-	results := make([]*BulkResult[[]*models.Version], 0, len(gemNames))
-	for _, gemName := range gemNames {
-		versions, err := m.GetGemVersions(ctx, gemName)
-		results = append(results, &BulkResult[[]*models.Version]{
-			Key:   gemName,
-			Value: versions,
-			Error: err,
-		})
-		if err != nil && !options.ContinueOnError {
-			break
-		}
-	}
-	return results
+	return bulkExecute(ctx, gemNames, options, m.GetGemVersions)
 }
 
 func (m *mockRepository) BulkGetDependencies(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[[]*models.DependencyInfo] {
@@ -199,6 +196,16 @@ func (m *mockRepository) BulkGetReverseDependencies(ctx context.Context, gemName
 	return nil
 }
 
+func (m *mockRepository) BulkGemExists(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[bool] {
+	return bulkExecute(ctx, gemNames, options, m.GemExists)
+}
+
+func (m *mockRepository) BulkSearch(ctx context.Context, queries []string, page int, options *BulkOptions) []*BulkResult[[]*models.PackageInformation] {
+	return bulkExecute(ctx, queries, options, func(ctx context.Context, query string) ([]*models.PackageInformation, error) {
+		return m.Search(ctx, query, page)
+	})
+}
+
 // 测试批量获取包信息
 func TestBulkGetPackages(t *testing.T) {
 	// 创建模拟仓库
@@ -365,4 +372,359 @@ func TestBulkOptions(t *testing.T) {
 	if options.ContinueOnError {
 		t.Errorf("设置错误处理策略后不正确，期望: %v, 实际: %v", false, options.ContinueOnError)
 	}
+
+	// 测试设置最长运行时间
+	options = NewBulkOptions().WithMaxDuration(time.Second)
+	if options.MaxDuration != time.Second {
+		t.Errorf("设置最长运行时间后不正确，期望: %v, 实际: %v", time.Second, options.MaxDuration)
+	}
+}
+
+// 测试批量操作的最长运行时间
+func TestBulkExecute_MaxDuration(t *testing.T) {
+	mockRepo := newMockRepository()
+	mockRepo.delay = 50 * time.Millisecond
+	gemNames := make([]string, 20)
+	for i := range gemNames {
+		gemNames[i] = "rails"
+	}
+
+	start := time.Now()
+	options := NewBulkOptions().WithMaxConcurrency(2).WithMaxDuration(120 * time.Millisecond)
+	results := bulkExecute(context.Background(), gemNames, options, mockRepo.GetPackage)
+	elapsed := time.Since(start)
+
+	// 顺序执行需要500ms，超时后应该很快返回
+	if elapsed > 300*time.Millisecond {
+		t.Errorf("批量操作应该在MaxDuration附近返回，实际耗时: %v", elapsed)
+	}
+	summary := SummarizeBulk(results)
+	if summary.Succeeded == 0 || summary.Succeeded >= len(gemNames) || summary.Failed != 0 || summary.Aborted != len(gemNames)-summary.Succeeded {
+		t.Errorf("统计结果不正确: %+v", summary)
+	}
+	for i, result := range results {
+		if result == nil {
+			t.Fatalf("第%d个结果为空", i)
+		}
+		if result.Key != gemNames[i] {
+			t.Errorf("第%d个结果的键不正确: %s", i, result.Key)
+		}
+		if result.Error != nil && !errors.Is(result.Error, ErrMaxDurationExceeded) {
+			t.Errorf("中止的结果应该返回ErrMaxDurationExceeded，实际: %v", result.Error)
+		}
+	}
+
+	// 没有超时时结果不受影响
+	results = bulkExecute(context.Background(), gemNames[:4], NewBulkOptions().WithMaxDuration(time.Second), mockRepo.GetPackage)
+	if summary := SummarizeBulk(results); summary.Succeeded != 4 {
+		t.Errorf("没有超时时应该全部成功: %+v", summary)
+	}
+}
+
+// 测试单个操作在MaxDuration之前自己超时时保留原来的错误，不会被标记为中止
+func TestBulkExecute_MaxDurationKeepsOperationTimeout(t *testing.T) {
+	keys := []string{"operation-timeout", "slow"}
+	options := NewBulkOptions().WithMaxConcurrency(2).WithMaxDuration(100 * time.Millisecond)
+	results := bulkExecute(context.Background(), keys, options, func(ctx context.Context, key string) (string, error) {
+		if key == "operation-timeout" {
+			// 模拟Options.Timeouts配置的单个操作超时
+			opCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+			defer cancel()
+			<-opCtx.Done()
+			return "", opCtx.Err()
+		}
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+
+	if errors.Is(results[0].Error, ErrMaxDurationExceeded) || !errors.Is(results[0].Error, context.DeadlineExceeded) {
+		t.Errorf("单个操作的超时不应该被标记为中止，实际: %v", results[0].Error)
+	}
+	if !errors.Is(results[1].Error, ErrMaxDurationExceeded) {
+		t.Errorf("超过MaxDuration的结果应该返回ErrMaxDurationExceeded，实际: %v", results[1].Error)
+	}
+}
+
+// 测试批量结果的顺序与输入一致
+func TestBulkExecute_PreservesOrder(t *testing.T) {
+	keys := []string{"a", "b", "c", "d", "e"}
+	results := bulkExecute(context.Background(), keys, NewBulkOptions().WithMaxConcurrency(3), func(ctx context.Context, key string) (string, error) {
+		if key == "c" {
+			return "", errors.New("boom")
+		}
+		return key + key, nil
+	})
+
+	if len(results) != len(keys) {
+		t.Fatalf("结果数量不符合预期，期望: %d, 实际: %d", len(keys), len(results))
+	}
+	for i, result := range results {
+		if result.Key != keys[i] {
+			t.Errorf("结果顺序不正确，位置%d期望: %s, 实际: %s", i, keys[i], result.Key)
+		}
+	}
+	if results[2].Error == nil {
+		t.Errorf("期望c返回错误")
+	}
+	if results[4].Value != "ee" {
+		t.Errorf("结果值不正确，期望: %s, 实际: %s", "ee", results[4].Value)
+	}
+}
+
+// 测试批量搜索
+func TestBulkSearch(t *testing.T) {
+	repo := newTestRepository(t, map[string]string{
+		"/api/v1/search.json?query=rails&page=1": `[{"name": "rails"}, {"name": "railties"}]`,
+		"/api/v1/search.json?query=rack&page=1":  `[{"name": "rack"}]`,
+	})
+
+	results := repo.BulkSearch(context.Background(), []string{"rails", "rack", "missing"}, 1, NewBulkOptions().WithMaxConcurrency(2))
+	if len(results) != 3 {
+		t.Fatalf("结果数量不符合预期，期望: %d, 实际: %d", 3, len(results))
+	}
+
+	if results[0].Key != "rails" || results[0].Error != nil || len(results[0].Value) != 2 {
+		t.Errorf("rails的搜索结果不正确: %+v", results[0])
+	}
+	if results[1].Key != "rack" || results[1].Error != nil || len(results[1].Value) != 1 {
+		t.Errorf("rack的搜索结果不正确: %+v", results[1])
+	}
+	if results[2].Key != "missing" || results[2].Error == nil {
+		t.Errorf("期望missing查询返回错误: %+v", results[2])
+	}
+}
+
+// 测试批量检查包是否存在
+func TestBulkGemExists(t *testing.T) {
+	repo := newTestRepository(t, map[string]string{
+		"/api/v1/gems/rails.json": `{"name": "rails"}`,
+		"/api/v1/gems/rack.json":  `{"name": "rack"}`,
+	})
+
+	gemNames := []string{"rails", "not-a-gem", "rack", "also-missing"}
+	results := repo.BulkGemExists(context.Background(), gemNames, NewBulkOptions().WithMaxConcurrency(2))
+	if len(results) != len(gemNames) {
+		t.Fatalf("结果数量不符合预期，期望: %d, 实际: %d", len(gemNames), len(results))
+	}
+
+	expected := []bool{true, false, true, false}
+	for i, result := range results {
+		if result.Key != gemNames[i] || result.Error != nil || result.Value != expected[i] {
+			t.Errorf("%s的检查结果不正确，期望存在: %v, 实际: %+v", gemNames[i], expected[i], result)
+		}
+	}
+}
+
+// 测试批量获取依赖时多个包合并为一个请求
+func TestBulkGetDependencies_Coalesced(t *testing.T) {
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		var entries []string
+		for _, name := range strings.Split(r.URL.Query().Get("gems"), ",") {
+			// 响应中没有unknown开头的包，模拟不存在的gem
+			if strings.HasPrefix(name, "unknown") {
+				continue
+			}
+			for _, number := range []string{"1.0.0", "2.0.0"} {
+				entries = append(entries, fmt.Sprintf(`{"name": %q, "number": %q, "platform": "ruby", "dependencies": []}`, name, number))
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("[" + strings.Join(entries, ",") + "]"))
+	}))
+	defer server.Close()
+	repo := NewRepository(NewOptions().SetServerURL(server.URL).DisableRetry())
+
+	var gemNames []string
+	for i := 0; i < 120; i++ {
+		gemNames = append(gemNames, fmt.Sprintf("gem-%03d", i))
+	}
+	gemNames = append(gemNames, "unknown-gem")
+
+	results := repo.BulkGetDependencies(context.Background(), gemNames, NewBulkOptions().WithDependencyBatchSize(50))
+	if len(results) != len(gemNames) {
+		t.Fatalf("结果数量不符合预期，期望: %d, 实际: %d", len(gemNames), len(results))
+	}
+	// 121个包按50个一组只需要3个请求
+	if got := atomic.LoadInt64(&requests); got != 3 {
+		t.Errorf("期望发送3个请求，实际: %d", got)
+	}
+	for i, result := range results[:120] {
+		if result.Key != gemNames[i] || result.Error != nil || len(result.Value) != 2 {
+			t.Fatalf("%s的结果不正确: %+v", gemNames[i], result)
+		}
+		for _, info := range result.Value {
+			if info.Name != gemNames[i] {
+				t.Errorf("%s的结果中混入了%s的依赖", gemNames[i], info.Name)
+			}
+		}
+	}
+	if last := results[120]; last.Key != "unknown-gem" || last.Error != nil || last.Value == nil || len(last.Value) != 0 {
+		t.Errorf("响应中没有的包应该返回空切片: %+v", last)
+	}
+
+	// 合并数量为1时每个包单独请求
+	atomic.StoreInt64(&requests, 0)
+	repo.BulkGetDependencies(context.Background(), gemNames[:5], NewBulkOptions().WithDependencyBatchSize(1))
+	if got := atomic.LoadInt64(&requests); got != 5 {
+		t.Errorf("期望发送5个请求，实际: %d", got)
+	}
+}
+
+// 测试包名中含有逗号时分组结果仍然对应到正确的包
+func TestBulkGetDependencies_CommaInName(t *testing.T) {
+	var batches [][]string
+	var mu sync.Mutex
+	getDependencies := func(ctx context.Context, gemNames ...string) ([]*models.DependencyInfo, error) {
+		mu.Lock()
+		batches = append(batches, gemNames)
+		mu.Unlock()
+		infos := make([]*models.DependencyInfo, 0, len(gemNames))
+		for _, name := range gemNames {
+			infos = append(infos, &models.DependencyInfo{Name: name})
+		}
+		return infos, nil
+	}
+
+	gemNames := []string{"a,b", "c", "d"}
+	results := bulkGetDependencies(context.Background(), gemNames, NewBulkOptions().WithDependencyBatchSize(2), getDependencies)
+	if len(results) != 3 {
+		t.Fatalf("结果数量不符合预期，期望: 3, 实际: %d", len(results))
+	}
+	for i, result := range results {
+		if result.Key != gemNames[i] || result.Error != nil || len(result.Value) != 1 || result.Value[0].Name != gemNames[i] {
+			t.Errorf("%s的结果不正确: %+v", gemNames[i], result)
+		}
+	}
+	for _, batch := range batches {
+		if len(batch) == 2 && (batch[0] != "a,b" || batch[1] != "c") {
+			t.Errorf("包名被错误拆分: %q", batch)
+		}
+	}
+}
+
+// 测试批量结果的错误分类
+func TestBulkResult_Classify(t *testing.T) {
+	mockRepo := newMockRepository()
+	mockRepo.delay = 0
+	mockRepo.setFailOn("gone", &APIError{StatusCode: http.StatusNotFound, Cause: ErrNotFound}).
+		setFailOn("busy", &APIError{StatusCode: http.StatusServiceUnavailable, Cause: ErrServerError}).
+		setFailOn("throttled", fmt.Errorf("search: %w", ErrRateLimited)).
+		setFailOn("secret", ErrUnauthorized).
+		setFailOn("broken", errors.New("unexpected end of JSON input"))
+
+	results := mockRepo.BulkGetPackages(context.Background(), []string{"rails", "gone", "busy", "throttled", "secret", "broken"}, nil)
+
+	expected := []ErrorClass{
+		ErrorClassNone,
+		ErrorClassNotFound,
+		ErrorClassTransient,
+		ErrorClassTransient,
+		ErrorClassUnauthorized,
+		ErrorClassOther,
+	}
+	for i, class := range expected {
+		if results[i].Classify() != class {
+			t.Errorf("%s的错误类别不正确，期望: %s, 实际: %s", results[i].Key, class, results[i].Classify())
+		}
+	}
+
+	keys := RetriableKeys(results)
+	if len(keys) != 2 || keys[0] != "busy" || keys[1] != "throttled" {
+		t.Errorf("可重试的键不正确: %v", keys)
+	}
+}
+
+func TestSummarizeBulk(t *testing.T) {
+	results := []*BulkResult[string]{
+		{Key: "rails", Value: "7.1.2"},
+		{Key: "rack", Value: "3.0.8"},
+		{Key: "gone", Error: &APIError{StatusCode: http.StatusNotFound, Cause: ErrNotFound}},
+		{Key: "slow", Error: fmt.Errorf("get package: %w", context.DeadlineExceeded)},
+		{Key: "cancelled", Error: context.Canceled},
+		// 取消后没有被处理的结果
+		nil,
+	}
+
+	summary := SummarizeBulk(results)
+	expected := BulkSummary{Total: 6, Succeeded: 2, Failed: 1, Aborted: 3}
+	if summary != expected {
+		t.Errorf("统计结果不正确，期望: %+v, 实际: %+v", expected, summary)
+	}
+
+	if summary := SummarizeBulk[string](nil); summary != (BulkSummary{}) {
+		t.Errorf("空结果的统计应为零值，实际: %+v", summary)
+	}
+
+	// 取消的批量操作
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cancelled := bulkExecute(ctx, []string{"a", "b", "c"}, NewBulkOptions().WithMaxConcurrency(1), func(ctx context.Context, key string) (string, error) {
+		return key, nil
+	})
+	summary = SummarizeBulk(cancelled)
+	if summary.Total != 3 || summary.Aborted != 3 || summary.Succeeded != 0 {
+		t.Errorf("取消后的统计结果不正确: %+v", summary)
+	}
+}
+
+// 测试批量汇总下载量
+func TestBulkAggregateDownloads(t *testing.T) {
+	mockRepo := newMockRepository()
+	mockRepo.delay = 0
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("gem-%02d", i)
+		mockRepo.mockPackages[name] = &models.PackageInformation{Name: name, Downloads: models.Count((i*7919)%1000 + 1)}
+	}
+	mockRepo.setFailOn("gem-13", errors.New("request failed"))
+
+	gemNames := []string{"missing"}
+	for name := range mockRepo.mockPackages {
+		gemNames = append(gemNames, name)
+	}
+
+	// 逐个计算期望的结果
+	var count, sum, max, min int64
+	min = -1
+	for _, name := range gemNames {
+		pkg, err := mockRepo.GetPackage(context.Background(), name)
+		if err != nil {
+			continue
+		}
+		downloads := int64(pkg.Downloads)
+		count++
+		sum += downloads
+		if downloads > max {
+			max = downloads
+		}
+		if min < 0 || downloads < min {
+			min = downloads
+		}
+	}
+
+	// 即使ContinueOnError为false，失败的包也只是被跳过
+	options := NewBulkOptions().WithMaxConcurrency(8).WithContinueOnError(false)
+	aggregate, err := bulkAggregateDownloads(context.Background(), gemNames, options, mockRepo.GetPackage)
+	if err != nil {
+		t.Fatalf("汇总失败: %v", err)
+	}
+	if aggregate.Count != count || aggregate.Sum != sum || aggregate.Max != max || aggregate.Min != min {
+		t.Errorf("汇总结果不正确，期望: count=%d sum=%d max=%d min=%d, 实际: %+v", count, sum, max, min, aggregate)
+	}
+	if aggregate.Failed != 2 {
+		t.Errorf("期望2个包失败，实际: %d", aggregate.Failed)
+	}
+	if got, want := aggregate.Mean(), float64(sum)/float64(count); got != want {
+		t.Errorf("平均值不正确，期望: %f, 实际: %f", want, got)
+	}
+	if options.ContinueOnError {
+		t.Errorf("不应该修改调用方的选项")
+	}
+
+	// 全部失败时最大值和最小值为0
+	aggregate, err = bulkAggregateDownloads(context.Background(), []string{"missing"}, nil, mockRepo.GetPackage)
+	if err != nil || aggregate.Count != 0 || aggregate.Failed != 1 || aggregate.Max != 0 || aggregate.Min != 0 || aggregate.Mean() != 0 {
+		t.Errorf("全部失败时的汇总不正确: %+v, %v", aggregate, err)
+	}
 }
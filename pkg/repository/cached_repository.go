@@ -1,9 +1,15 @@
 package repository
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/scagogogo/rubygems-crawler/pkg/cache"
@@ -16,18 +22,42 @@ const (
 
 	// DefaultCleanupInterval 默认清理间隔 (1小时)
 	DefaultCleanupInterval = 1 * time.Hour
+
+	// DefaultEmptySearchTTL 空搜索结果默认的缓存时间 (30秒)
+	DefaultEmptySearchTTL = 30 * time.Second
+
+	// DefaultPrefetchConcurrency 预取依赖时默认的最大并发请求数
+	DefaultPrefetchConcurrency = 4
 )
 
 // CachedRepository 是带缓存功能的仓库包装器
 // 它实现了Repository接口，可以无缝替代基础仓库
 // 通过缓存API响应数据，减少重复请求，提高性能
 type CachedRepository struct {
-	repo          Repository    // 底层仓库实现
-	defaultTTL    time.Duration // 默认缓存过期时间
-	cache         cache.Cache   // 缓存实现
-	stopCleanupCh chan struct{} // 用于停止清理协程的通道
+	repo          Repository     // 底层仓库实现
+	defaultTTL    time.Duration  // 默认缓存过期时间
+	cache         cache.Cache    // 缓存实现
+	stopCleanupCh chan struct{}  // 用于停止清理协程的通道
+	closeOnce     sync.Once      // 保证只关闭一次
+	closeErr      error          // 关闭时发生的错误
+	searchOptions *SearchOptions // 搜索查询的处理选项，为nil时查询原样使用
+
+	emptySearchTTL time.Duration // 空搜索结果的缓存时间，为0时使用默认值
+
+	prefetchDependencies bool               // 获取包信息后是否在后台预取运行时依赖的包信息
+	prefetchSem          chan struct{}      // 限制预取的并发请求数
+	prefetchWg           sync.WaitGroup     // 等待进行中的预取结束
+	prefetchCtx          context.Context    // 预取请求使用的上下文，关闭仓库时取消
+	prefetchCancel       context.CancelFunc // 取消进行中的预取
+
+	onCacheEvent func(key string, hit bool) // 每次读取缓存后的回调，为nil时不调用
+
+	dependencyKeysMu sync.Mutex                     // 保护dependencyKeys
+	dependencyKeys   map[string]map[string]struct{} // 包名到包含它的多包依赖缓存键，用于发布或撤回后使这些键失效
 }
 
+var _ Repository = (*CachedRepository)(nil)
+
 // NewCachedRepository 创建一个新的带缓存的仓库实例
 // 参数：
 //   - repo: 底层仓库实现
@@ -39,47 +69,140 @@ func NewCachedRepository(repo Repository, ttl time.Duration, cacheImpl cache.Cac
 		cacheImpl = cache.NewMemoryCache(ttl, ttl*2)
 	}
 
+	prefetchCtx, prefetchCancel := context.WithCancel(context.Background())
 	return &CachedRepository{
-		repo:          repo,
-		defaultTTL:    ttl,
-		cache:         cacheImpl,
-		stopCleanupCh: make(chan struct{}),
+		repo:           repo,
+		defaultTTL:     ttl,
+		cache:          cacheImpl,
+		stopCleanupCh:  make(chan struct{}),
+		prefetchSem:    make(chan struct{}, DefaultPrefetchConcurrency),
+		prefetchCtx:    prefetchCtx,
+		prefetchCancel: prefetchCancel,
 	}
 }
 
 // GetPackage 通过缓存获取包信息
-// 优先从缓存获取，缓存未命中时调用底层仓库方法并缓存结果
+// 优先从缓存获取，缓存未命中时调用底层仓库方法并缓存结果。
+// 开启了依赖预取时，从底层仓库获取到包信息后会在后台预取它的运行时依赖
 func (c *CachedRepository) GetPackage(ctx context.Context, gemName string) (*models.PackageInformation, error) {
+	pkg, fetched, err := c.loadPackage(ctx, gemName)
+	if err != nil {
+		return nil, err
+	}
+	if fetched && c.prefetchDependencies {
+		c.prefetch(pkg)
+	}
+	return pkg, nil
+}
+
+// loadPackage 从缓存或底层仓库获取包信息，fetched表示是否请求了底层仓库
+func (c *CachedRepository) loadPackage(ctx context.Context, gemName string) (pkg *models.PackageInformation, fetched bool, err error) {
 	cacheKey := "package:" + gemName
 
 	// 尝试从缓存获取
-	if cachedValue, ok := c.cache.Get(cacheKey); ok {
-		if pkg, ok := cachedValue.(*models.PackageInformation); ok {
-			return pkg, nil
-		}
+	if pkg, ok := getCached[*models.PackageInformation](ctx, c, cacheKey); ok {
+		return pkg, false, nil
 	}
 
 	// 缓存未命中，调用底层仓库
-	pkg, err := c.repo.GetPackage(ctx, gemName)
+	pkg, err = c.repo.GetPackage(ctx, gemName)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	// 缓存结果
 	c.cache.SetWithExpiration(cacheKey, pkg, c.defaultTTL)
-	return pkg, nil
+	return pkg, true, nil
+}
+
+// WithOnCacheEvent 设置读取缓存后的回调，key是缓存键，hit表示是否命中缓存，未命中时接下来会请求底层仓库
+// 比完整的指标统计更轻量，可以用来记录日志或者统计某些键的命中率。批量方法和后台预取也会触发回调，
+// 所以回调可能被多个goroutine同时调用；调用回调时不持有缓存的锁，回调中可以访问缓存。
+// 传入nil时取消回调。需要在使用仓库之前设置，返回仓库自身，支持链式调用
+func (c *CachedRepository) WithOnCacheEvent(onCacheEvent func(key string, hit bool)) *CachedRepository {
+	c.onCacheEvent = onCacheEvent
+	return c
+}
+
+// WithPrefetchDependencies 设置获取包信息后是否在后台预取它的运行时依赖
+// 预取的包信息写入缓存，之后获取这些依赖时可以直接命中缓存。预取只进行一层，不会继续预取依赖的依赖，
+// 预取失败会被忽略。返回仓库自身，支持链式调用
+func (c *CachedRepository) WithPrefetchDependencies(enabled bool) *CachedRepository {
+	c.prefetchDependencies = enabled
+	return c
+}
+
+// WithPrefetchConcurrency 设置预取依赖的最大并发请求数，所有包的预取共用这个限制
+// 需要在使用仓库之前设置，小于等于0时忽略。返回仓库自身，支持链式调用
+func (c *CachedRepository) WithPrefetchConcurrency(concurrency int) *CachedRepository {
+	if concurrency > 0 {
+		c.prefetchSem = make(chan struct{}, concurrency)
+	}
+	return c
+}
+
+// prefetch 在后台获取pkg中还没有缓存的运行时依赖
+func (c *CachedRepository) prefetch(pkg *models.PackageInformation) {
+	if pkg == nil {
+		return
+	}
+	for _, dependency := range pkg.Dependencies.Runtime {
+		if dependency == nil || dependency.Name == "" {
+			continue
+		}
+		if _, ok := c.cache.Get("package:" + dependency.Name); ok {
+			continue
+		}
+		c.prefetchWg.Add(1)
+		go func(gemName string) {
+			defer c.prefetchWg.Done()
+			select {
+			case c.prefetchSem <- struct{}{}:
+				defer func() { <-c.prefetchSem }()
+			case <-c.prefetchCtx.Done():
+				return
+			}
+			_, _, _ = c.loadPackage(c.prefetchCtx, gemName)
+		}(dependency.Name)
+	}
+}
+
+// GemExists 判断包是否存在
+// 包信息已经在缓存中时直接返回true，否则交给底层仓库判断，判断结果本身不缓存，避免新发布的gem一直被当作不存在
+func (c *CachedRepository) GemExists(ctx context.Context, gemName string) (bool, error) {
+	if _, ok := getCached[*models.PackageInformation](ctx, c, "package:"+gemName); ok {
+		return true, nil
+	}
+	return c.repo.GemExists(ctx, gemName)
+}
+
+// WithSearchOptions 设置搜索查询的处理选项，例如开启查询规范化以提高缓存命中率
+// 返回仓库自身，支持链式调用
+func (c *CachedRepository) WithSearchOptions(options *SearchOptions) *CachedRepository {
+	c.searchOptions = options
+	return c
+}
+
+// WithEmptySearchTTL 设置空搜索结果的缓存时间
+// 空结果可能是真的没有匹配，也可能是被掩盖成空列表的临时故障，所以单独使用更短的缓存时间，
+// 避免之后有了结果的查询在整个缓存周期内一直返回空列表。
+// 没有设置时使用DefaultEmptySearchTTL和非空结果缓存时间中较短的一个
+// 返回仓库自身，支持链式调用
+func (c *CachedRepository) WithEmptySearchTTL(ttl time.Duration) *CachedRepository {
+	c.emptySearchTTL = ttl
+	return c
 }
 
 // Search 通过缓存执行搜索操作
 // 由于搜索结果可能随时间变化，搜索结果的缓存时间较短
+// 开启了查询规范化时，请求和缓存键都使用规范化之后的查询
 func (c *CachedRepository) Search(ctx context.Context, query string, page int) ([]*models.PackageInformation, error) {
+	query = c.searchOptions.NormalizeQuery(query)
 	cacheKey := "search:" + query + ":" + strconv.Itoa(page)
 
 	// 尝试从缓存获取
-	if cachedValue, ok := c.cache.Get(cacheKey); ok {
-		if results, ok := cachedValue.([]*models.PackageInformation); ok {
-			return results, nil
-		}
+	if results, ok := getCached[[]*models.PackageInformation](ctx, c, cacheKey); ok {
+		return results, nil
 	}
 
 	// 缓存未命中，调用底层仓库
@@ -88,21 +211,44 @@ func (c *CachedRepository) Search(ctx context.Context, query string, page int) (
 		return nil, err
 	}
 
-	// 搜索结果缓存时间较短，使用默认TTL的一半
-	c.cache.SetWithExpiration(cacheKey, results, c.defaultTTL/2)
+	// 搜索结果缓存时间较短，使用默认TTL的一半，空结果使用单独的更短的缓存时间
+	ttl := c.defaultTTL / 2
+	if len(results) == 0 {
+		ttl = c.emptySearchTTLOrDefault()
+	}
+	c.cache.SetWithExpiration(cacheKey, results, ttl)
 	return results, nil
 }
 
+// SearchByLicense 通过缓存的搜索结果过滤出声明了给定许可证的包
+// 过滤在客户端完成，缓存的是底层的搜索结果，与Search共享缓存项
+func (c *CachedRepository) SearchByLicense(ctx context.Context, license string, page int) ([]*models.PackageInformation, error) {
+	packages, err := c.Search(ctx, models.NormalizeLicense(license), page)
+	if err != nil {
+		return nil, err
+	}
+	return FilterByLicense(packages, license), nil
+}
+
+// emptySearchTTLOrDefault 返回空搜索结果的缓存时间
+func (c *CachedRepository) emptySearchTTLOrDefault() time.Duration {
+	if c.emptySearchTTL > 0 {
+		return c.emptySearchTTL
+	}
+	if positive := c.defaultTTL / 2; positive > 0 && positive < DefaultEmptySearchTTL {
+		return positive
+	}
+	return DefaultEmptySearchTTL
+}
+
 // GetGemVersions 通过缓存获取包的版本列表
 // 版本列表相对稳定，使用默认缓存时间
 func (c *CachedRepository) GetGemVersions(ctx context.Context, gemName string) ([]*models.Version, error) {
 	cacheKey := "versions:" + gemName
 
 	// 尝试从缓存获取
-	if cachedValue, ok := c.cache.Get(cacheKey); ok {
-		if versions, ok := cachedValue.([]*models.Version); ok {
-			return versions, nil
-		}
+	if versions, ok := getCached[[]*models.Version](ctx, c, cacheKey); ok {
+		return versions, nil
 	}
 
 	// 缓存未命中，调用底层仓库
@@ -121,10 +267,8 @@ func (c *CachedRepository) GetGemLatestVersion(ctx context.Context, gemName stri
 	cacheKey := "latest_version:" + gemName
 
 	// 尝试从缓存获取
-	if cachedValue, ok := c.cache.Get(cacheKey); ok {
-		if version, ok := cachedValue.(*models.LatestVersion); ok {
-			return version, nil
-		}
+	if version, ok := getCached[*models.LatestVersion](ctx, c, cacheKey); ok {
+		return version, nil
 	}
 
 	// 缓存未命中，调用底层仓库
@@ -138,16 +282,39 @@ func (c *CachedRepository) GetGemLatestVersion(ctx context.Context, gemName stri
 	return version, nil
 }
 
+// GetGemVersion 通过缓存获取包的某一个版本
+// 已发布的版本内容不会变化，使用默认缓存时间，撤回版本时对应的缓存会被删除
+func (c *CachedRepository) GetGemVersion(ctx context.Context, gemName, version string) (*models.Version, error) {
+	cacheKey := "version:" + gemName + ":" + version
+
+	// 尝试从缓存获取
+	if v, ok := getCached[*models.Version](ctx, c, cacheKey); ok {
+		return v, nil
+	}
+
+	// 缓存未命中，调用底层仓库
+	v, err := c.repo.GetGemVersion(ctx, gemName, version)
+	if err != nil {
+		return nil, err
+	}
+
+	c.cache.SetWithExpiration(cacheKey, v, c.defaultTTL)
+	return v, nil
+}
+
+// FindVersionMatching 在缓存的版本列表中查找满足约束的最新版本
+func (c *CachedRepository) FindVersionMatching(ctx context.Context, gemName, constraint string) (*models.Version, error) {
+	return findVersionMatching(ctx, gemName, constraint, c.GetGemVersions)
+}
+
 // GetTimeFrameVersions 通过缓存获取时间段内的版本
 // 时间段查询结果相对稳定，使用默认缓存时间
 func (c *CachedRepository) GetTimeFrameVersions(ctx context.Context, from, to time.Time) ([]*models.Version, error) {
 	cacheKey := "timeframe:" + from.Format(time.RFC3339) + ":" + to.Format(time.RFC3339)
 
 	// 尝试从缓存获取
-	if cachedValue, ok := c.cache.Get(cacheKey); ok {
-		if versions, ok := cachedValue.([]*models.Version); ok {
-			return versions, nil
-		}
+	if versions, ok := getCached[[]*models.Version](ctx, c, cacheKey); ok {
+		return versions, nil
 	}
 
 	// 缓存未命中，调用底层仓库
@@ -166,10 +333,8 @@ func (c *CachedRepository) Downloads(ctx context.Context) (*models.RepositoryDow
 	cacheKey := "downloads"
 
 	// 尝试从缓存获取
-	if cachedValue, ok := c.cache.Get(cacheKey); ok {
-		if downloads, ok := cachedValue.(*models.RepositoryDownloadCount); ok {
-			return downloads, nil
-		}
+	if downloads, ok := getCached[*models.RepositoryDownloadCount](ctx, c, cacheKey); ok {
+		return downloads, nil
 	}
 
 	// 缓存未命中，调用底层仓库
@@ -189,10 +354,8 @@ func (c *CachedRepository) VersionDownloads(ctx context.Context, gemName, gemVer
 	cacheKey := "version_downloads:" + gemName + ":" + gemVersion
 
 	// 尝试从缓存获取
-	if cachedValue, ok := c.cache.Get(cacheKey); ok {
-		if downloads, ok := cachedValue.(*models.VersionDownloadCount); ok {
-			return downloads, nil
-		}
+	if downloads, ok := getCached[*models.VersionDownloadCount](ctx, c, cacheKey); ok {
+		return downloads, nil
 	}
 
 	// 缓存未命中，调用底层仓库
@@ -213,10 +376,8 @@ func (c *CachedRepository) GetDependencies(ctx context.Context, gemNames ...stri
 	cacheKey := "dependencies:" + strings.Join(gemNames, ",")
 
 	// 尝试从缓存获取
-	if cachedValue, ok := c.cache.Get(cacheKey); ok {
-		if deps, ok := cachedValue.([]*models.DependencyInfo); ok {
-			return deps, nil
-		}
+	if deps, ok := getCached[[]*models.DependencyInfo](ctx, c, cacheKey); ok {
+		return deps, nil
 	}
 
 	// 缓存未命中，调用底层仓库
@@ -226,19 +387,47 @@ func (c *CachedRepository) GetDependencies(ctx context.Context, gemNames ...stri
 	}
 
 	c.cache.SetWithExpiration(cacheKey, deps, c.defaultTTL)
+	if len(gemNames) > 1 {
+		c.trackDependencyKey(cacheKey, gemNames)
+	}
 	return deps, nil
 }
 
+// trackDependencyKey 记录多包依赖缓存键包含的每个包名，单个包的键可以直接由包名得到，不需要记录
+func (c *CachedRepository) trackDependencyKey(cacheKey string, gemNames []string) {
+	c.dependencyKeysMu.Lock()
+	defer c.dependencyKeysMu.Unlock()
+	if c.dependencyKeys == nil {
+		c.dependencyKeys = make(map[string]map[string]struct{})
+	}
+	for _, gemName := range gemNames {
+		if c.dependencyKeys[gemName] == nil {
+			c.dependencyKeys[gemName] = make(map[string]struct{})
+		}
+		c.dependencyKeys[gemName][cacheKey] = struct{}{}
+	}
+}
+
+// takeDependencyKeys 取出并清除包含gemName的多包依赖缓存键
+func (c *CachedRepository) takeDependencyKeys(gemName string) []string {
+	c.dependencyKeysMu.Lock()
+	defer c.dependencyKeysMu.Unlock()
+	keys := make([]string, 0, len(c.dependencyKeys[gemName]))
+	for cacheKey := range c.dependencyKeys[gemName] {
+		keys = append(keys, cacheKey)
+	}
+	delete(c.dependencyKeys, gemName)
+	return keys
+}
+
 // LatestGems 通过缓存获取最新的gem包列表
 // 最新列表变化频繁，使用较短的缓存时间
 func (c *CachedRepository) LatestGems(ctx context.Context) ([]*models.PackageInformation, error) {
 	cacheKey := "latest_gems"
 
 	// 尝试从缓存获取
-	if cachedValue, ok := c.cache.Get(cacheKey); ok {
-		if gems, ok := cachedValue.([]*models.PackageInformation); ok {
-			return gems, nil
-		}
+	if gems, ok := getCached[[]*models.PackageInformation](ctx, c, cacheKey); ok {
+		return gems, nil
 	}
 
 	// 缓存未命中，调用底层仓库
@@ -252,16 +441,34 @@ func (c *CachedRepository) LatestGems(ctx context.Context) ([]*models.PackageInf
 	return gems, nil
 }
 
+// JustUpdatedGems 通过缓存获取最近更新的gem包列表
+// 与最新列表一样变化频繁，使用较短的缓存时间
+func (c *CachedRepository) JustUpdatedGems(ctx context.Context) ([]*models.PackageInformation, error) {
+	cacheKey := "just_updated_gems"
+
+	// 尝试从缓存获取
+	if gems, ok := getCached[[]*models.PackageInformation](ctx, c, cacheKey); ok {
+		return gems, nil
+	}
+
+	// 缓存未命中，调用底层仓库
+	gems, err := c.repo.JustUpdatedGems(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.cache.SetWithExpiration(cacheKey, gems, c.defaultTTL/4)
+	return gems, nil
+}
+
 // GetReverseDependencies 通过缓存获取包的反向依赖
 // 反向依赖相对稳定，使用默认缓存时间
 func (c *CachedRepository) GetReverseDependencies(ctx context.Context, gemName string) ([]string, error) {
 	cacheKey := "reverse_dependencies:" + gemName
 
 	// 尝试从缓存获取
-	if cachedValue, ok := c.cache.Get(cacheKey); ok {
-		if deps, ok := cachedValue.([]string); ok {
-			return deps, nil
-		}
+	if deps, ok := getCached[[]string](ctx, c, cacheKey); ok {
+		return deps, nil
 	}
 
 	// 缓存未命中，调用底层仓库
@@ -274,16 +481,92 @@ func (c *CachedRepository) GetReverseDependencies(ctx context.Context, gemName s
 	return deps, nil
 }
 
+// PushGem 通过底层仓库发布gem包，成功后使这个gem的缓存失效
+// 底层仓库没有实现Publisher时返回ErrUnsupportedOperation。
+// 无法从提示信息中识别出包名时清空整个缓存
+func (c *CachedRepository) PushGem(ctx context.Context, gem io.Reader) (string, error) {
+	publisher, ok := c.repo.(Publisher)
+	if !ok {
+		return "", ErrUnsupportedOperation
+	}
+	message, err := publisher.PushGem(ctx, gem)
+	if err != nil {
+		return "", err
+	}
+	if gemName := pushedGemName(message); gemName != "" {
+		c.invalidateGem(gemName)
+	} else {
+		c.ClearCache()
+	}
+	return message, nil
+}
+
+// YankGem 通过底层仓库撤回gem包的一个版本，成功后使这个gem的缓存失效
+// 底层仓库没有实现Publisher时返回ErrUnsupportedOperation
+func (c *CachedRepository) YankGem(ctx context.Context, gemName, version string) error {
+	publisher, ok := c.repo.(Publisher)
+	if !ok {
+		return ErrUnsupportedOperation
+	}
+	if err := publisher.YankGem(ctx, gemName, version); err != nil {
+		return err
+	}
+	c.invalidateGem(gemName)
+	c.cache.Delete("version_downloads:" + gemName + ":" + version)
+	c.cache.Delete("version:" + gemName + ":" + version)
+	return nil
+}
+
+// invalidateGem 删除与gemName相关的缓存，包括包信息、版本列表、最新版本、依赖和最新发布列表
+// 同时查询了多个包的依赖缓存只要包含gemName也会被删除。这些键记录在内存中，
+// 使用持久化缓存时，重启之前写入的多包依赖缓存不会被删除，只能等待过期
+func (c *CachedRepository) invalidateGem(gemName string) {
+	for _, cacheKey := range []string{
+		"package:" + gemName,
+		"versions:" + gemName,
+		"latest_version:" + gemName,
+		"dependencies:" + gemName,
+		"latest_gems",
+	} {
+		c.cache.Delete(cacheKey)
+	}
+	for _, cacheKey := range c.takeDependencyKeys(gemName) {
+		c.cache.Delete(cacheKey)
+	}
+}
+
 // Close 关闭缓存仓库，释放资源
-// 在仓库不再使用时应调用此方法
+// 在仓库不再使用时应调用此方法，需要知道持久化是否成功时请使用CloseWithError
 func (c *CachedRepository) Close() {
-	close(c.stopCleanupCh)
+	_ = c.CloseWithError()
+}
+
+// CloseWithError 关闭缓存仓库并返回关闭过程中的错误
+// 如果缓存实现了cache.FlushableCache，会先把数据写入后端存储，再关闭缓存。
+// 多次调用是安全的，之后的调用返回第一次关闭的结果
+func (c *CachedRepository) CloseWithError() error {
+	c.closeOnce.Do(func() {
+		// 先停止预取，避免关闭缓存之后还有预取的结果写入
+		c.prefetchCancel()
+		c.prefetchWg.Wait()
+
+		close(c.stopCleanupCh)
+		if flushable, ok := c.cache.(cache.FlushableCache); ok {
+			c.closeErr = flushable.Flush()
+		}
+		c.cache.Close()
+	})
+	return c.closeErr
 }
 
 // ClearCache 清空缓存
 // 可在需要强制刷新数据时调用
 func (c *CachedRepository) ClearCache() {
 	c.cache.Clear()
+
+	c.dependencyKeysMu.Lock()
+	c.dependencyKeys = nil
+	c.dependencyKeysMu.Unlock()
 }
 
 // GetCacheStats 获取缓存统计信息
@@ -292,22 +575,170 @@ func (c *CachedRepository) GetCacheStats() int {
 	return c.cache.Count()
 }
 
+// ImportJSONL 从每行一个PackageInformation的JSON（NDJSON）中读取包信息并写入缓存，用于用之前抓取的数据预热缓存
+// 每个包使用与GetPackage相同的缓存键和默认缓存时间，返回成功导入的数量。
+// 空行会被忽略；无法解析或没有包名的行会被跳过并继续导入，全部读取完后以*MultiError返回这些行的错误，
+// 错误中包含跳过的行数和行号。读取r失败时立即返回已导入的数量和读取错误
+func (c *CachedRepository) ImportJSONL(r io.Reader) (int, error) {
+	imported := 0
+	var malformed []error
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var pkg models.PackageInformation
+		if err := json.Unmarshal(line, &pkg); err != nil {
+			malformed = append(malformed, fmt.Errorf("line %d: %w", lineNumber, err))
+			continue
+		}
+		if pkg.Name == "" {
+			malformed = append(malformed, fmt.Errorf("line %d: missing package name", lineNumber))
+			continue
+		}
+		c.cache.SetWithExpiration("package:"+pkg.Name, &pkg, c.defaultTTL)
+		imported++
+	}
+	if err := scanner.Err(); err != nil {
+		return imported, err
+	}
+	if len(malformed) > 0 {
+		return imported, &MultiError{Errors: malformed}
+	}
+	return imported, nil
+}
+
 // BulkGetPackages implements the Repository interface
+// 已经缓存（包括预取）的包直接从缓存返回，只有未命中的包交给底层仓库的BulkGetPackages，获取成功后写入缓存
 func (c *CachedRepository) BulkGetPackages(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[*models.PackageInformation] {
-	return c.repo.BulkGetPackages(ctx, gemNames, options)
+	return cachedBulk(ctx, c, gemNames, "package:", func(missing []string) []*BulkResult[*models.PackageInformation] {
+		return c.repo.BulkGetPackages(ctx, missing, options)
+	}, func(pkg *models.PackageInformation) {
+		if c.prefetchDependencies {
+			c.prefetch(pkg)
+		}
+	})
 }
 
 // BulkGetVersions implements the Repository interface
+// 与GetGemVersions使用相同的缓存键，只有未命中的包交给底层仓库的BulkGetVersions
 func (c *CachedRepository) BulkGetVersions(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[[]*models.Version] {
-	return c.repo.BulkGetVersions(ctx, gemNames, options)
+	return cachedBulk(ctx, c, gemNames, "versions:", func(missing []string) []*BulkResult[[]*models.Version] {
+		return c.repo.BulkGetVersions(ctx, missing, options)
+	}, nil)
 }
 
 // BulkGetDependencies implements the Repository interface
+// 与单个包调用GetDependencies使用相同的缓存键，只有未命中的包交给底层仓库的BulkGetDependencies
 func (c *CachedRepository) BulkGetDependencies(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[[]*models.DependencyInfo] {
-	return c.repo.BulkGetDependencies(ctx, gemNames, options)
+	return cachedBulk(ctx, c, gemNames, "dependencies:", func(missing []string) []*BulkResult[[]*models.DependencyInfo] {
+		return c.repo.BulkGetDependencies(ctx, missing, options)
+	}, nil)
 }
 
 // BulkGetReverseDependencies implements the Repository interface
+// 与GetReverseDependencies使用相同的缓存键，只有未命中的包交给底层仓库的BulkGetReverseDependencies
 func (c *CachedRepository) BulkGetReverseDependencies(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[[]string] {
-	return c.repo.BulkGetReverseDependencies(ctx, gemNames, options)
+	return cachedBulk(ctx, c, gemNames, "reverse_dependencies:", func(missing []string) []*BulkResult[[]string] {
+		return c.repo.BulkGetReverseDependencies(ctx, missing, options)
+	}, nil)
+}
+
+// cachedBulk 先按 keyPrefix+名称 从缓存中读取每个元素，只把未命中的名称交给fetch，
+// 再把fetch的结果按位置放回，并缓存获取成功的结果；onFetched在每个新获取的值写入缓存后调用，可以为nil
+func cachedBulk[T any](ctx context.Context, c *CachedRepository, keys []string, keyPrefix string, fetch func(missing []string) []*BulkResult[T], onFetched func(T)) []*BulkResult[T] {
+	results := make([]*BulkResult[T], len(keys))
+	var missing []string
+	var missingIndexes []int
+	for i, key := range keys {
+		if value, ok := getCached[T](ctx, c, keyPrefix+key); ok {
+			results[i] = &BulkResult[T]{Key: key, Value: value}
+			continue
+		}
+		missing = append(missing, key)
+		missingIndexes = append(missingIndexes, i)
+	}
+	if len(missing) == 0 {
+		return results
+	}
+
+	for j, result := range fetch(missing) {
+		if j >= len(missingIndexes) {
+			break
+		}
+		results[missingIndexes[j]] = result
+		if result == nil || result.Error != nil {
+			continue
+		}
+		c.cache.SetWithExpiration(keyPrefix+missing[j], result.Value, c.defaultTTL)
+		if onFetched != nil {
+			onFetched(result.Value)
+		}
+	}
+	return results
+}
+
+// BulkGemExists implements the Repository interface
+// 逐个调用GemExists，已经缓存了包信息的包不需要再发送请求
+func (c *CachedRepository) BulkGemExists(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[bool] {
+	return bulkExecute(ctx, gemNames, options, c.GemExists)
+}
+
+// BulkSearch implements the Repository interface
+func (c *CachedRepository) BulkSearch(ctx context.Context, queries []string, page int, options *BulkOptions) []*BulkResult[[]*models.PackageInformation] {
+	return c.repo.BulkSearch(ctx, queries, page, options)
+}
+
+// bypassCacheKey 是WithBypassCache在上下文中使用的键
+type bypassCacheKey struct{}
+
+// WithBypassCache 返回一个跳过缓存读取的上下文
+// 使用这个上下文调用CachedRepository的方法时不读取缓存，直接请求底层仓库并用新的结果更新缓存，
+// 适合只需要刷新单次调用、不想清空整个缓存的场景，例如:
+//
+//	pkg, err := cachedRepo.GetPackage(repository.WithBypassCache(ctx), "rails")
+func WithBypassCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassCacheKey{}, true)
+}
+
+// IsBypassCache 判断上下文是否要求跳过缓存读取
+func IsBypassCache(ctx context.Context) bool {
+	bypass, _ := ctx.Value(bypassCacheKey{}).(bool)
+	return bypass
+}
+
+// getCached 从缓存中读取指定类型的值，上下文要求跳过缓存时总是视为未命中
+// 读取之后调用WithOnCacheEvent设置的回调
+func getCached[T any](ctx context.Context, c *CachedRepository, cacheKey string) (T, bool) {
+	value, hit := lookupCached[T](ctx, c, cacheKey)
+	if c.onCacheEvent != nil {
+		c.onCacheEvent(cacheKey, hit)
+	}
+	return value, hit
+}
+
+// lookupCached 是getCached的实现，持久化缓存从后端加载的值是JSON原文，这里会把它反序列化为需要的类型
+func lookupCached[T any](ctx context.Context, c *CachedRepository, cacheKey string) (T, bool) {
+	var zero T
+	if IsBypassCache(ctx) {
+		return zero, false
+	}
+	cachedValue, ok := c.cache.Get(cacheKey)
+	if !ok {
+		return zero, false
+	}
+	if value, ok := cachedValue.(T); ok {
+		return value, true
+	}
+	if raw, ok := cachedValue.(json.RawMessage); ok {
+		value, err := unmarshalJson[T](raw)
+		if err != nil {
+			return zero, false
+		}
+		return value, true
+	}
+	return zero, false
 }
@@ -2,6 +2,12 @@ package repository
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -13,7 +19,10 @@ import (
 // 模拟Repository用于测试
 type MockRepo struct {
 	calledTimes int
-	testPkg     *models.PackageInformation
+	queries     []string
+	// 按查询指定搜索结果，没有指定的查询返回testPkg
+	searchResults map[string][]*models.PackageInformation
+	testPkg       *models.PackageInformation
 }
 
 func NewMockRepo() *MockRepo {
@@ -33,9 +42,18 @@ func (m *MockRepo) GetPackage(ctx context.Context, gemName string) (*models.Pack
 	return m.testPkg, nil
 }
 
-// 为了满足Repository接口，需要实现的其他方法
 func (m *MockRepo) Search(ctx context.Context, query string, page int) ([]*models.PackageInformation, error) {
-	return nil, nil
+	m.calledTimes++
+	m.queries = append(m.queries, query)
+	if results, ok := m.searchResults[query]; ok {
+		return results, nil
+	}
+	return []*models.PackageInformation{m.testPkg}, nil
+}
+
+// 为了满足Repository接口，需要实现的其他方法
+func (m *MockRepo) GemExists(ctx context.Context, gemName string) (bool, error) {
+	return gemName == m.testPkg.Name, nil
 }
 
 func (m *MockRepo) GetGemVersions(ctx context.Context, gemName string) ([]*models.Version, error) {
@@ -46,6 +64,18 @@ func (m *MockRepo) GetGemLatestVersion(ctx context.Context, gemName string) (*mo
 	return nil, nil
 }
 
+func (m *MockRepo) SearchByLicense(ctx context.Context, license string, page int) ([]*models.PackageInformation, error) {
+	return nil, nil
+}
+
+func (m *MockRepo) GetGemVersion(ctx context.Context, gemName, version string) (*models.Version, error) {
+	return nil, nil
+}
+
+func (m *MockRepo) FindVersionMatching(ctx context.Context, gemName, constraint string) (*models.Version, error) {
+	return nil, nil
+}
+
 func (m *MockRepo) GetTimeFrameVersions(ctx context.Context, from, to time.Time) ([]*models.Version, error) {
 	return nil, nil
 }
@@ -66,6 +96,10 @@ func (m *MockRepo) LatestGems(ctx context.Context) ([]*models.PackageInformation
 	return nil, nil
 }
 
+func (m *MockRepo) JustUpdatedGems(ctx context.Context) ([]*models.PackageInformation, error) {
+	return nil, nil
+}
+
 func (m *MockRepo) GetReverseDependencies(ctx context.Context, gemName string) ([]string, error) {
 	return nil, nil
 }
@@ -87,6 +121,14 @@ func (m *MockRepo) BulkGetReverseDependencies(ctx context.Context, gemNames []st
 	return nil
 }
 
+func (m *MockRepo) BulkGemExists(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[bool] {
+	return nil
+}
+
+func (m *MockRepo) BulkSearch(ctx context.Context, queries []string, page int, options *BulkOptions) []*BulkResult[[]*models.PackageInformation] {
+	return nil
+}
+
 func TestCachedRepository(t *testing.T) {
 	ctx := context.Background()
 	mockRepo := NewMockRepo()
@@ -142,3 +184,411 @@ func TestCachedRepository(t *testing.T) {
 	cacheRepo.ClearCache()
 	cacheRepo.Close()
 }
+
+func TestCachedRepository_CloseFlushesFileCache(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "rubygems-cache.json")
+
+	fileCache, err := cache.NewFileCache(path, 10*time.Minute, 0)
+	assert.NoError(t, err)
+
+	mockRepo := NewMockRepo()
+	cacheRepo := NewCachedRepository(mockRepo, 10*time.Minute, fileCache)
+	_, err = cacheRepo.GetPackage(ctx, "test-gem")
+	assert.NoError(t, err)
+
+	// 关闭时数据应该被写入文件，重复关闭是安全的
+	assert.NoError(t, cacheRepo.CloseWithError())
+	assert.NoError(t, cacheRepo.CloseWithError())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "package:test-gem")
+
+	// 用同一个文件重新创建缓存仓库，应该直接命中缓存
+	reopened, err := cache.NewFileCache(path, 10*time.Minute, 0)
+	assert.NoError(t, err)
+	mockRepo2 := NewMockRepo()
+	cacheRepo2 := NewCachedRepository(mockRepo2, 10*time.Minute, reopened)
+	defer cacheRepo2.Close()
+
+	pkg, err := cacheRepo2.GetPackage(ctx, "test-gem")
+	assert.NoError(t, err)
+	assert.Equal(t, "test-gem", pkg.Name)
+	assert.Equal(t, 0, mockRepo2.calledTimes)
+}
+
+func TestCachedRepository_SearchNormalize(t *testing.T) {
+	ctx := context.Background()
+
+	// 开启规范化后，大小写和空白不同的查询共享同一个缓存项
+	mockRepo := NewMockRepo()
+	cacheRepo := NewCachedRepository(mockRepo, 10*time.Minute, nil).
+		WithSearchOptions(NewSearchOptions().WithNormalize(true).WithLowercase(true))
+	defer cacheRepo.Close()
+
+	_, err := cacheRepo.Search(ctx, "  Rails ", 1)
+	assert.NoError(t, err)
+	_, err = cacheRepo.Search(ctx, "rails", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, mockRepo.calledTimes)
+	assert.Equal(t, []string{"rails"}, mockRepo.queries)
+
+	// 默认不做规范化
+	mockRepo2 := NewMockRepo()
+	cacheRepo2 := NewCachedRepository(mockRepo2, 10*time.Minute, nil)
+	defer cacheRepo2.Close()
+
+	_, _ = cacheRepo2.Search(ctx, "  Rails ", 1)
+	_, _ = cacheRepo2.Search(ctx, "rails", 1)
+	assert.Equal(t, 2, mockRepo2.calledTimes)
+}
+
+func TestCachedRepository_EmptySearchTTL(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := NewMockRepo()
+	mockRepo.searchResults = map[string][]*models.PackageInformation{"nothing-here": {}}
+
+	// 非空结果缓存200ms，空结果只缓存20ms
+	cacheRepo := NewCachedRepository(mockRepo, 400*time.Millisecond, nil).WithEmptySearchTTL(20 * time.Millisecond)
+	defer cacheRepo.Close()
+
+	_, _ = cacheRepo.Search(ctx, "test", 1)
+	_, _ = cacheRepo.Search(ctx, "nothing-here", 1)
+	assert.Equal(t, 2, mockRepo.calledTimes)
+
+	time.Sleep(50 * time.Millisecond)
+
+	// 空结果已经过期，非空结果仍然命中缓存
+	_, _ = cacheRepo.Search(ctx, "test", 1)
+	assert.Equal(t, 2, mockRepo.calledTimes)
+	results, err := cacheRepo.Search(ctx, "nothing-here", 1)
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+	assert.Equal(t, 3, mockRepo.calledTimes)
+}
+
+func TestCachedRepository_EmptySearchTTLDefault(t *testing.T) {
+	cacheRepo := NewCachedRepository(NewMockRepo(), time.Hour, nil)
+	defer cacheRepo.Close()
+	assert.Equal(t, DefaultEmptySearchTTL, cacheRepo.emptySearchTTLOrDefault())
+
+	// 非空结果的缓存时间更短时，空结果不会比它缓存得更久
+	shortRepo := NewCachedRepository(NewMockRepo(), 10*time.Second, nil)
+	defer shortRepo.Close()
+	assert.Equal(t, 5*time.Second, shortRepo.emptySearchTTLOrDefault())
+}
+
+func TestCachedRepository_PrefetchDependencies(t *testing.T) {
+	var mu sync.Mutex
+	requested := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested[r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/api/v1/gems/rails.json":
+			_, _ = w.Write([]byte(`{"name": "rails", "version": "7.1.2", "dependencies": {"runtime": [
+				{"name": "activesupport", "requirements": "= 7.1.2"},
+				{"name": "actionpack", "requirements": "= 7.1.2"}
+			]}}`))
+		case "/api/v1/gems/activesupport.json":
+			_, _ = w.Write([]byte(`{"name": "activesupport", "version": "7.1.2", "dependencies": {"runtime": [
+				{"name": "i18n", "requirements": ">= 1.6, < 2"}
+			]}}`))
+		case "/api/v1/gems/actionpack.json":
+			_, _ = w.Write([]byte(`{"name": "actionpack", "version": "7.1.2"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	repo := NewRepository(NewOptions().SetServerURL(server.URL).DisableRetry())
+	cachedRepo := NewCachedRepository(repo, time.Minute, nil).
+		WithPrefetchDependencies(true).
+		WithPrefetchConcurrency(1)
+	defer cachedRepo.Close()
+
+	rails, err := cachedRepo.GetPackage(context.Background(), "rails")
+	assert.NoError(t, err)
+	assert.Equal(t, "7.1.2", rails.Version)
+	cachedRepo.prefetchWg.Wait()
+
+	// 依赖已经被预取到缓存中
+	activesupport, err := cachedRepo.GetPackage(context.Background(), "activesupport")
+	assert.NoError(t, err)
+	assert.Equal(t, "activesupport", activesupport.Name)
+	results := cachedRepo.BulkGetPackages(context.Background(), []string{"activesupport", "actionpack"}, nil)
+	assert.Equal(t, BulkSummary{Total: 2, Succeeded: 2}, SummarizeBulk(results))
+	cachedRepo.prefetchWg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, requested["/api/v1/gems/activesupport.json"])
+	assert.Equal(t, 1, requested["/api/v1/gems/actionpack.json"])
+	// 只预取一层依赖
+	assert.Equal(t, 0, requested["/api/v1/gems/i18n.json"])
+}
+
+func TestCachedRepository_PublishInvalidatesMultiGemDependencies(t *testing.T) {
+	var mu sync.Mutex
+	requested := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/api/v1/dependencies":
+			requested[r.URL.Query().Get("gems")]++
+			_, _ = w.Write([]byte(`[]`))
+		case "/api/v1/gems/yank":
+			_, _ = w.Write([]byte("Successfully deleted gem: tiny (0.2.0)"))
+		case "/api/v1/gems":
+			_, _ = w.Write([]byte("Successfully registered gem: rack (3.0.9)"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	repo := NewRepository(NewOptions().SetServerURL(server.URL).DisableRetry())
+	cachedRepo := NewCachedRepository(repo, time.Minute, nil)
+	defer cachedRepo.Close()
+
+	fetch := func(gemNames ...string) {
+		_, err := cachedRepo.GetDependencies(context.Background(), gemNames...)
+		assert.NoError(t, err)
+	}
+	count := func(gems string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return requested[gems]
+	}
+
+	fetch("tiny", "rack")
+	fetch("rack", "puma")
+	fetch("tiny", "rack")
+	fetch("rack", "puma")
+	assert.Equal(t, 1, count("tiny,rack"))
+	assert.Equal(t, 1, count("rack,puma"))
+
+	// 撤回tiny后包含tiny的多包依赖缓存失效，其它的仍然有效
+	assert.NoError(t, cachedRepo.YankGem(context.Background(), "tiny", "0.2.0"))
+	fetch("tiny", "rack")
+	fetch("rack", "puma")
+	assert.Equal(t, 2, count("tiny,rack"))
+	assert.Equal(t, 1, count("rack,puma"))
+
+	// 发布rack后两个缓存都包含rack，都会失效
+	_, err := cachedRepo.PushGem(context.Background(), strings.NewReader("gem"))
+	assert.NoError(t, err)
+	fetch("tiny", "rack")
+	fetch("rack", "puma")
+	assert.Equal(t, 3, count("tiny,rack"))
+	assert.Equal(t, 2, count("rack,puma"))
+}
+
+func TestCachedRepository_YankInvalidatesCache(t *testing.T) {
+	var mu sync.Mutex
+	yanked := false
+	versionRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/api/v1/versions/tiny.json":
+			versionRequests++
+			if yanked {
+				_, _ = w.Write([]byte(`[{"number": "0.1.0"}]`))
+			} else {
+				_, _ = w.Write([]byte(`[{"number": "0.2.0"}, {"number": "0.1.0"}]`))
+			}
+		case "/api/v1/gems/yank":
+			yanked = true
+			_, _ = w.Write([]byte("Successfully deleted gem: tiny (0.2.0)"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	repo := NewRepository(NewOptions().SetServerURL(server.URL).DisableRetry())
+	cachedRepo := NewCachedRepository(repo, time.Minute, nil)
+	defer cachedRepo.Close()
+
+	versions, err := cachedRepo.GetGemVersions(context.Background(), "tiny")
+	assert.NoError(t, err)
+	assert.Len(t, versions, 2)
+	_, _ = cachedRepo.GetGemVersions(context.Background(), "tiny")
+	assert.Equal(t, 1, versionRequests)
+
+	// 撤回之后重新获取版本列表，而不是返回撤回前缓存的列表
+	assert.NoError(t, cachedRepo.YankGem(context.Background(), "tiny", "0.2.0"))
+	versions, err = cachedRepo.GetGemVersions(context.Background(), "tiny")
+	assert.NoError(t, err)
+	if assert.Len(t, versions, 1) {
+		assert.Equal(t, "0.1.0", versions[0].Number)
+	}
+	assert.Equal(t, 2, versionRequests)
+
+	// 底层仓库不支持发布
+	err = NewCachedRepository(NewMockRepo(), time.Minute, nil).YankGem(context.Background(), "tiny", "0.1.0")
+	assert.ErrorIs(t, err, ErrUnsupportedOperation)
+}
+
+func TestCachedRepository_BypassCache(t *testing.T) {
+	mockRepo := NewMockRepo()
+	cachedRepo := NewCachedRepository(mockRepo, time.Minute, nil)
+	defer cachedRepo.Close()
+
+	ctx := context.Background()
+	assert.False(t, IsBypassCache(ctx))
+	_, _ = cachedRepo.GetPackage(ctx, "test-gem")
+	_, _ = cachedRepo.GetPackage(ctx, "test-gem")
+	assert.Equal(t, 1, mockRepo.calledTimes)
+
+	// 跳过缓存时即使有缓存也请求底层仓库
+	mockRepo.testPkg = &models.PackageInformation{Name: "test-gem", Version: "2.0.0"}
+	bypassCtx := WithBypassCache(ctx)
+	assert.True(t, IsBypassCache(bypassCtx))
+	pkg, err := cachedRepo.GetPackage(bypassCtx, "test-gem")
+	assert.NoError(t, err)
+	assert.Equal(t, "2.0.0", pkg.Version)
+	assert.Equal(t, 2, mockRepo.calledTimes)
+
+	// 新的结果写回了缓存
+	pkg, err = cachedRepo.GetPackage(ctx, "test-gem")
+	assert.NoError(t, err)
+	assert.Equal(t, "2.0.0", pkg.Version)
+	assert.Equal(t, 2, mockRepo.calledTimes)
+}
+
+// 记录批量方法实际交给底层仓库的包名
+type bulkRecordingRepo struct {
+	*mockRepository
+	bulkCalls [][]string
+}
+
+func (r *bulkRecordingRepo) BulkGetPackages(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[*models.PackageInformation] {
+	r.bulkCalls = append(r.bulkCalls, gemNames)
+	return r.mockRepository.BulkGetPackages(ctx, gemNames, options)
+}
+
+func (r *bulkRecordingRepo) BulkGetVersions(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[[]*models.Version] {
+	r.bulkCalls = append(r.bulkCalls, gemNames)
+	return r.mockRepository.BulkGetVersions(ctx, gemNames, options)
+}
+
+func TestCachedRepository_BulkUsesCache(t *testing.T) {
+	mockRepo := newMockRepository()
+	mockRepo.delay = 0
+	repo := &bulkRecordingRepo{mockRepository: mockRepo}
+	cachedRepo := NewCachedRepository(repo, time.Minute, nil)
+	defer cachedRepo.Close()
+	ctx := context.Background()
+
+	// 单个获取的结果与批量获取共用缓存
+	_, err := cachedRepo.GetPackage(ctx, "rails")
+	assert.NoError(t, err)
+
+	results := cachedRepo.BulkGetPackages(ctx, []string{"rails", "rack", "not-exist"}, nil)
+	assert.Equal(t, [][]string{{"rack", "not-exist"}}, repo.bulkCalls)
+	assert.Len(t, results, 3)
+	assert.Equal(t, "rails", results[0].Key)
+	assert.Equal(t, "rails", results[0].Value.Name)
+	assert.Equal(t, "rack", results[1].Key)
+	assert.Equal(t, "rack", results[1].Value.Name)
+	assert.Equal(t, "not-exist", results[2].Key)
+	assert.Error(t, results[2].Error)
+
+	// 获取成功的包写入了缓存，失败的不缓存
+	repo.bulkCalls = nil
+	results = cachedRepo.BulkGetPackages(ctx, []string{"rack", "rails", "not-exist"}, nil)
+	assert.Equal(t, [][]string{{"not-exist"}}, repo.bulkCalls)
+	assert.Equal(t, BulkSummary{Total: 3, Succeeded: 2, Failed: 1}, SummarizeBulk(results))
+	pkg, err := cachedRepo.GetPackage(ctx, "rack")
+	assert.NoError(t, err)
+	assert.Equal(t, "rack", pkg.Name)
+	assert.Len(t, repo.bulkCalls, 1)
+
+	// 全部命中缓存时不调用底层仓库
+	repo.bulkCalls = nil
+	cachedRepo.BulkGetVersions(ctx, []string{"rails", "rack"}, nil)
+	versions := cachedRepo.BulkGetVersions(ctx, []string{"rack", "rails"}, nil)
+	assert.Equal(t, [][]string{{"rails", "rack"}}, repo.bulkCalls)
+	assert.Equal(t, "2.2.7", versions[0].Value[0].Number)
+	assert.Equal(t, "7.0.5", versions[1].Value[0].Number)
+}
+
+func TestCachedRepository_ImportJSONL(t *testing.T) {
+	mockRepo := NewMockRepo()
+	cachedRepo := NewCachedRepository(mockRepo, time.Minute, nil)
+	defer cachedRepo.Close()
+
+	dump := `{"name": "rails", "version": "7.1.2", "downloads": 500000000}
+{"name": "rack", "version": "3.0.8"}
+
+{"name": "broken",
+{"version": "1.0.0"}
+{"name": "sinatra", "version": "3.1.0"}
+`
+	imported, err := cachedRepo.ImportJSONL(strings.NewReader(dump))
+	assert.Equal(t, 3, imported)
+	var multiErr *MultiError
+	if assert.ErrorAs(t, err, &multiErr) {
+		assert.Len(t, multiErr.Errors, 2)
+		assert.Contains(t, multiErr.Errors[0].Error(), "line 4")
+		assert.Contains(t, multiErr.Errors[1].Error(), "line 5")
+	}
+	assert.Equal(t, 3, cachedRepo.GetCacheStats())
+
+	// 导入的包直接命中缓存，不请求底层仓库
+	pkg, err := cachedRepo.GetPackage(context.Background(), "rails")
+	assert.NoError(t, err)
+	assert.Equal(t, "7.1.2", pkg.Version)
+	assert.Equal(t, models.Count(500000000), pkg.Downloads)
+	_, err = cachedRepo.GetPackage(context.Background(), "sinatra")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, mockRepo.calledTimes)
+
+	imported, err = cachedRepo.ImportJSONL(strings.NewReader(""))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, imported)
+}
+
+func TestCachedRepository_OnCacheEvent(t *testing.T) {
+	type event struct {
+		key string
+		hit bool
+	}
+	var events []event
+	mockRepo := NewMockRepo()
+	cachedRepo := NewCachedRepository(mockRepo, time.Minute, nil)
+	cachedRepo.WithOnCacheEvent(func(key string, hit bool) {
+		// 回调中可以访问缓存，不会因为持有锁而死锁
+		cachedRepo.GetCacheStats()
+		events = append(events, event{key, hit})
+	})
+	defer cachedRepo.Close()
+	ctx := context.Background()
+
+	_, _ = cachedRepo.GetPackage(ctx, "test-gem")
+	_, _ = cachedRepo.GetPackage(ctx, "test-gem")
+	_, _ = cachedRepo.GetGemVersions(ctx, "test-gem")
+	assert.Equal(t, []event{
+		{"package:test-gem", false},
+		{"package:test-gem", true},
+		{"versions:test-gem", false},
+	}, events)
+
+	// 跳过缓存时视为未命中
+	events = nil
+	_, _ = cachedRepo.GetPackage(WithBypassCache(ctx), "test-gem")
+	assert.Equal(t, []event{{"package:test-gem", false}}, events)
+
+	// 取消回调
+	events = nil
+	cachedRepo.WithOnCacheEvent(nil)
+	_, _ = cachedRepo.GetPackage(ctx, "test-gem")
+	assert.Empty(t, events)
+}
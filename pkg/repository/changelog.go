@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// GetChangelog 获取gem包的变更日志内容
+// 变更日志地址取自包信息中的changelog_uri（顶层为空时使用metadata中的值），
+// 指向GitHub上的文件页面时会改为请求对应的原始文件，以便拿到文本而不是HTML页面。
+// 注意变更日志可能托管在任意站点，这个请求会离开配置的仓库或镜像直接访问该地址：
+// 请求会使用配置的代理和重试策略，但不会携带Token。
+// 包没有声明变更日志地址时返回ErrNotFound
+func (x *RepositoryImpl) GetChangelog(ctx context.Context, gemName string) (string, error) {
+	pkg, err := x.GetPackage(ctx, gemName)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(pkg.ChangelogURI) == "" {
+		return "", fmt.Errorf("%w: %s does not declare a changelog", ErrNotFound, gemName)
+	}
+
+	request := &apiRequest{
+		operation: OperationGetChangelog,
+		url:       changelogRawURL(pkg.ChangelogURI),
+		external:  true,
+	}
+	bytes, err := doRequest(ctx, x, request, bodyResponseHandler)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// changelogRawURL 把GitHub文件页面地址转换为原始文件地址，其它地址原样返回
+// 例如 https://github.com/rails/rails/blob/main/CHANGELOG.md => https://raw.githubusercontent.com/rails/rails/main/CHANGELOG.md
+func changelogRawURL(changelogURI string) string {
+	u, err := url.Parse(strings.TrimSpace(changelogURI))
+	if err != nil || u.Host != "github.com" {
+		return strings.TrimSpace(changelogURI)
+	}
+	// /owner/repo/blob/ref/path...
+	parts := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 5)
+	if len(parts) < 5 || parts[2] != "blob" {
+		return u.String()
+	}
+	return fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s/%s", parts[0], parts[1], parts[3], parts[4])
+}
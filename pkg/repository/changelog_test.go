@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_GetChangelog(t *testing.T) {
+	changelogServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 变更日志托管在第三方站点，不应该收到仓库的Token
+		assert.Empty(t, r.Header.Get("Authorization"))
+		if r.URL.Path != "/demo/CHANGELOG.md" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("## 1.0.0\n\n- Initial release\n"))
+	}))
+	defer changelogServer.Close()
+
+	repo := newTestRepository(t, map[string]string{
+		"/api/v1/gems/demo.json":  fmt.Sprintf(`{"name": "demo", "metadata": {"changelog_uri": "%s/demo/CHANGELOG.md"}}`, changelogServer.URL),
+		"/api/v1/gems/moved.json": fmt.Sprintf(`{"name": "moved", "changelog_uri": "%s/moved/CHANGELOG.md"}`, changelogServer.URL),
+		"/api/v1/gems/nolog.json": `{"name": "nolog"}`,
+	})
+	repo.options.SetToken("secret-token")
+
+	changelog, err := repo.GetChangelog(context.Background(), "demo")
+	assert.NoError(t, err)
+	assert.Contains(t, changelog, "Initial release")
+
+	_, err = repo.GetChangelog(context.Background(), "moved")
+	assert.True(t, IsNotFound(err))
+
+	_, err = repo.GetChangelog(context.Background(), "nolog")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestChangelogRawURL(t *testing.T) {
+	assert.Equal(t, "https://raw.githubusercontent.com/rails/rails/v7.0.5/CHANGELOG.md",
+		changelogRawURL("https://github.com/rails/rails/blob/v7.0.5/CHANGELOG.md"))
+	assert.Equal(t, "https://github.com/rails/rails/releases/tag/v7.0.5",
+		changelogRawURL("https://github.com/rails/rails/releases/tag/v7.0.5"))
+	assert.Equal(t, "https://example.com/CHANGES", changelogRawURL(" https://example.com/CHANGES "))
+}
@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+)
+
+// CompactIndex 是compact index中/names和/versions两个文件的内容以及服务端返回的ETag
+// 参考: https://guides.rubygems.org/rubygems-org-compact-index-api/
+type CompactIndex struct {
+	// /names的原始内容，每行一个gem包名
+	Names     []byte
+	NamesETag string
+
+	// /versions的原始内容，每行是一个gem包的版本列表和对应info文件的校验和
+	Versions     []byte
+	VersionsETag string
+
+	// 最近一次有新数据到达的时间
+	FetchedAt time.Time
+}
+
+// GemNames 解析/names文件，返回全部gem包名
+func (i *CompactIndex) GemNames() []string {
+	names, _ := scanGemNames(bytes.NewReader(i.Names))
+	return names
+}
+
+// scanGemNames 逐行解析/names文件的内容，跳过开头的 "---" 和空行
+func scanGemNames(r io.Reader) ([]string, error) {
+	names := make([]string, 0)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// 文件以 "---" 开头
+		if line == "" || line == "---" {
+			continue
+		}
+		names = append(names, line)
+	}
+	return names, scanner.Err()
+}
+
+// GetAllGemNames 获取仓库中全部gem的包名，可以在发起更昂贵的单个gem请求之前低成本地确认包名存在
+// 响应有数MB，这里边读取边解析，不把整个响应读到内存中再拆分。
+// 只需要一次性的列表时使用这个方法，需要定期同步时使用RefreshIndex，它会用ETag避免重复下载
+// GET - /names
+func (x *RepositoryImpl) GetAllGemNames(ctx context.Context) ([]string, error) {
+	request := &apiRequest{
+		operation: OperationGetAllGemNames,
+		url:       fmt.Sprintf("%s/names", x.options.ServerURL),
+	}
+	return doRequest(ctx, x, request, gemNamesResponseHandler)
+}
+
+// GetCompactInfo 获取compact index中gem的/info文件，返回每个版本的运行时依赖、要求的ruby版本和校验和
+// 只需要依赖关系做解析时，这比逐个获取版本的JSON信息便宜得多
+// GET - /info/[GEM NAME]
+func (x *RepositoryImpl) GetCompactInfo(ctx context.Context, gemName string) ([]*models.CompactVersion, error) {
+	request := &apiRequest{
+		operation: OperationGetCompactInfo,
+		url:       fmt.Sprintf("%s/info/%s", x.options.ServerURL, url.PathEscape(gemName)),
+	}
+	return doRequest(ctx, x, request, compactInfoResponseHandler)
+}
+
+// compactInfoResponseHandler 流式解析/info的响应
+func compactInfoResponseHandler(resp *http.Response) ([]*models.CompactVersion, error) {
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseStatusError(resp)
+	}
+	defer resp.Body.Close()
+	return models.ParseCompactInfo(resp.Body)
+}
+
+// gemNamesResponseHandler 流式解析/names的响应
+func gemNamesResponseHandler(resp *http.Response) ([]string, error) {
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseStatusError(resp)
+	}
+	defer resp.Body.Close()
+	return scanGemNames(resp.Body)
+}
+
+// compactIndexCache 保存最近一次获取的compact index，可以被多个goroutine同时访问
+type compactIndexCache struct {
+	mu      sync.Mutex
+	current *CompactIndex
+}
+
+func (c *compactIndexCache) load() *CompactIndex {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.current
+}
+
+func (c *compactIndexCache) store(index *CompactIndex) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current = index
+}
+
+// CompactIndex 返回最近一次RefreshIndex获取的compact index，还没有获取过时返回nil
+// 返回的内容不会被之后的刷新修改，调用方不要修改它
+func (x *RepositoryImpl) CompactIndex() *CompactIndex {
+	return x.index.load()
+}
+
+// RefreshIndex 按条件重新获取compact index的/names和/versions文件，返回是否有新数据到达
+// 请求会带上上次保存的ETag（If-None-Match），两个文件都返回304时changed为false，不需要传输文件内容，
+// 适合作为增量镜像循环中判断仓库是否有变化的第一步。
+// 两个文件都成功获取后才会一起替换保存的内容，任意一个请求失败时保留之前的内容并返回错误
+// GET - /names
+// GET - /versions
+func (x *RepositoryImpl) RefreshIndex(ctx context.Context) (changed bool, err error) {
+	previous := x.index.load()
+	if previous == nil {
+		previous = &CompactIndex{}
+	}
+
+	names, err := x.getIndexFile(ctx, "names", previous.NamesETag)
+	if err != nil {
+		return false, err
+	}
+	versions, err := x.getIndexFile(ctx, "versions", previous.VersionsETag)
+	if err != nil {
+		return false, err
+	}
+	if names.notModified && versions.notModified {
+		return false, nil
+	}
+
+	next := &CompactIndex{
+		Names:        previous.Names,
+		NamesETag:    previous.NamesETag,
+		Versions:     previous.Versions,
+		VersionsETag: previous.VersionsETag,
+		FetchedAt:    time.Now(),
+	}
+	if !names.notModified {
+		next.Names, next.NamesETag = names.body, names.etag
+	}
+	if !versions.notModified {
+		next.Versions, next.VersionsETag = versions.body, versions.etag
+	}
+	x.index.store(next)
+	return true, nil
+}
+
+// indexFile 是一次按条件获取compact index文件的结果
+type indexFile struct {
+	notModified bool
+	body        []byte
+	etag        string
+}
+
+// getIndexFile 获取compact index中的一个文件，etag不为空时只在文件变化后才返回内容
+func (x *RepositoryImpl) getIndexFile(ctx context.Context, name, etag string) (*indexFile, error) {
+	request := &apiRequest{
+		operation: OperationRefreshIndex,
+		url:       fmt.Sprintf("%s/%s", x.options.ServerURL, name),
+	}
+	if etag != "" {
+		request.settings = append(request.settings, func(client *http.Client, request *http.Request) error {
+			request.Header.Set("If-None-Match", etag)
+			return nil
+		})
+	}
+	return doRequest(ctx, x, request, indexFileResponseHandler)
+}
+
+// indexFileResponseHandler 处理按条件获取的响应，304表示文件没有变化
+func indexFileResponseHandler(resp *http.Response) (*indexFile, error) {
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return &indexFile{notModified: true}, resp.Body.Close()
+	case http.StatusOK:
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return &indexFile{body: body, etag: resp.Header.Get("ETag")}, nil
+	default:
+		return nil, responseStatusError(resp)
+	}
+}
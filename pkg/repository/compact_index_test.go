@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_RefreshIndex(t *testing.T) {
+	var mu sync.Mutex
+	files := map[string]struct{ etag, body string }{
+		"/names":    {`"names-1"`, "---\nrack\nrails\n"},
+		"/versions": {`"versions-1"`, "created_at: 2024-01-01T00:00:00Z\n---\nrack 3.0.8 abc\nrails 7.1.2 def\n"},
+	}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		file, ok := files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.Header.Get("If-None-Match") == file.etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", file.etag)
+		_, _ = w.Write([]byte(file.body))
+	}))
+	defer server.Close()
+
+	repo := NewRepository(NewOptions().SetServerURL(server.URL).DisableRetry())
+	assert.Nil(t, repo.CompactIndex())
+
+	// 第一次获取时总是有新数据
+	changed, err := repo.RefreshIndex(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	index := repo.CompactIndex()
+	if assert.NotNil(t, index) {
+		assert.Equal(t, []string{"rack", "rails"}, index.GemNames())
+		assert.Equal(t, `"versions-1"`, index.VersionsETag)
+	}
+
+	// 两个文件都返回304
+	changed, err = repo.RefreshIndex(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.Same(t, index, repo.CompactIndex())
+
+	// 只有versions发生变化，names保留之前的内容
+	mu.Lock()
+	files["/versions"] = struct{ etag, body string }{`"versions-2"`, "created_at: 2024-01-01T00:00:00Z\n---\nrack 3.0.8,3.0.9 123\nrails 7.1.2 def\n"}
+	mu.Unlock()
+	changed, err = repo.RefreshIndex(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	refreshed := repo.CompactIndex()
+	assert.Equal(t, index.Names, refreshed.Names)
+	assert.Equal(t, `"versions-2"`, refreshed.VersionsETag)
+	assert.Contains(t, string(refreshed.Versions), "3.0.9")
+	assert.Equal(t, 6, requests)
+
+	// 请求失败时保留之前的内容
+	mu.Lock()
+	delete(files, "/versions")
+	files["/names"] = struct{ etag, body string }{`"names-2"`, "---\nrack\n"}
+	mu.Unlock()
+	changed, err = repo.RefreshIndex(context.Background())
+	assert.Error(t, err)
+	assert.False(t, changed)
+	assert.Same(t, refreshed, repo.CompactIndex())
+}
+
+func TestRepository_GetAllGemNames(t *testing.T) {
+	var names strings.Builder
+	names.WriteString("---\n")
+	for i := 0; i < 50000; i++ {
+		fmt.Fprintf(&names, "gem-%05d\n", i)
+	}
+	repo := newTestRepository(t, map[string]string{
+		"/names": names.String(),
+	})
+
+	all, err := repo.GetAllGemNames(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, all, 50000) {
+		assert.Equal(t, "gem-00000", all[0])
+		assert.Equal(t, "gem-49999", all[49999])
+	}
+
+	_, err = newTestRepository(t, map[string]string{}).GetAllGemNames(context.Background())
+	assert.True(t, IsNotFound(err))
+}
+
+func TestRepository_GetCompactInfo(t *testing.T) {
+	repo := newTestRepository(t, map[string]string{
+		"/info/nokogiri": "---\n1.15.4 mini_portile2:~> 2.8.2,racc:~> 1.4|checksum:aaa,ruby:>= 2.7&< 3.3.dev\n1.15.4-java racc:~> 1.4|checksum:bbb,ruby:>= 2.7\n",
+	})
+
+	versions, err := repo.GetCompactInfo(context.Background(), "nokogiri")
+	assert.NoError(t, err)
+	if assert.Len(t, versions, 2) {
+		assert.Equal(t, "1.15.4", versions[0].Number)
+		assert.Len(t, versions[0].Dependencies, 2)
+		assert.Equal(t, "aaa", versions[0].Checksum)
+		assert.Equal(t, "java", versions[1].Platform)
+	}
+
+	_, err = repo.GetCompactInfo(context.Background(), "missing")
+	assert.True(t, IsNotFound(err))
+}
@@ -0,0 +1,235 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+)
+
+// CrawlOptions 定义依赖闭包爬取的配置选项
+type CrawlOptions struct {
+	// MaxDepth 最大爬取深度，根包的深度为0，直接依赖的深度为1
+	// 0表示不限制深度
+	MaxDepth int
+
+	// MaxGems 最多爬取的包数量，达到后不再加入新的包
+	// 0表示不限制数量
+	MaxGems int
+
+	// MaxConcurrency 同一层中并发请求的最大数量，默认为10
+	MaxConcurrency int
+}
+
+// NewCrawlOptions 创建具有默认值的爬取选项
+// 默认配置：不限制深度和数量，最大并发数10
+func NewCrawlOptions() *CrawlOptions {
+	return &CrawlOptions{
+		MaxConcurrency: 10,
+	}
+}
+
+// WithMaxDepth 设置最大爬取深度
+// 返回选项对象自身，支持链式调用
+func (o *CrawlOptions) WithMaxDepth(maxDepth int) *CrawlOptions {
+	if maxDepth >= 0 {
+		o.MaxDepth = maxDepth
+	}
+	return o
+}
+
+// WithMaxGems 设置最多爬取的包数量
+// 返回选项对象自身，支持链式调用
+func (o *CrawlOptions) WithMaxGems(maxGems int) *CrawlOptions {
+	if maxGems >= 0 {
+		o.MaxGems = maxGems
+	}
+	return o
+}
+
+// WithMaxConcurrency 设置最大并发请求数
+// 返回选项对象自身，支持链式调用
+func (o *CrawlOptions) WithMaxConcurrency(maxConcurrency int) *CrawlOptions {
+	if maxConcurrency > 0 {
+		o.MaxConcurrency = maxConcurrency
+	}
+	return o
+}
+
+// CrawlClosure 从根包出发按广度优先遍历运行时依赖，收集所有可达的gem包
+// 每个包只会请求一次，同一层的包并发请求。依赖关系取自每个包当前版本声明的运行时依赖。
+// 部分包获取失败时，仍然返回已经获取到的包，同时返回描述失败情况的错误；
+// ctx被取消或超时时，返回已经获取到的包和ctx.Err()
+// 参数:
+//   - ctx: 上下文，用于控制请求超时和取消
+//   - roots: 根包名列表
+//   - options: 爬取选项，为nil时使用默认选项
+//
+// 返回:
+//   - 以包名为键的去重后的包信息
+func (x *RepositoryImpl) CrawlClosure(ctx context.Context, roots []string, options *CrawlOptions) (map[string]*models.PackageInformation, error) {
+	return x.crawl(ctx, roots, options)
+}
+
+// CrawlClosureWithCycles 与CrawlClosure相同，同时返回在爬取到的包之间发现的循环依赖
+// 循环依赖不会导致重复请求或死循环，这里只是把它们报告出来，便于分析生态的健康状况
+func (x *RepositoryImpl) CrawlClosureWithCycles(ctx context.Context, roots []string, options *CrawlOptions) (map[string]*models.PackageInformation, []models.Cycle, error) {
+	packages, err := x.crawl(ctx, roots, options)
+	return packages, FindCycles(packages), err
+}
+
+func (x *RepositoryImpl) crawl(ctx context.Context, roots []string, options *CrawlOptions) (map[string]*models.PackageInformation, error) {
+	if options == nil {
+		options = NewCrawlOptions()
+	}
+	bulkOptions := NewBulkOptions().WithMaxConcurrency(options.MaxConcurrency)
+
+	packages := make(map[string]*models.PackageInformation)
+	visited := make(map[string]bool)
+
+	var firstErr error
+	failed := 0
+
+	// enqueue 把尚未访问的包加入下一层，达到数量上限时返回false
+	enqueue := func(level []string, gemName string) ([]string, bool) {
+		if visited[gemName] {
+			return level, true
+		}
+		if options.MaxGems > 0 && len(visited) >= options.MaxGems {
+			return level, false
+		}
+		visited[gemName] = true
+		return append(level, gemName), true
+	}
+
+	level := make([]string, 0, len(roots))
+	for _, root := range roots {
+		var ok bool
+		if level, ok = enqueue(level, root); !ok {
+			break
+		}
+	}
+
+	for depth := 0; len(level) > 0; depth++ {
+		if err := ctx.Err(); err != nil {
+			return packages, err
+		}
+
+		next := make([]string, 0)
+		full := false
+		for _, result := range bulkExecute(ctx, level, bulkOptions, x.GetPackage) {
+			// ctx被取消后工作池不再处理剩下的键，这些键的结果为nil
+			if result == nil {
+				continue
+			}
+			if result.Error != nil {
+				failed++
+				if firstErr == nil {
+					firstErr = fmt.Errorf("%s: %w", result.Key, result.Error)
+				}
+				continue
+			}
+			packages[result.Key] = result.Value
+
+			if full || (options.MaxDepth > 0 && depth >= options.MaxDepth) {
+				continue
+			}
+			for _, dependency := range result.Value.Dependencies.Runtime {
+				var ok bool
+				if next, ok = enqueue(next, dependency.Name); !ok {
+					full = true
+					break
+				}
+			}
+		}
+		// 爬取过程中ctx被取消或超时，返回已经获取到的包和ctx的错误
+		if err := ctx.Err(); err != nil {
+			return packages, err
+		}
+		level = next
+	}
+
+	if firstErr != nil {
+		return packages, fmt.Errorf("failed to fetch %d of %d gems, first error: %w", failed, len(visited), firstErr)
+	}
+	return packages, nil
+}
+
+// FindCycles 在一组包的运行时依赖之间查找循环依赖
+// 按包名顺序做深度优先遍历，每条回边报告一个循环，同一个循环只报告一次。
+// 依赖了不在集合中的包时，这条依赖会被忽略
+func FindCycles(packages map[string]*models.PackageInformation) []models.Cycle {
+	names := make([]string, 0, len(packages))
+	for name := range packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(packages))
+	path := make([]string, 0)
+	seen := make(map[string]bool)
+	cycles := make([]models.Cycle, 0)
+
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = visiting
+		path = append(path, name)
+
+		// 集合中可能有值为nil的包，当作没有依赖处理
+		var dependencies []*models.Dependency
+		if pkg := packages[name]; pkg != nil {
+			dependencies = pkg.Dependencies.Runtime
+		}
+		for _, dependency := range dependencies {
+			if _, ok := packages[dependency.Name]; !ok {
+				continue
+			}
+			switch state[dependency.Name] {
+			case unvisited:
+				visit(dependency.Name)
+			case visiting:
+				// 回边：从路径上的祖先到当前节点构成一个循环
+				start := len(path) - 1
+				for path[start] != dependency.Name {
+					start--
+				}
+				cycle := normalizeCycle(path[start:])
+				key := strings.Join(cycle, "\x00")
+				if !seen[key] {
+					seen[key] = true
+					cycles = append(cycles, cycle)
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[name] = done
+	}
+
+	for _, name := range names {
+		if state[name] == unvisited {
+			visit(name)
+		}
+	}
+	return cycles
+}
+
+// normalizeCycle 旋转循环使其从字典序最小的包名开始，便于去重
+func normalizeCycle(loop []string) models.Cycle {
+	smallest := 0
+	for i, name := range loop {
+		if name < loop[smallest] {
+			smallest = i
+		}
+	}
+	cycle := make(models.Cycle, 0, len(loop))
+	cycle = append(cycle, loop[smallest:]...)
+	return append(cycle, loop[:smallest]...)
+}
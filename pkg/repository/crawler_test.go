@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// newCrawlerTestRepository 创建一个小型依赖图:
+//
+//	app -> web, db
+//	web -> rack, json
+//	db  -> json
+//	rack, json 没有依赖
+func newCrawlerTestRepository(t *testing.T) *RepositoryImpl {
+	return newTestRepository(t, map[string]string{
+		"/api/v1/gems/app.json":  `{"name": "app", "version": "1.0.0", "dependencies": {"runtime": [{"name": "web", "requirements": ">= 0"}, {"name": "db", "requirements": ">= 0"}]}}`,
+		"/api/v1/gems/web.json":  `{"name": "web", "version": "2.0.0", "dependencies": {"runtime": [{"name": "rack", "requirements": "~> 2.2"}, {"name": "json", "requirements": ">= 2.0"}]}}`,
+		"/api/v1/gems/db.json":   `{"name": "db", "version": "3.0.0", "dependencies": {"runtime": [{"name": "json", "requirements": ">= 2.0"}], "development": [{"name": "rspec", "requirements": ">= 0"}]}}`,
+		"/api/v1/gems/rack.json": `{"name": "rack", "version": "2.2.7", "dependencies": {"runtime": []}}`,
+		"/api/v1/gems/json.json": `{"name": "json", "version": "2.6.3", "dependencies": {"runtime": []}}`,
+	})
+}
+
+func crawledNames(packages map[string]*models.PackageInformation) []string {
+	names := make([]string, 0, len(packages))
+	for name := range packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestRepository_CrawlClosure(t *testing.T) {
+	repo := newCrawlerTestRepository(t)
+
+	packages, err := repo.CrawlClosure(context.Background(), []string{"app"}, NewCrawlOptions().WithMaxConcurrency(2))
+	assert.NoError(t, err)
+	for name, pkg := range packages {
+		assert.Equal(t, name, pkg.Name)
+	}
+	// 开发依赖不会被爬取
+	assert.Equal(t, []string{"app", "db", "json", "rack", "web"}, crawledNames(packages))
+}
+
+func TestRepository_CrawlClosure_Limits(t *testing.T) {
+	repo := newCrawlerTestRepository(t)
+
+	packages, err := repo.CrawlClosure(context.Background(), []string{"app"}, NewCrawlOptions().WithMaxDepth(1))
+	assert.NoError(t, err)
+	assert.Len(t, packages, 3)
+	assert.Contains(t, packages, "web")
+	assert.NotContains(t, packages, "rack")
+
+	packages, err = repo.CrawlClosure(context.Background(), []string{"app"}, NewCrawlOptions().WithMaxGems(2))
+	assert.NoError(t, err)
+	assert.Len(t, packages, 2)
+	assert.Contains(t, packages, "app")
+}
+
+func TestRepository_CrawlClosure_PartialFailure(t *testing.T) {
+	repo := newCrawlerTestRepository(t)
+
+	packages, err := repo.CrawlClosure(context.Background(), []string{"rack", "missing"}, nil)
+	assert.Error(t, err)
+	assert.Len(t, packages, 1)
+	assert.Contains(t, packages, "rack")
+}
+
+func TestRepository_CrawlClosureWithCycles(t *testing.T) {
+	// a -> b -> c -> a，另外 c -> d -> c
+	repo := newTestRepository(t, map[string]string{
+		"/api/v1/gems/a.json": `{"name": "a", "dependencies": {"runtime": [{"name": "b"}]}}`,
+		"/api/v1/gems/b.json": `{"name": "b", "dependencies": {"runtime": [{"name": "c"}]}}`,
+		"/api/v1/gems/c.json": `{"name": "c", "dependencies": {"runtime": [{"name": "a"}, {"name": "d"}]}}`,
+		"/api/v1/gems/d.json": `{"name": "d", "dependencies": {"runtime": [{"name": "c"}]}}`,
+	})
+
+	packages, cycles, err := repo.CrawlClosureWithCycles(context.Background(), []string{"b", "d"}, nil)
+	assert.NoError(t, err)
+	assert.Len(t, packages, 4)
+	assert.Equal(t, []models.Cycle{{"a", "b", "c"}, {"c", "d"}}, cycles)
+	assert.Equal(t, "a -> b -> c -> a", cycles[0].String())
+}
+
+func TestFindCycles_NoCycle(t *testing.T) {
+	repo := newCrawlerTestRepository(t)
+
+	packages, err := repo.CrawlClosure(context.Background(), []string{"app"}, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, FindCycles(packages))
+}
+
+func TestRepository_CrawlClosure_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 请求web时取消ctx，同一层里还没有处理的包不会再请求
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/gems/rack.json":
+			_, _ = w.Write([]byte(`{"name": "rack", "version": "2.2.7", "dependencies": {"runtime": []}}`))
+		case "/api/v1/gems/web.json":
+			cancel()
+			<-r.Context().Done()
+		default:
+			_, _ = w.Write([]byte(`{"name": "json", "version": "2.6.3", "dependencies": {"runtime": []}}`))
+		}
+	}))
+	defer server.Close()
+	repo := NewRepository(NewOptions().SetServerURL(server.URL).DisableRetry())
+
+	packages, err := repo.CrawlClosure(ctx, []string{"rack", "web", "db", "json"}, NewCrawlOptions().WithMaxConcurrency(1))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []string{"rack"}, crawledNames(packages))
+}
+
+func TestFindCycles_NilPackage(t *testing.T) {
+	packages := map[string]*models.PackageInformation{
+		"a": {Name: "a", Dependencies: models.Dependencies{Runtime: []*models.Dependency{{Name: "b"}}}},
+		"b": nil,
+	}
+	assert.Empty(t, FindCycles(packages))
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+)
+
+// 包级别的默认仓库，类似http.DefaultClient，方便在脚本中直接使用
+var (
+	defaultMu         sync.RWMutex
+	defaultRepository Repository
+)
+
+// Default 返回默认仓库
+// 没有通过SetDefault设置时，第一次调用会使用默认选项创建一个连接官方源的仓库
+func Default() Repository {
+	defaultMu.RLock()
+	repo := defaultRepository
+	defaultMu.RUnlock()
+	if repo != nil {
+		return repo
+	}
+
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultRepository == nil {
+		defaultRepository = NewRepository()
+	}
+	return defaultRepository
+}
+
+// SetDefault 替换默认仓库，例如换成镜像源、带缓存的仓库或者测试用的模拟仓库
+// 传入nil会重置为下次使用时再按默认选项创建
+func SetDefault(repo Repository) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultRepository = repo
+}
+
+// DefaultGetPackage 使用默认仓库获取包的详细信息
+func DefaultGetPackage(ctx context.Context, gemName string) (*models.PackageInformation, error) {
+	return Default().GetPackage(ctx, gemName)
+}
+
+// DefaultGemExists 使用默认仓库判断包是否存在
+func DefaultGemExists(ctx context.Context, gemName string) (bool, error) {
+	return Default().GemExists(ctx, gemName)
+}
+
+// DefaultSearch 使用默认仓库搜索包
+func DefaultSearch(ctx context.Context, query string, page int) ([]*models.PackageInformation, error) {
+	return Default().Search(ctx, query, page)
+}
+
+// DefaultSearchByLicense 使用默认仓库搜索声明了给定许可证的包
+func DefaultSearchByLicense(ctx context.Context, license string, page int) ([]*models.PackageInformation, error) {
+	return Default().SearchByLicense(ctx, license, page)
+}
+
+// DefaultGetGemVersions 使用默认仓库获取包的所有版本
+func DefaultGetGemVersions(ctx context.Context, gemName string) ([]*models.Version, error) {
+	return Default().GetGemVersions(ctx, gemName)
+}
+
+// DefaultGetGemVersion 使用默认仓库获取包的某一个版本
+func DefaultGetGemVersion(ctx context.Context, gemName, version string) (*models.Version, error) {
+	return Default().GetGemVersion(ctx, gemName, version)
+}
+
+// DefaultFindVersionMatching 使用默认仓库查找满足版本约束的最新版本
+func DefaultFindVersionMatching(ctx context.Context, gemName, constraint string) (*models.Version, error) {
+	return Default().FindVersionMatching(ctx, gemName, constraint)
+}
+
+// DefaultGetGemLatestVersion 使用默认仓库获取包的最新版本
+func DefaultGetGemLatestVersion(ctx context.Context, gemName string) (*models.LatestVersion, error) {
+	return Default().GetGemLatestVersion(ctx, gemName)
+}
+
+// DefaultGetTimeFrameVersions 使用默认仓库获取时间段内发布的版本
+func DefaultGetTimeFrameVersions(ctx context.Context, from, to time.Time) ([]*models.Version, error) {
+	return Default().GetTimeFrameVersions(ctx, from, to)
+}
+
+// DefaultDownloads 使用默认仓库获取仓库的总下载量
+func DefaultDownloads(ctx context.Context) (*models.RepositoryDownloadCount, error) {
+	return Default().Downloads(ctx)
+}
+
+// DefaultVersionDownloads 使用默认仓库获取指定版本的下载量
+func DefaultVersionDownloads(ctx context.Context, gemName, gemVersion string) (*models.VersionDownloadCount, error) {
+	return Default().VersionDownloads(ctx, gemName, gemVersion)
+}
+
+// DefaultGetDependencies 使用默认仓库获取包的依赖
+func DefaultGetDependencies(ctx context.Context, gemNames ...string) ([]*models.DependencyInfo, error) {
+	return Default().GetDependencies(ctx, gemNames...)
+}
+
+// DefaultLatestGems 使用默认仓库获取最新发布的包
+func DefaultLatestGems(ctx context.Context) ([]*models.PackageInformation, error) {
+	return Default().LatestGems(ctx)
+}
+
+// DefaultJustUpdatedGems 使用默认仓库获取最近更新的包
+func DefaultJustUpdatedGems(ctx context.Context) ([]*models.PackageInformation, error) {
+	return Default().JustUpdatedGems(ctx)
+}
+
+// DefaultGetReverseDependencies 使用默认仓库获取依赖于指定包的所有包
+func DefaultGetReverseDependencies(ctx context.Context, gemName string) ([]string, error) {
+	return Default().GetReverseDependencies(ctx, gemName)
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultRepository(t *testing.T) {
+	t.Cleanup(func() { SetDefault(nil) })
+
+	// 没有设置时惰性创建官方源仓库，并且之后一直返回同一个实例
+	SetDefault(nil)
+	repo, ok := Default().(*RepositoryImpl)
+	if assert.True(t, ok) {
+		assert.Equal(t, DefaultServerURL, repo.options.ServerURL)
+	}
+	assert.Same(t, Default(), Default())
+
+	// 替换为模拟仓库
+	mockRepo := NewMockRepo()
+	SetDefault(mockRepo)
+
+	pkg, err := DefaultGetPackage(context.Background(), "test-gem")
+	assert.NoError(t, err)
+	assert.Equal(t, "test-gem", pkg.Name)
+	assert.Equal(t, 1, mockRepo.calledTimes)
+}
+
+func TestDefaultRepository_Concurrent(t *testing.T) {
+	t.Cleanup(func() { SetDefault(nil) })
+	SetDefault(nil)
+
+	var wg sync.WaitGroup
+	instances := make([]Repository, 20)
+	for i := range instances {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			instances[i] = Default()
+		}(i)
+	}
+	wg.Wait()
+
+	for _, instance := range instances {
+		assert.Same(t, instances[0], instance)
+	}
+}
@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+)
+
+// TreeOptions 定义构建依赖树的配置选项
+type TreeOptions struct {
+	// MaxDepth 依赖树的最大深度，根节点的直接依赖深度为1
+	// 0表示不限制深度
+	MaxDepth int
+
+	// TargetRubyVersion 目标Ruby版本，例如 "2.7.8"
+	// 设置后，每个依赖会选择声明的Ruby版本要求接受目标版本的最高版本，
+	// 如果一个依赖的所有候选版本都不支持目标Ruby，这个依赖会被跳过。
+	// 这个过滤是尽力而为的：它只依据版本列表中每个版本的ruby_version字段，
+	// 该字段缺失或无法解析时视为兼容，也不会因为跳过某个依赖而回溯重新选择父节点的版本。
+	TargetRubyVersion string
+}
+
+// NewTreeOptions 创建具有默认值的依赖树选项
+// 默认配置：不限制深度，不按Ruby版本过滤
+func NewTreeOptions() *TreeOptions {
+	return &TreeOptions{}
+}
+
+// WithMaxDepth 设置依赖树的最大深度
+// 返回选项对象自身，支持链式调用
+func (o *TreeOptions) WithMaxDepth(maxDepth int) *TreeOptions {
+	if maxDepth >= 0 {
+		o.MaxDepth = maxDepth
+	}
+	return o
+}
+
+// WithTargetRubyVersion 设置目标Ruby版本
+// 返回选项对象自身，支持链式调用
+func (o *TreeOptions) WithTargetRubyVersion(rubyVersion string) *TreeOptions {
+	o.TargetRubyVersion = rubyVersion
+	return o
+}
+
+// GetDependencyTree 构建gem包的运行时依赖树
+// 根节点使用最高的稳定版本，每个依赖选择满足父节点版本要求的最高版本，
+// 再通过该版本的详细信息继续展开它自己的依赖。
+// 依赖链上出现循环时，回到祖先的依赖会作为叶子节点保留，不再继续展开。
+// 参数:
+//   - ctx: 上下文，用于控制请求超时和取消
+//   - gemName: 根节点的包名
+//   - options: 依赖树选项，为nil时使用默认选项
+func (x *RepositoryImpl) GetDependencyTree(ctx context.Context, gemName string, options *TreeOptions) (*models.DependencyNode, error) {
+	if options == nil {
+		options = NewTreeOptions()
+	}
+
+	builder := &treeBuilder{
+		repo:     x,
+		options:  options,
+		versions: make(map[string][]*models.Version),
+		children: make(map[string][]*models.DependencyNode),
+		onPath:   make(map[string]bool),
+	}
+
+	version, err := builder.resolve(ctx, gemName, "")
+	if err != nil {
+		return nil, err
+	}
+	if version == nil {
+		return nil, fmt.Errorf("%w: no version of %s supports ruby %s", ErrNotFound, gemName, options.TargetRubyVersion)
+	}
+
+	root := &models.DependencyNode{
+		Name:    gemName,
+		Version: version.Number,
+	}
+	root.Children, err = builder.expand(ctx, gemName, version.Number, 1)
+	if err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// treeBuilder 保存一次依赖树构建过程中的中间状态，避免重复请求
+type treeBuilder struct {
+	repo     *RepositoryImpl
+	options  *TreeOptions
+	versions map[string][]*models.Version        // 包名 => 版本列表
+	children map[string][]*models.DependencyNode // 包名@版本 => 已展开的子节点
+	onPath   map[string]bool                     // 当前依赖链上的包名，用于发现循环
+}
+
+// resolve 为依赖选择具体版本，没有支持目标Ruby的版本时返回nil
+func (b *treeBuilder) resolve(ctx context.Context, gemName, requirement string) (*models.Version, error) {
+	parsed, err := models.ParseRequirement(requirement)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s requirement %q: %v", ErrInvalidRequest, gemName, requirement, err)
+	}
+
+	versions, ok := b.versions[gemName]
+	if !ok {
+		versions, err = b.repo.GetGemVersions(ctx, gemName)
+		if err != nil {
+			return nil, err
+		}
+		b.versions[gemName] = versions
+	}
+
+	if newestSatisfying(versions, parsed, nil) == nil {
+		return nil, fmt.Errorf("%w: no version of %s satisfies %q", ErrNotFound, gemName, requirement)
+	}
+	return newestSatisfying(versions, parsed, func(v *models.Version) bool {
+		return supportsRuby(v, b.options.TargetRubyVersion)
+	}), nil
+}
+
+// expand 展开指定版本的运行时依赖，depth是这些依赖所在的深度
+func (b *treeBuilder) expand(ctx context.Context, gemName, version string, depth int) ([]*models.DependencyNode, error) {
+	if b.options.MaxDepth > 0 && depth > b.options.MaxDepth {
+		return nil, nil
+	}
+
+	// 限制深度时，同一个版本在不同深度展开的结果不同，需要区分缓存
+	key := gemName + "@" + version
+	if b.options.MaxDepth > 0 {
+		key = fmt.Sprintf("%s#%d", key, depth)
+	}
+	if children, ok := b.children[key]; ok {
+		return children, nil
+	}
+
+	pkg, err := b.repo.GetPackageAtVersion(ctx, gemName, version)
+	if err != nil {
+		return nil, err
+	}
+
+	b.onPath[gemName] = true
+	defer delete(b.onPath, gemName)
+
+	children := make([]*models.DependencyNode, 0, len(pkg.Dependencies.Runtime))
+	for _, dependency := range pkg.Dependencies.Runtime {
+		resolved, err := b.resolve(ctx, dependency.Name, dependency.Requirements)
+		if err != nil {
+			return nil, err
+		}
+		if resolved == nil {
+			// 没有任何满足要求的版本支持目标Ruby，跳过这个依赖
+			continue
+		}
+
+		child := &models.DependencyNode{
+			Name:        dependency.Name,
+			Requirement: dependency.Requirements,
+			Version:     resolved.Number,
+		}
+		if !b.onPath[dependency.Name] {
+			child.Children, err = b.expand(ctx, dependency.Name, resolved.Number, depth+1)
+			if err != nil {
+				return nil, err
+			}
+		}
+		children = append(children, child)
+	}
+
+	b.children[key] = children
+	return children, nil
+}
+
+// ExportTreeDOT 把依赖树写为Graphviz的DOT格式，可以直接交给 dot -Tpng 渲染
+// 每个gem的每个版本是一个节点，节点ID为 "name@version"；边从依赖方指向被依赖的包，标签为版本要求。
+// 同一个版本在树中多次出现时只输出一个节点，重复的边也只输出一次
+func ExportTreeDOT(w io.Writer, root *models.DependencyNode) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "digraph dependencies {")
+	fmt.Fprintln(out, "\tnode [shape=box];")
+
+	if root != nil {
+		nodes := make(map[string]bool)
+		edges := make(map[string]bool)
+		var walk func(node *models.DependencyNode)
+		walk = func(node *models.DependencyNode) {
+			id := dotNodeID(node)
+			if nodes[id] {
+				return
+			}
+			nodes[id] = true
+			fmt.Fprintf(out, "\t%s [label=%s];\n", dotQuote(id), dotQuote(node.Name+"\n"+node.Version))
+
+			for _, child := range node.Children {
+				edge := id + " -> " + dotNodeID(child) + " " + child.Requirement
+				if !edges[edge] {
+					edges[edge] = true
+					fmt.Fprintf(out, "\t%s -> %s [label=%s];\n", dotQuote(id), dotQuote(dotNodeID(child)), dotQuote(child.Requirement))
+				}
+				walk(child)
+			}
+		}
+		walk(root)
+	}
+
+	fmt.Fprintln(out, "}")
+	return out.Flush()
+}
+
+func dotNodeID(node *models.DependencyNode) string {
+	return node.Name + "@" + node.Version
+}
+
+// dotQuote 把字符串转换为DOT中带引号的ID，换行转换为DOT的居中换行
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
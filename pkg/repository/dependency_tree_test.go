@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// 依赖树测试使用的仓库数据：
+// app 1.0.0 依赖 modern(>= 1.0)、legacy(~> 1.0)、common(>= 0)
+// modern 2.0.0 要求 ruby >= 3.0，modern 1.5.0 要求 ruby >= 2.5
+// legacy 只有 1.0.0 一个版本，要求 ruby >= 3.1
+func newDependencyTreeTestRepository(t *testing.T) *RepositoryImpl {
+	return newTestRepository(t, map[string]string{
+		"/api/v1/versions/app.json": `[{"number": "1.0.0", "platform": "ruby"}]`,
+		"/api/v1/versions/modern.json": `[
+			{"number": "2.0.0", "platform": "ruby", "ruby_version": ">= 3.0"},
+			{"number": "1.5.0", "platform": "ruby", "ruby_version": ">= 2.5"}
+		]`,
+		"/api/v1/versions/legacy.json": `[{"number": "1.0.0", "platform": "ruby", "ruby_version": ">= 3.1"}]`,
+		"/api/v1/versions/common.json": `[{"number": "0.9.0", "platform": "ruby"}]`,
+		"/api/v2/rubygems/app/versions/1.0.0.json": `{
+			"name": "app", "version": "1.0.0",
+			"dependencies": {"development": [], "runtime": [
+				{"name": "modern", "requirements": ">= 1.0"},
+				{"name": "legacy", "requirements": "~> 1.0"},
+				{"name": "common", "requirements": ">= 0"}
+			]}
+		}`,
+		"/api/v2/rubygems/modern/versions/2.0.0.json": `{"name": "modern", "version": "2.0.0", "dependencies": {"runtime": [{"name": "common", "requirements": ">= 0"}]}}`,
+		"/api/v2/rubygems/modern/versions/1.5.0.json": `{"name": "modern", "version": "1.5.0", "dependencies": {"runtime": []}}`,
+		"/api/v2/rubygems/legacy/versions/1.0.0.json": `{"name": "legacy", "version": "1.0.0", "dependencies": {"runtime": []}}`,
+		"/api/v2/rubygems/common/versions/0.9.0.json": `{"name": "common", "version": "0.9.0", "dependencies": {"runtime": []}}`,
+	})
+}
+
+func childByName(node *models.DependencyNode, name string) *models.DependencyNode {
+	for _, child := range node.Children {
+		if child.Name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+func TestGetDependencyTree(t *testing.T) {
+	repo := newDependencyTreeTestRepository(t)
+
+	tree, err := repo.GetDependencyTree(context.Background(), "app", nil)
+	assert.NoError(t, err)
+	if assert.NotNil(t, tree) {
+		assert.Equal(t, "1.0.0", tree.Version)
+		assert.Len(t, tree.Children, 3)
+
+		modern := childByName(tree, "modern")
+		if assert.NotNil(t, modern) {
+			assert.Equal(t, "2.0.0", modern.Version)
+			assert.Equal(t, ">= 1.0", modern.Requirement)
+			assert.Len(t, modern.Children, 1)
+		}
+		assert.NotNil(t, childByName(tree, "legacy"))
+	}
+}
+
+func TestGetDependencyTree_TargetRubyVersion(t *testing.T) {
+	repo := newDependencyTreeTestRepository(t)
+
+	options := NewTreeOptions().WithTargetRubyVersion("2.7.8")
+	tree, err := repo.GetDependencyTree(context.Background(), "app", options)
+	assert.NoError(t, err)
+	if assert.NotNil(t, tree) {
+		// modern 退回到支持 ruby 2.7 的 1.5.0
+		modern := childByName(tree, "modern")
+		if assert.NotNil(t, modern) {
+			assert.Equal(t, "1.5.0", modern.Version)
+			assert.Empty(t, modern.Children)
+		}
+
+		// legacy 没有支持 ruby 2.7 的版本，被跳过
+		assert.Nil(t, childByName(tree, "legacy"))
+
+		// common 没有声明ruby版本要求，视为兼容
+		assert.NotNil(t, childByName(tree, "common"))
+	}
+}
+
+func TestGetDependencyTree_MaxDepth(t *testing.T) {
+	repo := newDependencyTreeTestRepository(t)
+
+	tree, err := repo.GetDependencyTree(context.Background(), "app", NewTreeOptions().WithMaxDepth(1))
+	assert.NoError(t, err)
+	if assert.NotNil(t, tree) {
+		modern := childByName(tree, "modern")
+		if assert.NotNil(t, modern) {
+			assert.Empty(t, modern.Children)
+		}
+	}
+}
+
+func TestExportTreeDOT(t *testing.T) {
+	repo := newDependencyTreeTestRepository(t)
+	tree, err := repo.GetDependencyTree(context.Background(), "app", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, ExportTreeDOT(&buf, tree))
+	dot := buf.String()
+
+	assert.True(t, strings.HasPrefix(dot, "digraph dependencies {\n"))
+	assert.True(t, strings.HasSuffix(dot, "}\n"))
+	assert.Contains(t, dot, `"app@1.0.0" [label="app\n1.0.0"];`)
+	assert.Contains(t, dot, `"app@1.0.0" -> "modern@2.0.0" [label=">= 1.0"];`)
+	assert.Contains(t, dot, `"app@1.0.0" -> "common@0.9.0" [label=">= 0"];`)
+	assert.Contains(t, dot, `"modern@2.0.0" -> "common@0.9.0" [label=">= 0"];`)
+
+	// common被两个包依赖，但只声明一次
+	assert.Equal(t, 1, strings.Count(dot, "\t\"common@0.9.0\" [label="))
+
+	buf.Reset()
+	assert.NoError(t, ExportTreeDOT(&buf, nil))
+	assert.Equal(t, "digraph dependencies {\n\tnode [shape=box];\n}\n", buf.String())
+}
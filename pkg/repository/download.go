@@ -0,0 +1,323 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// downloadBufferSize 下载时每次读取的字节数，也决定了进度回调的频率
+const downloadBufferSize = 32 * 1024
+
+// DownloadGem 下载gem包文件并以流的方式写入w，不会把整个文件读入内存，返回写入的字节数
+// 版本不存在或者已经被撤回时返回ErrNotFound
+// GET - /gems/[GEM NAME]-[GEM VERSION].gem
+func (x *RepositoryImpl) DownloadGem(ctx context.Context, gemName, version string, w io.Writer) (int64, error) {
+	return x.DownloadGemWithProgress(ctx, gemName, version, w, nil)
+}
+
+// DownloadGemWithProgress 下载gem包文件并报告进度，返回写入的字节数
+// 每写入一块数据调用一次onProgress，written为已写入的字节数，total为响应的Content-Length，未知时为-1。
+// 下载过程中可以通过ctx取消。下载失败或被取消时，如果w支持Seek和Truncate（例如*os.File），
+// 会把w恢复到下载开始前的位置和大小；其它类型的w可能已经写入了部分数据，需要调用方自行丢弃。
+// 请求使用Options中的代理和重试设置，但只有在还没有写入数据、或者w可以恢复时才会重试，避免重复写入
+// 参数:
+//   - ctx: 上下文，用于控制请求超时和取消
+//   - gemName: 包名
+//   - version: 版本号
+//   - w: 写入gem文件内容的目标
+//   - onProgress: 进度回调，可以为nil
+func (x *RepositoryImpl) DownloadGemWithProgress(ctx context.Context, gemName, version string, w io.Writer, onProgress func(written, total int64)) (int64, error) {
+	rewind, err := rewindPoint(w)
+	if err != nil {
+		return 0, err
+	}
+
+	maxAttempts := 1
+	if x.options.RetryOptions != nil && x.options.RetryOptions.MaxAttempts > 1 {
+		maxAttempts = x.options.RetryOptions.MaxAttempts
+	}
+
+	request := &apiRequest{
+		operation: OperationDownloadGem,
+		url:       x.GemDownloadURL(gemName, version, ""),
+		streaming: true,
+	}
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(x.options.RetryOptions.backoff(attempt, x.rand)):
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+			x.retries.record(err)
+		}
+
+		var written int64
+		written, err = doRequest(ctx, x, request, func(resp *http.Response) (int64, error) {
+			if resp.StatusCode != http.StatusOK {
+				return 0, responseStatusError(resp)
+			}
+			defer resp.Body.Close()
+			return copyWithProgress(ctx, w, resp.Body, resp.ContentLength, onProgress)
+		})
+		if err == nil {
+			return written, nil
+		}
+
+		// 写入了部分数据而w无法恢复时不能重试，否则w中会出现重复的数据
+		retry := attempt+1 < maxAttempts && ctx.Err() == nil && (IsTransient(err) || errors.Is(err, io.ErrUnexpectedEOF))
+		if rewind != nil {
+			if rewindErr := rewind(); rewindErr != nil {
+				return written, fmt.Errorf("%w (cleanup partial download: %v)", err, rewindErr)
+			}
+			written = 0
+		}
+		if !retry || written > 0 {
+			return written, err
+		}
+	}
+}
+
+// truncatableWriter 是可以撤销部分写入的目标，例如*os.File
+type truncatableWriter interface {
+	io.Seeker
+	Truncate(size int64) error
+}
+
+// rewindPoint 记录w当前的位置，返回把w恢复到这个位置的函数；w不支持时返回nil
+func rewindPoint(w io.Writer) (func() error, error) {
+	t, ok := w.(truncatableWriter)
+	if !ok {
+		return nil, nil
+	}
+	start, err := t.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		if err := t.Truncate(start); err != nil {
+			return err
+		}
+		_, err := t.Seek(start, io.SeekStart)
+		return err
+	}, nil
+}
+
+// copyWithProgress 把r的内容复制到w，每写入一块数据报告一次进度
+// 已知总大小而实际读到的数据不足时返回io.ErrUnexpectedEOF
+func copyWithProgress(ctx context.Context, w io.Writer, r io.Reader, total int64, onProgress func(written, total int64)) (int64, error) {
+	buf := make([]byte, downloadBufferSize)
+	var written int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return written, err
+			}
+			written += int64(n)
+			if onProgress != nil {
+				onProgress(written, total)
+			}
+		}
+
+		if readErr == io.EOF {
+			if total >= 0 && written != total {
+				return written, io.ErrUnexpectedEOF
+			}
+			return written, nil
+		}
+		if readErr != nil {
+			if err := ctx.Err(); err != nil {
+				return written, err
+			}
+			return written, readErr
+		}
+	}
+}
+
+// GemDownloadURL 返回官方仓库中gem文件的下载地址
+// 原生扩展的gem每个平台有单独的gem文件，文件名带有平台后缀，例如 "nokogiri-1.15.4-x86_64-linux.gem"；
+// platform为空或者为"ruby"时返回纯ruby版本的地址，与GemURI相同
+func GemDownloadURL(gemName, version, platform string) string {
+	return gemDownloadURL(DefaultServerURL, gemName, version, platform)
+}
+
+// GemDownloadURL 返回当前仓库中gem文件的下载地址，规则与包级函数GemDownloadURL相同
+// GET - /gems/[GEM NAME]-[GEM VERSION](-[PLATFORM]).gem
+func (x *RepositoryImpl) GemDownloadURL(gemName, version, platform string) string {
+	return gemDownloadURL(x.options.ServerURL, gemName, version, platform)
+}
+
+func gemDownloadURL(serverURL, gemName, version, platform string) string {
+	return fmt.Sprintf("%s/gems/%s.gem", serverURL, gemFullName(gemName, version, platform))
+}
+
+// GemVersionRef 指定要下载的gem包版本
+type GemVersionRef struct {
+	Name    string
+	Version string
+
+	// gem文件的SHA256校验和（十六进制），可以取自版本列表中的Version.Sha，为空时使用GetGemVersion返回的sha校验
+	Sha string
+}
+
+// FileName 返回gem文件的文件名，例如 "rails-7.1.2.gem"
+func (r GemVersionRef) FileName() string {
+	return fmt.Sprintf("%s-%s.gem", r.Name, r.Version)
+}
+
+// DownloadGems 并发下载多个gem包到destDir，文件名为 "gem-version.gem"
+// 每个结果的Key为文件名，Value为下载后的文件路径。下载的文件总是会被校验，不一致时返回ErrChecksumMismatch：
+// 提供了Sha时使用它，否则像DownloadGemVerified一样使用仓库返回的sha，仓库也没有提供sha时返回ErrUnsupportedOperation。
+// destDir中已经存在且校验和与Sha一致的文件会被跳过，没有提供Sha时总是重新下载。
+// 文件先下载到destDir中的临时文件，校验通过后再重命名，失败时不会留下不完整的文件
+func (x *RepositoryImpl) DownloadGems(ctx context.Context, refs []GemVersionRef, destDir string, options *BulkOptions) []*BulkResult[string] {
+	keys := make([]string, len(refs))
+	byKey := make(map[string]GemVersionRef, len(refs))
+	for i, ref := range refs {
+		keys[i] = ref.FileName()
+		byKey[keys[i]] = ref
+	}
+	return bulkExecute(ctx, keys, options, func(ctx context.Context, key string) (string, error) {
+		return x.downloadGemFile(ctx, byKey[key], destDir)
+	})
+}
+
+// downloadGemFile 下载单个gem包到destDir，返回文件路径
+func (x *RepositoryImpl) downloadGemFile(ctx context.Context, ref GemVersionRef, destDir string) (string, error) {
+	path := filepath.Join(destDir, ref.FileName())
+	if ref.Sha != "" {
+		if err := verifyFileChecksum(path, ref.Sha); err == nil {
+			return path, nil
+		}
+	}
+
+	file, err := os.CreateTemp(destDir, "."+ref.FileName()+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	tempPath := file.Name()
+	defer os.Remove(tempPath)
+
+	if ref.Sha != "" {
+		_, err = x.DownloadGem(ctx, ref.Name, ref.Version, file)
+	} else {
+		err = x.DownloadGemVerified(ctx, ref.Name, ref.Version, file)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	if ref.Sha != "" {
+		if err := verifyFileChecksum(tempPath, ref.Sha); err != nil {
+			return "", fmt.Errorf("%s: %w", ref.FileName(), err)
+		}
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// verifyFileChecksum 校验文件的SHA256，不一致时返回ErrChecksumMismatch
+func verifyFileChecksum(path, sha string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return err
+	}
+	return verifyChecksum(hash, sha)
+}
+
+// verifyChecksum 比较已经写入hash的内容与期望的SHA256（十六进制），不一致时返回ErrChecksumMismatch
+func verifyChecksum(h hash.Hash, sha string) error {
+	if actual := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(actual, strings.TrimSpace(sha)) {
+		return fmt.Errorf("%w: expected sha256 %s, got %s", ErrChecksumMismatch, sha, actual)
+	}
+	return nil
+}
+
+// DownloadGemVerified 下载gem包文件写入w，同时计算SHA256并与GetGemVersion返回的sha比较
+// 不一致时返回ErrChecksumMismatch，错误信息中包含期望和实际的校验和，可以发现镜像返回的损坏文件。
+// 与DownloadGemWithProgress一样，w支持Seek和Truncate时下载失败或者校验失败都会把w恢复到下载开始前的状态。
+// 仓库没有提供这个版本的sha时无法校验，返回ErrUnsupportedOperation
+func (x *RepositoryImpl) DownloadGemVerified(ctx context.Context, gemName, version string, w io.Writer) error {
+	info, err := x.GetGemVersion(ctx, gemName, version)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(info.Sha) == "" {
+		return fmt.Errorf("%w: no sha256 for %s-%s", ErrUnsupportedOperation, gemName, version)
+	}
+
+	rewind, err := rewindPoint(w)
+	if err != nil {
+		return err
+	}
+	hashing := &hashingWriter{w: w, hash: sha256.New()}
+	var target io.Writer = hashing
+	if t, ok := w.(truncatableWriter); ok {
+		target = &truncatableHashingWriter{hashingWriter: hashing, t: t}
+	}
+	if _, err := x.DownloadGem(ctx, gemName, version, target); err != nil {
+		return err
+	}
+	if err := verifyChecksum(hashing.hash, info.Sha); err != nil {
+		if rewind != nil {
+			if rewindErr := rewind(); rewindErr != nil {
+				return fmt.Errorf("%w (cleanup corrupted download: %v)", err, rewindErr)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// hashingWriter 在写入w的同时计算写入内容的摘要
+type hashingWriter struct {
+	w    io.Writer
+	hash hash.Hash
+}
+
+func (h *hashingWriter) Write(p []byte) (int, error) {
+	n, err := h.w.Write(p)
+	h.hash.Write(p[:n])
+	return n, err
+}
+
+// truncatableHashingWriter 保留w的Seek和Truncate，使下载失败后的清理和重试仍然可用
+// 下载只会把w截断回开始下载的位置，所以截断时摘要也从头开始计算
+type truncatableHashingWriter struct {
+	*hashingWriter
+	t truncatableWriter
+}
+
+func (h *truncatableHashingWriter) Seek(offset int64, whence int) (int64, error) {
+	return h.t.Seek(offset, whence)
+}
+
+func (h *truncatableHashingWriter) Truncate(size int64) error {
+	h.hash.Reset()
+	return h.t.Truncate(size)
+}
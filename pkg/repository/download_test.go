@@ -0,0 +1,285 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_DownloadGemWithProgress(t *testing.T) {
+	content := bytes.Repeat([]byte("gem-data"), 10000) // 80000字节，会分成多块
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/gems/rails-7.0.5.gem" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+
+	repo := NewRepository(NewOptions().SetServerURL(server.URL))
+
+	var buf bytes.Buffer
+	calls := 0
+	var lastWritten, lastTotal int64
+	n, err := repo.DownloadGemWithProgress(context.Background(), "rails", "7.0.5", &buf, func(written, total int64) {
+		calls++
+		assert.GreaterOrEqual(t, written, lastWritten)
+		lastWritten, lastTotal = written, total
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(content)), n)
+	assert.Equal(t, content, buf.Bytes())
+	assert.Greater(t, calls, 1)
+	assert.Equal(t, int64(len(content)), lastTotal)
+	assert.Equal(t, lastTotal, lastWritten)
+
+	n, err = repo.DownloadGem(context.Background(), "missing", "1.0.0", &bytes.Buffer{})
+	assert.True(t, IsNotFound(err))
+	assert.Equal(t, int64(0), n)
+}
+
+func TestRepository_DownloadGem_Retry(t *testing.T) {
+	var mu sync.Mutex
+	requested := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested[r.URL.Path]++
+		count := requested[r.URL.Path]
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/gems/rack-3.0.8.gem":
+			// 第一次请求返回503，重试后成功
+			if count == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte("rack gem content"))
+		default:
+			// 被撤回的版本返回404
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	retryOptions := NewDefaultRetryOptions().WithMaxAttempts(3).WithWaitTime(time.Millisecond).WithMaxWaitTime(time.Millisecond)
+	repo := NewRepository(NewOptions().SetServerURL(server.URL).SetRetryOptions(retryOptions))
+
+	var buf bytes.Buffer
+	n, err := repo.DownloadGem(context.Background(), "rack", "3.0.8", &buf)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len("rack gem content")), n)
+	assert.Equal(t, "rack gem content", buf.String())
+	assert.Equal(t, 2, requested["/gems/rack-3.0.8.gem"])
+	assert.Equal(t, int64(1), repo.RetryStats().Retries)
+
+	// 404不会重试
+	_, err = repo.DownloadGem(context.Background(), "rack", "0.0.1", &bytes.Buffer{})
+	assert.True(t, IsNotFound(err))
+	assert.Equal(t, 1, requested["/gems/rack-0.0.1.gem"])
+}
+
+func TestRepository_DownloadGemVerified(t *testing.T) {
+	sum := sha256.Sum256([]byte("rack gem content"))
+	expected := hex.EncodeToString(sum[:])
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/versions/rack/3.0.8.json", "/api/v1/versions/rack/3.0.7.json":
+			_, _ = w.Write([]byte(`{"number": "3.0.8", "sha": "` + expected + `"}`))
+		case "/api/v1/versions/rack/0.1.0.json":
+			_, _ = w.Write([]byte(`{"number": "0.1.0", "sha": null}`))
+		case "/gems/rack-3.0.8.gem":
+			_, _ = w.Write([]byte("rack gem content"))
+		case "/gems/rack-3.0.7.gem":
+			// 镜像返回了损坏的文件
+			_, _ = w.Write([]byte("rack gem cont\x00nt"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	repo := NewRepository(NewOptions().SetServerURL(server.URL).DisableRetry())
+
+	var buf bytes.Buffer
+	assert.NoError(t, repo.DownloadGemVerified(context.Background(), "rack", "3.0.8", &buf))
+	assert.Equal(t, "rack gem content", buf.String())
+
+	// 校验失败时错误信息包含两个校验和，文件中损坏的内容被清理掉
+	file, err := os.Create(filepath.Join(t.TempDir(), "rack-3.0.7.gem"))
+	assert.NoError(t, err)
+	defer file.Close()
+	err = repo.DownloadGemVerified(context.Background(), "rack", "3.0.7", file)
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	if assert.Error(t, err) {
+		actual := sha256.Sum256([]byte("rack gem cont\x00nt"))
+		assert.Contains(t, err.Error(), expected)
+		assert.Contains(t, err.Error(), hex.EncodeToString(actual[:]))
+	}
+	info, err := file.Stat()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), info.Size())
+
+	err = repo.DownloadGemVerified(context.Background(), "rack", "0.1.0", &bytes.Buffer{})
+	assert.ErrorIs(t, err, ErrUnsupportedOperation)
+
+	err = repo.DownloadGemVerified(context.Background(), "rack", "9.9.9", &bytes.Buffer{})
+	assert.True(t, IsNotFound(err))
+}
+
+func TestRepository_DownloadGemWithProgress_Cancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1000000")
+		_, _ = w.Write(bytes.Repeat([]byte("x"), 1000))
+		w.(http.Flusher).Flush()
+		// 剩余的数据迟迟不来，直到客户端取消
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	repo := NewRepository(NewOptions().SetServerURL(server.URL))
+
+	file, err := os.Create(filepath.Join(t.TempDir(), "rails-7.0.5.gem"))
+	assert.NoError(t, err)
+	defer file.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err = repo.DownloadGemWithProgress(ctx, "rails", "7.0.5", file, func(written, total int64) {
+		cancel()
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// 已经写入的部分数据会被清理掉
+	info, err := file.Stat()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), info.Size())
+}
+
+func TestRepository_DownloadGems(t *testing.T) {
+	files := map[string][]byte{
+		"/gems/rack-3.0.8.gem":  []byte("rack gem content"),
+		"/gems/rails-7.1.2.gem": []byte("rails gem content"),
+		"/gems/puma-6.4.0.gem":  []byte("tampered content"),
+	}
+	var mu sync.Mutex
+	requested := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested[r.URL.Path]++
+		mu.Unlock()
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+
+	sha := func(content string) string {
+		sum := sha256.Sum256([]byte(content))
+		return hex.EncodeToString(sum[:])
+	}
+
+	dir := t.TempDir()
+	// 已经存在且校验和一致的文件不会重新下载
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "rails-7.1.2.gem"), files["/gems/rails-7.1.2.gem"], 0644))
+
+	repo := NewRepository(NewOptions().SetServerURL(server.URL))
+	refs := []GemVersionRef{
+		{Name: "rack", Version: "3.0.8", Sha: sha("rack gem content")},
+		{Name: "rails", Version: "7.1.2", Sha: sha("rails gem content")},
+		{Name: "puma", Version: "6.4.0", Sha: sha("puma gem content")},
+		{Name: "missing", Version: "1.0.0"},
+	}
+	results := repo.DownloadGems(context.Background(), refs, dir, NewBulkOptions().WithMaxConcurrency(2))
+
+	if assert.Len(t, results, 4) {
+		assert.NoError(t, results[0].Error)
+		assert.Equal(t, filepath.Join(dir, "rack-3.0.8.gem"), results[0].Value)
+		content, err := os.ReadFile(results[0].Value)
+		assert.NoError(t, err)
+		assert.Equal(t, "rack gem content", string(content))
+
+		assert.NoError(t, results[1].Error)
+		assert.Equal(t, filepath.Join(dir, "rails-7.1.2.gem"), results[1].Value)
+
+		assert.ErrorIs(t, results[2].Error, ErrChecksumMismatch)
+		assert.True(t, IsNotFound(results[3].Error))
+	}
+
+	// 失败的下载不会留下文件或临时文件
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.ElementsMatch(t, []string{"rack-3.0.8.gem", "rails-7.1.2.gem"}, names)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 0, requested["/gems/rails-7.1.2.gem"])
+	assert.Equal(t, 1, requested["/gems/rack-3.0.8.gem"])
+}
+
+// 测试没有提供Sha时使用仓库返回的sha校验下载的文件
+func TestRepository_DownloadGemsWithoutSha(t *testing.T) {
+	sha := func(content string) string {
+		sum := sha256.Sum256([]byte(content))
+		return hex.EncodeToString(sum[:])
+	}
+	repo := newTestRepository(t, map[string]string{
+		"/gems/rack-3.0.8.gem":               "rack gem content",
+		"/gems/puma-6.4.0.gem":               "tampered content",
+		"/gems/legacy-0.1.0.gem":             "legacy gem content",
+		"/api/v1/versions/rack/3.0.8.json":   `{"number": "3.0.8", "sha": "` + sha("rack gem content") + `"}`,
+		"/api/v1/versions/puma/6.4.0.json":   `{"number": "6.4.0", "sha": "` + sha("puma gem content") + `"}`,
+		"/api/v1/versions/legacy/0.1.0.json": `{"number": "0.1.0"}`,
+	})
+
+	dir := t.TempDir()
+	results := repo.DownloadGems(context.Background(), []GemVersionRef{
+		{Name: "rack", Version: "3.0.8"},
+		{Name: "puma", Version: "6.4.0"},
+		{Name: "legacy", Version: "0.1.0"},
+	}, dir, nil)
+
+	if assert.Len(t, results, 3) {
+		assert.NoError(t, results[0].Error)
+		assert.Equal(t, filepath.Join(dir, "rack-3.0.8.gem"), results[0].Value)
+		assert.ErrorIs(t, results[1].Error, ErrChecksumMismatch)
+		// 仓库也没有提供sha时无法校验，不会写入未经校验的文件
+		assert.ErrorIs(t, results[2].Error, ErrUnsupportedOperation)
+	}
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "rack-3.0.8.gem", entries[0].Name())
+	}
+}
+
+func TestGemDownloadURL(t *testing.T) {
+	assert.Equal(t, "https://rubygems.org/gems/rails-7.0.5.gem", GemDownloadURL("rails", "7.0.5", ""))
+	assert.Equal(t, "https://rubygems.org/gems/rails-7.0.5.gem", GemDownloadURL("rails", "7.0.5", "ruby"))
+	assert.Equal(t, "https://rubygems.org/gems/rails-7.0.5-java.gem", GemDownloadURL("rails", "7.0.5", "java"))
+	assert.Equal(t, "https://rubygems.org/gems/nokogiri-1.15.4-x86_64-linux.gem", GemDownloadURL("nokogiri", "1.15.4", "x86_64-linux"))
+
+	repo := NewRepository(NewOptions().SetServerURL("https://gems.ruby-china.com"))
+	assert.Equal(t, "https://gems.ruby-china.com/gems/rails-7.0.5.gem", repo.GemDownloadURL("rails", "7.0.5", "ruby"))
+	assert.Equal(t, "https://gems.ruby-china.com/gems/rails-7.0.5-java.gem", repo.GemDownloadURL("rails", "7.0.5", "java"))
+}
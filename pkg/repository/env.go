@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"os"
+	"strings"
+)
+
+// 读取配置的环境变量名称
+const (
+	EnvServerURL = "RUBYGEMS_SERVER_URL"
+	EnvToken     = "RUBYGEMS_TOKEN"
+	EnvProxy     = "RUBYGEMS_PROXY"
+	EnvMirror    = "RUBYGEMS_MIRROR"
+)
+
+// mirrorServerURLs 是RUBYGEMS_MIRROR可以使用的镜像名称
+var mirrorServerURLs = map[string]string{
+	"rubygems":   DefaultServerURL,
+	"ruby-china": ServerURLRubyChina,
+	"tsinghua":   ServerURLTSingHua,
+	"aliyun":     ServerURLAliYun,
+}
+
+// OptionsFromEnv 在默认选项的基础上读取环境变量中的配置，方便命令行和容器中使用，不需要在脚本里写死配置
+//   - RUBYGEMS_SERVER_URL: 仓库地址
+//   - RUBYGEMS_MIRROR: 镜像名称，可以是ruby-china、tsinghua、aliyun或rubygems，同时设置了RUBYGEMS_SERVER_URL时不生效
+//   - RUBYGEMS_TOKEN: API Token
+//   - RUBYGEMS_PROXY: 代理地址
+//
+// 没有设置或者为空的环境变量保持默认值。返回的选项可以继续通过Set方法修改，
+// 所以优先级是代码中的设置 > 环境变量 > 默认值。
+// 不认识的镜像名称按仓库地址处理，创建仓库时会由Options.Validate报告错误
+func OptionsFromEnv() *Options {
+	options := NewOptions()
+
+	if mirror := strings.TrimSpace(os.Getenv(EnvMirror)); mirror != "" {
+		if serverURL, ok := mirrorServerURLs[strings.ToLower(mirror)]; ok {
+			options.SetServerURL(serverURL)
+		} else {
+			options.SetServerURL(mirror)
+		}
+	}
+	if serverURL := strings.TrimSpace(os.Getenv(EnvServerURL)); serverURL != "" {
+		options.SetServerURL(serverURL)
+	}
+	if token := strings.TrimSpace(os.Getenv(EnvToken)); token != "" {
+		options.SetToken(token)
+	}
+	if proxy := strings.TrimSpace(os.Getenv(EnvProxy)); proxy != "" {
+		options.SetProxy(proxy)
+	}
+	return options
+}
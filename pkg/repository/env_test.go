@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptionsFromEnv(t *testing.T) {
+	t.Setenv(EnvServerURL, "")
+	t.Setenv(EnvToken, "")
+	t.Setenv(EnvProxy, "")
+	t.Setenv(EnvMirror, "")
+
+	// 没有设置环境变量时与默认选项一致
+	options := OptionsFromEnv()
+	assert.Equal(t, DefaultServerURL, options.ServerURL)
+	assert.Empty(t, options.Token)
+	assert.Empty(t, options.Proxy)
+
+	t.Setenv(EnvToken, "rubygems_secret")
+	t.Setenv(EnvProxy, "http://127.0.0.1:7890")
+	t.Setenv(EnvMirror, "Ruby-China")
+	options = OptionsFromEnv()
+	assert.Equal(t, ServerURLRubyChina, options.ServerURL)
+	assert.Equal(t, "rubygems_secret", options.Token)
+	assert.Equal(t, "http://127.0.0.1:7890", options.Proxy)
+
+	t.Setenv(EnvMirror, "tsinghua")
+	assert.Equal(t, ServerURLTSingHua, OptionsFromEnv().ServerURL)
+	t.Setenv(EnvMirror, "aliyun")
+	assert.Equal(t, ServerURLAliYun, OptionsFromEnv().ServerURL)
+
+	// 明确的仓库地址优先于镜像名称
+	t.Setenv(EnvServerURL, "https://gems.internal.example.com")
+	assert.Equal(t, "https://gems.internal.example.com", OptionsFromEnv().ServerURL)
+
+	// 代码中的设置优先于环境变量
+	options = OptionsFromEnv().SetToken("explicit").SetServerURL(DefaultServerURL)
+	assert.Equal(t, "explicit", options.Token)
+	assert.Equal(t, DefaultServerURL, options.ServerURL)
+
+	// 不认识的镜像名称由Validate报告
+	t.Setenv(EnvServerURL, "")
+	t.Setenv(EnvMirror, "nowhere")
+	assert.Error(t, OptionsFromEnv().Validate())
+}
@@ -1,9 +1,13 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 )
 
 var (
@@ -27,6 +31,22 @@ var (
 
 	// ErrNetworkFailure 网络故障
 	ErrNetworkFailure = errors.New("network failure")
+
+	// ErrUnsupportedOperation 仓库不支持该操作，或者请求的数据不存在
+	ErrUnsupportedOperation = errors.New("unsupported operation")
+
+	// ErrChecksumMismatch 下载的文件与期望的校验和不一致
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+
+	// ErrResponseMismatch 响应的内容与请求不符，例如镜像返回了另一个gem的数据
+	ErrResponseMismatch = errors.New("response does not match request")
+
+	// ErrMaxDurationExceeded 批量操作超过了BulkOptions.MaxDuration，没有完成的键使用这个错误
+	// 它包装了context.DeadlineExceeded，SummarizeBulk会把这些结果计为中止
+	ErrMaxDurationExceeded = fmt.Errorf("bulk operation exceeded max duration: %w", context.DeadlineExceeded)
+
+	// ErrTimeframeTooLarge 查询的时间段超过了接口允许的最大跨度，具体的最大跨度见TimeframeTooLargeError
+	ErrTimeframeTooLarge = errors.New("timeframe too large")
 )
 
 // APIError 表示API调用时遇到的错误
@@ -49,6 +69,11 @@ func (e *APIError) Error() string {
 	return fmt.Sprintf("API error (status: %d, url: %s): %v", e.StatusCode, e.URL, e.Cause)
 }
 
+// Unwrap 返回错误原因，使errors.Is(err, ErrNotFound)这样针对哨兵错误的判断可以穿过APIError
+func (e *APIError) Unwrap() error {
+	return e.Cause
+}
+
 // 从HTTP响应创建APIError
 func NewAPIError(resp *http.Response, body []byte, cause error) *APIError {
 	return &APIError{
@@ -59,6 +84,24 @@ func NewAPIError(resp *http.Response, body []byte, cause error) *APIError {
 	}
 }
 
+// statusCause 返回HTTP状态码对应的错误原因，用作APIError.Cause
+// 404对应ErrNotFound，429对应ErrRateLimited，401和403对应ErrUnauthorized，其它4xx对应ErrInvalidRequest，
+// 5xx以及其它不符合预期的状态码对应ErrServerError
+func statusCause(statusCode int) error {
+	switch {
+	case statusCode == http.StatusNotFound:
+		return ErrNotFound
+	case statusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return ErrUnauthorized
+	case statusCode >= 400 && statusCode < 500:
+		return ErrInvalidRequest
+	default:
+		return ErrServerError
+	}
+}
+
 // IsNotFound 检查错误是否为资源未找到
 func IsNotFound(err error) bool {
 	var apiErr *APIError
@@ -77,11 +120,122 @@ func IsRateLimited(err error) bool {
 	return errors.Is(err, ErrRateLimited)
 }
 
-// IsUnauthorized 检查错误是否为未授权
+// IsUnauthorized 检查错误是否为未授权，401和403都视为未授权
 func IsUnauthorized(err error) bool {
 	var apiErr *APIError
 	if errors.As(err, &apiErr) {
-		return apiErr.StatusCode == http.StatusUnauthorized
+		return apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden
 	}
 	return errors.Is(err, ErrUnauthorized)
 }
+
+// IsTransient 检查错误是否为暂时性的，稍后重试可能成功
+// 包括限流、5xx服务器错误、请求超时和网络故障
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if IsRateLimited(err) {
+		return true
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= http.StatusInternalServerError || apiErr.StatusCode == http.StatusRequestTimeout
+	}
+
+	if errors.Is(err, ErrServerError) || errors.Is(err, ErrTimeout) || errors.Is(err, ErrNetworkFailure) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// TimeframeTooLargeError 表示timeframe_versions接口因为时间跨度过大拒绝了请求
+// errors.Is(err, ErrTimeframeTooLarge)可以判断这种错误，errors.As可以取出服务端允许的最大跨度
+type TimeframeTooLargeError struct {
+	// 服务端允许的最大跨度，from和to需要相差小于这个值；响应中没有给出时为0
+	MaxSpan time.Duration
+
+	// 服务端返回的原始错误
+	Err *APIError
+}
+
+// 实现Error接口
+func (e *TimeframeTooLargeError) Error() string {
+	if e.MaxSpan <= 0 {
+		return ErrTimeframeTooLarge.Error()
+	}
+	return fmt.Sprintf("%v: max span is %s", ErrTimeframeTooLarge, e.MaxSpan)
+}
+
+// Is 使errors.Is(err, ErrTimeframeTooLarge)返回true
+func (e *TimeframeTooLargeError) Is(target error) bool {
+	return target == ErrTimeframeTooLarge
+}
+
+// Unwrap 返回服务端返回的原始错误
+func (e *TimeframeTooLargeError) Unwrap() error {
+	if e.Err == nil {
+		return nil
+	}
+	return e.Err
+}
+
+// BackendError 表示某个后端仓库的请求失败，Backend是后端的标识，例如仓库地址
+type BackendError struct {
+	Backend string
+	Err     error
+}
+
+// 实现Error接口
+func (e *BackendError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Backend, e.Err)
+}
+
+// Unwrap 返回后端返回的原始错误
+func (e *BackendError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError 汇总多个错误，例如故障转移仓库中每个后端各自的失败原因
+// errors.Is和errors.As会依次检查其中的每个错误
+type MultiError struct {
+	Errors []error
+}
+
+// 实现Error接口
+func (e *MultiError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("%d errors occurred: %s", len(e.Errors), strings.Join(messages, "; "))
+}
+
+// Unwrap 返回汇总的全部错误
+func (e *MultiError) Unwrap() []error {
+	return e.Errors
+}
+
+// Is 在任意一个汇总的错误匹配target时返回true，使旧版本Go的errors.Is也能检查每个错误
+func (e *MultiError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As 把第一个能匹配target的汇总错误赋值给target，使旧版本Go的errors.As也能检查每个错误
+func (e *MultiError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
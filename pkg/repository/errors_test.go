@@ -108,6 +108,10 @@ func TestIsUnauthorized(t *testing.T) {
 	}
 	assert.True(t, IsUnauthorized(apiErr), "401 API错误应该被识别为Unauthorized")
 
+	// 测试403状态码的API错误
+	apiErr.StatusCode = http.StatusForbidden
+	assert.True(t, IsUnauthorized(apiErr), "403 API错误应该被识别为Unauthorized")
+
 	// 测试其他错误
 	assert.False(t, IsUnauthorized(errors.New("random error")), "随机错误不应该被识别为Unauthorized")
 
@@ -142,6 +146,13 @@ func TestErrorWrapping(t *testing.T) {
 	var extractedAPIErr *APIError
 	assert.True(t, errors.As(wrappedErr2, &extractedAPIErr), "errors.As应该能提取API错误")
 	assert.Equal(t, http.StatusNotFound, extractedAPIErr.StatusCode, "提取的API错误应该保留状态码")
+
+	// errors.Is可以通过Unwrap找到错误原因
+	assert.True(t, errors.Is(apiErr, ErrNotFound), "errors.Is应该能识别API错误的原因")
+	assert.True(t, errors.Is(wrappedErr2, ErrNotFound), "errors.Is应该能穿过多层包装识别API错误的原因")
+	assert.False(t, errors.Is(wrappedErr2, ErrRateLimited), "不相关的哨兵错误不应该匹配")
+	assert.True(t, errors.Is(&APIError{StatusCode: http.StatusTooManyRequests, Cause: ErrRateLimited}, ErrRateLimited))
+	assert.Nil(t, (&APIError{StatusCode: http.StatusInternalServerError}).Unwrap())
 }
 
 // 测试不同错误类型
@@ -165,3 +176,13 @@ func TestErrorTypes(t *testing.T) {
 		}
 	}
 }
+
+// 测试暂时性错误判断
+func TestIsTransient(t *testing.T) {
+	assert.True(t, IsTransient(&APIError{StatusCode: http.StatusServiceUnavailable}), "503应该被识别为暂时性错误")
+	assert.True(t, IsTransient(&APIError{StatusCode: http.StatusTooManyRequests}), "429应该被识别为暂时性错误")
+	assert.True(t, IsTransient(fmt.Errorf("wrapped: %w", ErrTimeout)), "包装的超时错误应该被识别为暂时性错误")
+	assert.False(t, IsTransient(&APIError{StatusCode: http.StatusNotFound}), "404不应该被识别为暂时性错误")
+	assert.False(t, IsTransient(errors.New("boom")), "普通错误不应该被识别为暂时性错误")
+	assert.False(t, IsTransient(nil), "nil不应该被识别为暂时性错误")
+}
@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+)
+
+// FailoverRepository 按顺序使用多个后端仓库，前一个后端请求失败时自动尝试下一个
+// 适合把官方仓库和若干镜像组合在一起使用。所有后端都失败时返回*MultiError，
+// 其中每个错误都是*BackendError，记录了后端的标识和失败原因，
+// 可以用errors.Is/errors.As检查是全部限流、全部5xx还是各不相同
+type FailoverRepository struct {
+	backends []Repository
+	names    []string
+}
+
+var _ Repository = (*FailoverRepository)(nil)
+
+// NewFailoverRepository 创建故障转移仓库，backends按优先级从高到低排列
+// 后端的标识为仓库地址，无法获取地址的后端使用 "backend #N"
+func NewFailoverRepository(backends ...Repository) *FailoverRepository {
+	names := make([]string, len(backends))
+	for i, backend := range backends {
+		names[i] = backendName(i, backend)
+	}
+	return &FailoverRepository{backends: backends, names: names}
+}
+
+// backendName 返回后端在错误信息中使用的标识
+func backendName(index int, backend Repository) string {
+	switch repo := backend.(type) {
+	case *RepositoryImpl:
+		return repo.options.ServerURL
+	case *CachedRepository:
+		return backendName(index, repo.repo)
+	}
+	return fmt.Sprintf("backend #%d", index+1)
+}
+
+// failover 依次在每个后端上调用fn，返回第一个成功的结果
+// 上下文被取消或超时后不再尝试剩下的后端
+func failover[T any](ctx context.Context, f *FailoverRepository, fn func(Repository) (T, error)) (T, error) {
+	var zero T
+	if len(f.backends) == 0 {
+		return zero, fmt.Errorf("%w: failover repository has no backends", ErrInvalidRequest)
+	}
+
+	errs := make([]error, 0, len(f.backends))
+	for i, backend := range f.backends {
+		value, err := fn(backend)
+		if err == nil {
+			return value, nil
+		}
+		errs = append(errs, &BackendError{Backend: f.names[i], Err: err})
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return zero, &MultiError{Errors: errs}
+}
+
+// GetPackage implements the Repository interface
+func (f *FailoverRepository) GetPackage(ctx context.Context, gemName string) (*models.PackageInformation, error) {
+	return failover(ctx, f, func(backend Repository) (*models.PackageInformation, error) {
+		return backend.GetPackage(ctx, gemName)
+	})
+}
+
+// GemExists implements the Repository interface
+func (f *FailoverRepository) GemExists(ctx context.Context, gemName string) (bool, error) {
+	return failover(ctx, f, func(backend Repository) (bool, error) {
+		return backend.GemExists(ctx, gemName)
+	})
+}
+
+// Search implements the Repository interface
+func (f *FailoverRepository) Search(ctx context.Context, query string, page int) ([]*models.PackageInformation, error) {
+	return failover(ctx, f, func(backend Repository) ([]*models.PackageInformation, error) {
+		return backend.Search(ctx, query, page)
+	})
+}
+
+// SearchByLicense implements the Repository interface
+func (f *FailoverRepository) SearchByLicense(ctx context.Context, license string, page int) ([]*models.PackageInformation, error) {
+	return failover(ctx, f, func(backend Repository) ([]*models.PackageInformation, error) {
+		return backend.SearchByLicense(ctx, license, page)
+	})
+}
+
+// GetGemVersions implements the Repository interface
+func (f *FailoverRepository) GetGemVersions(ctx context.Context, gemName string) ([]*models.Version, error) {
+	return failover(ctx, f, func(backend Repository) ([]*models.Version, error) {
+		return backend.GetGemVersions(ctx, gemName)
+	})
+}
+
+// GetGemLatestVersion implements the Repository interface
+func (f *FailoverRepository) GetGemLatestVersion(ctx context.Context, gemName string) (*models.LatestVersion, error) {
+	return failover(ctx, f, func(backend Repository) (*models.LatestVersion, error) {
+		return backend.GetGemLatestVersion(ctx, gemName)
+	})
+}
+
+// GetGemVersion implements the Repository interface
+func (f *FailoverRepository) GetGemVersion(ctx context.Context, gemName, version string) (*models.Version, error) {
+	return failover(ctx, f, func(backend Repository) (*models.Version, error) {
+		return backend.GetGemVersion(ctx, gemName, version)
+	})
+}
+
+// FindVersionMatching implements the Repository interface
+// 版本列表通过GetGemVersions获取，所以同样会在仓库之间故障转移
+func (f *FailoverRepository) FindVersionMatching(ctx context.Context, gemName, constraint string) (*models.Version, error) {
+	return findVersionMatching(ctx, gemName, constraint, f.GetGemVersions)
+}
+
+// GetTimeFrameVersions implements the Repository interface
+func (f *FailoverRepository) GetTimeFrameVersions(ctx context.Context, from, to time.Time) ([]*models.Version, error) {
+	return failover(ctx, f, func(backend Repository) ([]*models.Version, error) {
+		return backend.GetTimeFrameVersions(ctx, from, to)
+	})
+}
+
+// Downloads implements the Repository interface
+func (f *FailoverRepository) Downloads(ctx context.Context) (*models.RepositoryDownloadCount, error) {
+	return failover(ctx, f, func(backend Repository) (*models.RepositoryDownloadCount, error) {
+		return backend.Downloads(ctx)
+	})
+}
+
+// VersionDownloads implements the Repository interface
+func (f *FailoverRepository) VersionDownloads(ctx context.Context, gemName, gemVersion string) (*models.VersionDownloadCount, error) {
+	return failover(ctx, f, func(backend Repository) (*models.VersionDownloadCount, error) {
+		return backend.VersionDownloads(ctx, gemName, gemVersion)
+	})
+}
+
+// GetDependencies implements the Repository interface
+func (f *FailoverRepository) GetDependencies(ctx context.Context, gemNames ...string) ([]*models.DependencyInfo, error) {
+	return failover(ctx, f, func(backend Repository) ([]*models.DependencyInfo, error) {
+		return backend.GetDependencies(ctx, gemNames...)
+	})
+}
+
+// LatestGems implements the Repository interface
+func (f *FailoverRepository) LatestGems(ctx context.Context) ([]*models.PackageInformation, error) {
+	return failover(ctx, f, func(backend Repository) ([]*models.PackageInformation, error) {
+		return backend.LatestGems(ctx)
+	})
+}
+
+// JustUpdatedGems implements the Repository interface
+func (f *FailoverRepository) JustUpdatedGems(ctx context.Context) ([]*models.PackageInformation, error) {
+	return failover(ctx, f, func(backend Repository) ([]*models.PackageInformation, error) {
+		return backend.JustUpdatedGems(ctx)
+	})
+}
+
+// GetReverseDependencies implements the Repository interface
+func (f *FailoverRepository) GetReverseDependencies(ctx context.Context, gemName string) ([]string, error) {
+	return failover(ctx, f, func(backend Repository) ([]string, error) {
+		return backend.GetReverseDependencies(ctx, gemName)
+	})
+}
+
+// BulkGetPackages implements the Repository interface
+// 每个包单独进行故障转移
+func (f *FailoverRepository) BulkGetPackages(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[*models.PackageInformation] {
+	return bulkExecute(ctx, gemNames, options, f.GetPackage)
+}
+
+// BulkGetVersions implements the Repository interface
+func (f *FailoverRepository) BulkGetVersions(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[[]*models.Version] {
+	return bulkExecute(ctx, gemNames, options, f.GetGemVersions)
+}
+
+// BulkGetDependencies implements the Repository interface
+func (f *FailoverRepository) BulkGetDependencies(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[[]*models.DependencyInfo] {
+	return bulkGetDependencies(ctx, gemNames, options, f.GetDependencies)
+}
+
+// BulkGetReverseDependencies implements the Repository interface
+func (f *FailoverRepository) BulkGetReverseDependencies(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[[]string] {
+	return bulkExecute(ctx, gemNames, options, f.GetReverseDependencies)
+}
+
+// BulkGemExists implements the Repository interface
+func (f *FailoverRepository) BulkGemExists(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[bool] {
+	return bulkExecute(ctx, gemNames, options, f.GemExists)
+}
+
+// BulkSearch implements the Repository interface
+func (f *FailoverRepository) BulkSearch(ctx context.Context, queries []string, page int, options *BulkOptions) []*BulkResult[[]*models.PackageInformation] {
+	return bulkExecute(ctx, queries, options, func(ctx context.Context, query string) ([]*models.PackageInformation, error) {
+		return f.Search(ctx, query, page)
+	})
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailoverRepository_GetPackage(t *testing.T) {
+	broken := newMockRepository().setFailOn("rails", &APIError{StatusCode: http.StatusServiceUnavailable, Cause: ErrServerError})
+	healthy := newMockRepository()
+	healthy.delay = 0
+
+	// 第一个后端失败时使用第二个后端的结果
+	pkg, err := NewFailoverRepository(broken, healthy).GetPackage(context.Background(), "rails")
+	assert.NoError(t, err)
+	if assert.NotNil(t, pkg) {
+		assert.Equal(t, "7.0.5", pkg.Version)
+	}
+
+	_, err = NewFailoverRepository().GetPackage(context.Background(), "rails")
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+}
+
+func TestFailoverRepository_AllBackendsFail(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	backends := []Repository{
+		NewRepository(NewOptions().SetServerURL(server.URL).DisableRetry()),
+		NewCachedRepository(newMockRepository().setFailOn("rails", &APIError{StatusCode: http.StatusServiceUnavailable, Cause: ErrServerError}), 0, nil),
+		newMockRepository().setFailOn("rails", fmt.Errorf("search: %w", ErrRateLimited)),
+	}
+	_, err := NewFailoverRepository(backends...).GetPackage(context.Background(), "rails")
+
+	var multiErr *MultiError
+	if !assert.ErrorAs(t, err, &multiErr) {
+		return
+	}
+	assert.Len(t, multiErr.Errors, 3)
+	for i, name := range []string{server.URL, "backend #2", "backend #3"} {
+		var backendErr *BackendError
+		if assert.ErrorAs(t, multiErr.Errors[i], &backendErr) {
+			assert.Equal(t, name, backendErr.Backend)
+		}
+	}
+
+	// 每个后端的失败原因都可以被检查
+	assert.Contains(t, multiErr.Errors[0].Error(), "502")
+	var apiErr *APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+	}
+	if assert.ErrorAs(t, multiErr.Errors[1], &apiErr) {
+		assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	}
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.False(t, errors.Is(err, ErrUnauthorized))
+	assert.Contains(t, err.Error(), "3 errors occurred")
+}
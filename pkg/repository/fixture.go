@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// fixtureServerURL 离线仓库使用的虚拟地址，请求不会真正发出
+const fixtureServerURL = "http://rubygems.fixture"
+
+// NewFixtureRepository 创建一个从文件系统读取响应的离线仓库，不需要访问网络
+// 请求路径直接对应fsys中的文件，例如:
+//   - GetPackage("rails") 读取 api/v1/gems/rails.json
+//   - GetGemVersions("rack") 读取 api/v1/versions/rack.json
+//   - GetPackageAtVersion("rails", "7.1.2") 读取 api/v2/rubygems/rails/versions/7.1.2.json
+//
+// 查询参数会被忽略，所以Search等依赖查询参数的接口总是返回同一个文件的内容。
+// 文件不存在时返回404，与真实仓库中gem不存在的表现一致。
+// fsys可以是os.DirFS，也可以是下游项目用go:embed打包的embed.FS
+func NewFixtureRepository(fsys fs.FS) Repository {
+	options := NewOptions().
+		SetServerURL(fixtureServerURL).
+		SetTransport(&fixtureTransport{fsys: fsys}).
+		DisableRetry()
+	return NewRepository(options)
+}
+
+// fixtureTransport 把HTTP请求映射为对文件系统的读取
+type fixtureTransport struct {
+	fsys fs.FS
+}
+
+func (t *fixtureTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	name := strings.TrimPrefix(path.Clean("/"+request.URL.Path), "/")
+
+	body, err := fs.ReadFile(t.fsys, name)
+	status := http.StatusOK
+	if errors.Is(err, fs.ErrNotExist) {
+		status = http.StatusNotFound
+		body = []byte("This rubygem could not be found.")
+	} else if err != nil {
+		return nil, err
+	}
+
+	header := make(http.Header)
+	if status == http.StatusOK && path.Ext(name) == ".json" {
+		header.Set("Content-Type", "application/json")
+	}
+	return &http.Response{
+		Status:        http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       request,
+	}, nil
+}
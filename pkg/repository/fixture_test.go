@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 离线仓库的测试不需要网络，在short模式下也会运行
+func newFixtureTestRepository() Repository {
+	return NewFixtureRepository(os.DirFS("testdata/fixtures"))
+}
+
+func TestFixtureRepository_GetPackage(t *testing.T) {
+	repo := newFixtureTestRepository()
+
+	rails, err := repo.GetPackage(context.Background(), "rails")
+	assert.NoError(t, err)
+	if assert.NotNil(t, rails) {
+		assert.Equal(t, "rails", rails.Name)
+		assert.Equal(t, "7.1.2", rails.Version)
+		assert.Equal(t, []string{"MIT"}, rails.Licenses)
+		assert.Len(t, rails.Dependencies.Runtime, 5)
+	}
+
+	rack, err := repo.GetPackage(context.Background(), "rack")
+	assert.NoError(t, err)
+	if assert.NotNil(t, rack) {
+		assert.Equal(t, "3.0.8", rack.Version)
+		assert.Empty(t, rack.Dependencies.Runtime)
+	}
+}
+
+func TestFixtureRepository_Versions(t *testing.T) {
+	repo := newFixtureTestRepository()
+
+	versions, err := repo.GetGemVersions(context.Background(), "rails")
+	assert.NoError(t, err)
+	if assert.Len(t, versions, 4) {
+		assert.Equal(t, "7.1.2", versions[0].Number)
+		assert.True(t, versions[2].Prerelease)
+	}
+
+	latest, err := repo.GetGemLatestVersion(context.Background(), "rack")
+	assert.NoError(t, err)
+	if assert.NotNil(t, latest) {
+		assert.Equal(t, "3.0.8", latest.Version)
+	}
+
+	downloads, err := repo.VersionDownloads(context.Background(), "rack", "3.0.8")
+	assert.NoError(t, err)
+	if assert.NotNil(t, downloads) {
+		assert.Equal(t, 21394571, downloads.VersionDownloads)
+	}
+
+	dependents, err := repo.GetReverseDependencies(context.Background(), "rack")
+	assert.NoError(t, err)
+	assert.Contains(t, dependents, "railties")
+}
+
+func TestFixtureRepository_GetPackageAtVersion(t *testing.T) {
+	repo := newFixtureTestRepository().(*RepositoryImpl)
+
+	pkg, err := repo.GetPackageAtVersion(context.Background(), "rails", "7.1.2")
+	assert.NoError(t, err)
+	if assert.NotNil(t, pkg) {
+		// 顶层为null的链接用metadata补全
+		assert.Equal(t, "https://github.com/rails/rails/tree/v7.1.2", pkg.SourceCodeURI)
+		assert.Len(t, pkg.Dependencies.Runtime, 4)
+	}
+}
+
+func TestFixtureRepository_Missing(t *testing.T) {
+	repo := NewFixtureRepository(fstest.MapFS{
+		"api/v1/gems/tiny.json": &fstest.MapFile{Data: []byte(`{"name": "tiny", "version": "0.0.1"}`)},
+	})
+
+	pkg, err := repo.GetPackage(context.Background(), "tiny")
+	assert.NoError(t, err)
+	if assert.NotNil(t, pkg) {
+		assert.Equal(t, "0.0.1", pkg.Version)
+	}
+
+	_, err = repo.GetPackage(context.Background(), "missing")
+	assert.Error(t, err)
+}
+
+func TestFixtureRepository_GetReverseDependenciesForVersion(t *testing.T) {
+	repo := newFixtureTestRepository().(*RepositoryImpl)
+
+	// puma的最新版本已经不再依赖rack
+	dependents, err := repo.GetReverseDependenciesForVersion(context.Background(), "rack", "3.0.8")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"actionpack", "railties", "rack-test"}, dependents)
+
+	// sinatra要求 "~> 2.2, >= 2.2.4"
+	dependents, err = repo.GetReverseDependenciesForVersion(context.Background(), "rack", "2.2.8")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"actionpack", "railties", "rack-test", "sinatra"}, dependents)
+
+	dependents, err = repo.GetReverseDependenciesForVersion(context.Background(), "rack", "1.6.0")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"rack-test"}, dependents)
+
+	_, err = repo.GetReverseDependenciesForVersion(context.Background(), "missing", "1.0.0")
+	assert.Error(t, err)
+}
+
+func TestFixtureRepository_SearchByLicense(t *testing.T) {
+	repo := newFixtureTestRepository()
+
+	// 许可证的不同写法都会规范化为MIT，只是提到MIT的包不会被返回
+	packages, err := repo.SearchByLicense(context.Background(), "MIT License", 1)
+	assert.NoError(t, err)
+	names := make([]string, 0, len(packages))
+	for _, pkg := range packages {
+		names = append(names, pkg.Name)
+	}
+	assert.Equal(t, []string{"mit", "license_finder", "licensee"}, names)
+
+	packages, err = repo.SearchByLicense(context.Background(), "Apache-2.0", 1)
+	assert.NoError(t, err)
+	assert.NotNil(t, packages)
+	assert.Empty(t, packages)
+}
+
+func TestFixtureRepository_GetGemAuthors(t *testing.T) {
+	repo := newFixtureTestRepository().(*RepositoryImpl)
+
+	// sinatra的作者随着版本逐渐增加
+	authors, err := repo.GetGemAuthors(context.Background(), "sinatra")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Blake Mizerany", "Ryan Tomayko", "Simon Rozet", "Konstantin Haase"}, authors)
+
+	authors, err = repo.GetGemAuthors(context.Background(), "rack")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Leah Neukirchen"}, authors)
+
+	_, err = repo.GetGemAuthors(context.Background(), "missing")
+	assert.Error(t, err)
+}
+
+func TestFixtureRepository_GetDuplicateDependencies(t *testing.T) {
+	repo := newFixtureTestRepository().(*RepositoryImpl)
+
+	// sass-rails的fixture中sprockets声明了两次，railties同时是运行时依赖和开发依赖
+	duplicates, err := repo.GetDuplicateDependencies(context.Background(), "sass-rails")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"railties", "sprockets"}, duplicates)
+
+	duplicates, err = repo.GetDuplicateDependencies(context.Background(), "rack-test")
+	assert.NoError(t, err)
+	assert.Empty(t, duplicates)
+
+	_, err = repo.GetDuplicateDependencies(context.Background(), "missing")
+	assert.Error(t, err)
+}
+
+func TestFixtureRepository_MinimumRubyVersion(t *testing.T) {
+	repo := newFixtureTestRepository().(*RepositoryImpl)
+
+	// rack 3.0.8要求>= 2.4.0，rails 7.1.2要求>= 2.7.0，sinatra 3.1.0要求>= 2.6.0
+	floor, requirements, err := repo.MinimumRubyVersion(context.Background(), []string{"rack", "rails", "sinatra"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "2.7.0", floor)
+	assert.Equal(t, map[string]string{
+		"rack":    ">= 2.4.0",
+		"rails":   ">= 2.7.0",
+		"sinatra": ">= 2.6.0",
+	}, requirements)
+
+	// 获取失败的gem汇总到错误中，其它gem的结果仍然返回
+	floor, requirements, err = repo.MinimumRubyVersion(context.Background(), []string{"rack", "missing"}, NewBulkOptions().WithContinueOnError(true))
+	var multiErr *MultiError
+	if assert.ErrorAs(t, err, &multiErr) {
+		assert.Len(t, multiErr.Errors, 1)
+		assert.True(t, IsNotFound(err))
+	}
+	assert.Equal(t, "2.4.0", floor)
+	assert.Equal(t, map[string]string{"rack": ">= 2.4.0"}, requirements)
+}
+
+func TestRequirementFloor(t *testing.T) {
+	assert.Equal(t, "2.7.0", requirementFloor(">= 2.7.0"))
+	assert.Equal(t, "3.1", requirementFloor("~> 3.1"))
+	assert.Equal(t, "2.6", requirementFloor(">= 2.5, > 2.6, < 4"))
+	assert.Equal(t, "0", requirementFloor(">= 0"))
+	assert.Equal(t, "", requirementFloor("< 3.0"))
+	assert.Equal(t, "", requirementFloor(""))
+	assert.Equal(t, "", requirementFloor("not a version"))
+}
+
+func TestFixtureRepository_GetGemVersionYanked(t *testing.T) {
+	repo := newFixtureTestRepository()
+
+	version, err := repo.GetGemVersion(context.Background(), "rest-client", "1.6.13")
+	assert.NoError(t, err)
+	assert.True(t, version.IsYanked())
+	if assert.NotNil(t, version.YankedAt) {
+		assert.Equal(t, time.Date(2019, 8, 19, 16, 2, 13, 0, time.UTC), version.YankedAt.UTC())
+	}
+	assert.Equal(t, "Malicious code was published from a compromised account", version.YankedReason)
+
+	version, err = repo.GetGemVersion(context.Background(), "rest-client", "2.1.0")
+	assert.NoError(t, err)
+	assert.False(t, version.IsYanked())
+	assert.Nil(t, version.YankedAt)
+	assert.Empty(t, version.YankedReason)
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+)
+
+// LicenseDistribution 统计一组gem包的许可证分布
+// 通过BulkGetPackages批量获取包信息，把每个包声明的许可证规范化为SPDX标识后计数。
+// 声明了多个许可证的包会在每个许可证下各计一次，没有声明许可证的包计入models.UnknownLicense。
+// 部分包获取失败时，仍然返回其余包的统计结果，同时返回描述失败情况的错误
+func (x *RepositoryImpl) LicenseDistribution(ctx context.Context, gemNames []string, options *BulkOptions) (map[string]int, error) {
+	distribution := make(map[string]int)
+
+	var firstErr error
+	failed := 0
+	for _, result := range x.BulkGetPackages(ctx, gemNames, options) {
+		if result == nil {
+			// 设置了遇到错误停止时，未处理的包没有结果
+			continue
+		}
+		if result.Error != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", result.Key, result.Error)
+			}
+			continue
+		}
+		for _, license := range models.NormalizeLicenses(result.Value.Licenses) {
+			distribution[license]++
+		}
+	}
+
+	if firstErr != nil {
+		return distribution, fmt.Errorf("failed to fetch %d of %d gems, first error: %w", failed, len(gemNames), firstErr)
+	}
+	return distribution, nil
+}
+
+// FilterByLicense 过滤出声明了给定许可证的包，许可证在比较之前都会规范化为SPDX标识
+// 例如license为"MIT License"时，声明了"MIT"或"mit"的包都会被保留
+func FilterByLicense(packages []*models.PackageInformation, license string) []*models.PackageInformation {
+	want := models.NormalizeLicense(license)
+	filtered := make([]*models.PackageInformation, 0)
+	for _, pkg := range packages {
+		if pkg == nil {
+			continue
+		}
+		for _, id := range models.NormalizeLicenses(pkg.Licenses) {
+			if id == want {
+				filtered = append(filtered, pkg)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// SearchByLicense 搜索声明了给定许可证的包
+// API不支持按许可证搜索，这里用许可证名称作为查询，再用FilterByLicense过滤这一页的结果。
+// 结果只是近似的：没有在名称、简介或描述中提到许可证的包不会被找到；
+// 一页过滤后可能为空，但后面的页仍然可能有结果，需要完整的结果时请用SearchAll获取全部候选后再过滤
+func (x *RepositoryImpl) SearchByLicense(ctx context.Context, license string, page int) ([]*models.PackageInformation, error) {
+	packages, err := x.Search(ctx, models.NormalizeLicense(license), page)
+	if err != nil {
+		return nil, err
+	}
+	return FilterByLicense(packages, license), nil
+}
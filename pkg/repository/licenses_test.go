@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_LicenseDistribution(t *testing.T) {
+	repo := newTestRepository(t, map[string]string{
+		"/api/v1/gems/rails.json":    `{"name": "rails", "licenses": ["MIT"]}`,
+		"/api/v1/gems/rack.json":     `{"name": "rack", "licenses": ["MIT License"]}`,
+		"/api/v1/gems/nokogiri.json": `{"name": "nokogiri", "licenses": ["MIT", "Apache 2.0"]}`,
+		"/api/v1/gems/json.json":     `{"name": "json", "licenses": ["Ruby", "BSD-2-Clause"]}`,
+		"/api/v1/gems/legacy.json":   `{"name": "legacy", "licenses": []}`,
+		"/api/v1/gems/blank.json":    `{"name": "blank", "licenses": null}`,
+	})
+
+	distribution, err := repo.LicenseDistribution(context.Background(),
+		[]string{"rails", "rack", "nokogiri", "json", "legacy", "blank"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{
+		"MIT":                 3,
+		"Apache-2.0":          1,
+		"Ruby":                1,
+		"BSD-2-Clause":        1,
+		models.UnknownLicense: 2,
+	}, distribution)
+
+	// 获取失败的包不计入统计，但会返回错误
+	distribution, err = repo.LicenseDistribution(context.Background(), []string{"rails", "missing"}, nil)
+	assert.Error(t, err)
+	assert.Equal(t, map[string]int{"MIT": 1}, distribution)
+}
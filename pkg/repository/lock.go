@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+)
+
+// maxLockPasses 限制解析过程的最大轮数，防止版本选择在几个候选之间来回摆动
+const maxLockPasses = 32
+
+// LockConstraint 表示解析过程中施加在某个gem上的一条版本要求及其来源
+type LockConstraint struct {
+	// 版本要求，例如 "~> 7.0"
+	Requirement string
+
+	// 提出这个要求的gem，形如 "rails 7.0.8"，顶层依赖为 "Gemfile"
+	RequiredBy string
+}
+
+// UnsatisfiableError 表示某个gem的所有版本都无法同时满足施加在它上面的版本要求
+type UnsatisfiableError struct {
+	// 发生冲突的包名
+	Name string
+
+	// 冲突时施加在这个gem上的全部版本要求
+	Constraints []LockConstraint
+}
+
+func (e *UnsatisfiableError) Error() string {
+	parts := make([]string, 0, len(e.Constraints))
+	for _, constraint := range e.Constraints {
+		parts = append(parts, fmt.Sprintf("%q (required by %s)", constraint.Requirement, constraint.RequiredBy))
+	}
+	return fmt.Sprintf("no version of %s satisfies %s", e.Name, strings.Join(parts, ", "))
+}
+
+// GenerateLock 把Gemfile风格的依赖声明解析成完整的锁定结果
+// gems是包名 => 版本要求，版本要求为空表示接受任意版本。
+// 每个gem以及它的传递运行时依赖都会被锁定到满足所有版本要求的最高版本。
+//
+// 这是Bundler解析器的简化版本：它会在发现新的版本要求后重新选择版本，直到结果稳定，
+// 但不会为了满足冲突而回退到父节点的旧版本去尝试其他组合。
+// 某个gem的所有版本都无法满足施加在它上面的版本要求时返回*UnsatisfiableError。
+func (x *RepositoryImpl) GenerateLock(ctx context.Context, gems map[string]string) (*models.Lockfile, error) {
+	resolver := &lockResolver{
+		repo:         x,
+		roots:        gems,
+		versions:     make(map[string][]*models.Version),
+		dependencies: make(map[string][]*models.Dependency),
+	}
+
+	pins := make(map[string]string)
+	for pass := 0; pass < maxLockPasses; pass++ {
+		next, err := resolver.pass(ctx, pins)
+		if err != nil {
+			return nil, err
+		}
+		if samePins(pins, next) {
+			return resolver.lockfile(ctx, next)
+		}
+		pins = next
+	}
+	return nil, fmt.Errorf("%w: dependency resolution did not converge after %d passes", ErrInvalidRequest, maxLockPasses)
+}
+
+// lockResolver 保存一次GenerateLock过程中的状态，版本列表和依赖信息只请求一次
+type lockResolver struct {
+	repo         *RepositoryImpl
+	roots        map[string]string
+	versions     map[string][]*models.Version    // 包名 => 版本列表
+	dependencies map[string][]*models.Dependency // 包名@版本 => 运行时依赖
+}
+
+// pass 基于上一轮的锁定结果执行一轮解析
+// 从顶层依赖出发遍历，沿途收集每个gem上的版本要求：上一轮锁定的版本仍然满足已知要求时沿用，
+// 否则重新选择；遍历结束后再用完整的要求集合校验一遍，不满足的gem重新选择版本留给下一轮展开
+func (r *lockResolver) pass(ctx context.Context, previous map[string]string) (map[string]string, error) {
+	constraints := make(map[string][]LockConstraint)
+	pins := make(map[string]string)
+
+	names := make([]string, 0, len(r.roots))
+	for name := range r.roots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	queue := make([]string, 0, len(names))
+	for _, name := range names {
+		constraints[name] = append(constraints[name], LockConstraint{Requirement: r.roots[name], RequiredBy: "Gemfile"})
+		queue = append(queue, name)
+	}
+
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if _, ok := pins[name]; ok {
+			continue
+		}
+
+		version, err := r.choose(ctx, name, constraints[name], previous[name])
+		if err != nil {
+			return nil, err
+		}
+		pins[name] = version
+
+		dependencies, err := r.runtimeDependencies(ctx, name, version)
+		if err != nil {
+			return nil, err
+		}
+		for _, dependency := range dependencies {
+			constraints[dependency.Name] = append(constraints[dependency.Name], LockConstraint{
+				Requirement: dependency.Requirements,
+				RequiredBy:  name + " " + version,
+			})
+			queue = append(queue, dependency.Name)
+		}
+	}
+
+	for name, version := range pins {
+		chosen, err := r.choose(ctx, name, constraints[name], version)
+		if err != nil {
+			return nil, err
+		}
+		pins[name] = chosen
+	}
+	return pins, nil
+}
+
+// choose 为gem选择满足全部要求的最高版本，preferred满足要求时优先沿用
+func (r *lockResolver) choose(ctx context.Context, name string, constraints []LockConstraint, preferred string) (string, error) {
+	requirement := &models.Requirement{}
+	for _, constraint := range constraints {
+		parsed, err := models.ParseRequirement(constraint.Requirement)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s requirement %q from %s: %v", ErrInvalidRequest, name, constraint.Requirement, constraint.RequiredBy, err)
+		}
+		requirement.Constraints = append(requirement.Constraints, parsed.Constraints...)
+	}
+
+	if preferred != "" && requirement.Satisfies(preferred) {
+		return preferred, nil
+	}
+
+	versions, ok := r.versions[name]
+	if !ok {
+		var err error
+		versions, err = r.repo.GetGemVersions(ctx, name)
+		if err != nil {
+			return "", err
+		}
+		r.versions[name] = versions
+	}
+
+	newest := newestSatisfying(versions, requirement, nil)
+	if newest == nil {
+		return "", &UnsatisfiableError{Name: name, Constraints: constraints}
+	}
+	return newest.Number, nil
+}
+
+// runtimeDependencies 返回指定版本的运行时依赖
+func (r *lockResolver) runtimeDependencies(ctx context.Context, name, version string) ([]*models.Dependency, error) {
+	key := name + "@" + version
+	if dependencies, ok := r.dependencies[key]; ok {
+		return dependencies, nil
+	}
+	pkg, err := r.repo.GetPackageAtVersion(ctx, name, version)
+	if err != nil {
+		return nil, err
+	}
+	r.dependencies[key] = pkg.Dependencies.Runtime
+	return pkg.Dependencies.Runtime, nil
+}
+
+// lockfile 把稳定下来的锁定结果整理成Lockfile
+func (r *lockResolver) lockfile(ctx context.Context, pins map[string]string) (*models.Lockfile, error) {
+	lockfile := &models.Lockfile{
+		Specs:        make([]*models.LockedSpec, 0, len(pins)),
+		Dependencies: make(map[string]string, len(r.roots)),
+	}
+	for name, requirement := range r.roots {
+		lockfile.Dependencies[name] = requirement
+	}
+	for name, version := range pins {
+		dependencies, err := r.runtimeDependencies(ctx, name, version)
+		if err != nil {
+			return nil, err
+		}
+		lockfile.Specs = append(lockfile.Specs, &models.LockedSpec{
+			Name:         name,
+			Version:      version,
+			Dependencies: dependencies,
+		})
+	}
+	sort.Slice(lockfile.Specs, func(i, j int) bool {
+		return lockfile.Specs[i].Name < lockfile.Specs[j].Name
+	})
+	return lockfile, nil
+}
+
+func samePins(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, version := range a {
+		if b[name] != version {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 锁定测试使用的仓库数据：
+// app 1.0.0 依赖 web(~> 2.0)，web 2.1.0 依赖 rack(< 3)
+// rack 有 3.0.0 和 2.2.8 两个版本
+func newLockTestRepository(t *testing.T) *RepositoryImpl {
+	return newTestRepository(t, map[string]string{
+		"/api/v1/versions/app.json": `[{"number": "1.0.0", "platform": "ruby"}]`,
+		"/api/v1/versions/web.json": `[
+			{"number": "3.0.0", "platform": "ruby"},
+			{"number": "2.1.0", "platform": "ruby"},
+			{"number": "2.0.0", "platform": "ruby"}
+		]`,
+		"/api/v1/versions/rack.json": `[
+			{"number": "3.0.0", "platform": "ruby"},
+			{"number": "3.1.0.beta1", "platform": "ruby", "prerelease": true},
+			{"number": "2.2.8", "platform": "ruby"}
+		]`,
+		"/api/v2/rubygems/app/versions/1.0.0.json":  `{"name": "app", "version": "1.0.0", "dependencies": {"runtime": [{"name": "web", "requirements": "~> 2.0"}]}}`,
+		"/api/v2/rubygems/web/versions/2.1.0.json":  `{"name": "web", "version": "2.1.0", "dependencies": {"runtime": [{"name": "rack", "requirements": "< 3"}]}}`,
+		"/api/v2/rubygems/rack/versions/3.0.0.json": `{"name": "rack", "version": "3.0.0", "dependencies": {"runtime": []}}`,
+		"/api/v2/rubygems/rack/versions/2.2.8.json": `{"name": "rack", "version": "2.2.8", "dependencies": {"runtime": []}}`,
+	})
+}
+
+func TestGenerateLock(t *testing.T) {
+	repo := newLockTestRepository(t)
+
+	lockfile, err := repo.GenerateLock(context.Background(), map[string]string{
+		"app":  "",
+		"rack": ">= 2.0",
+	})
+	assert.NoError(t, err)
+	if assert.NotNil(t, lockfile) {
+		assert.Len(t, lockfile.Specs, 3)
+		assert.Equal(t, map[string]string{"app": "", "rack": ">= 2.0"}, lockfile.Dependencies)
+
+		assert.Equal(t, "1.0.0", lockfile.Spec("app").Version)
+		assert.Equal(t, "2.1.0", lockfile.Spec("web").Version)
+		// rack起初被锁定到3.0.0，发现web的 "< 3" 要求后重新选择了2.2.8
+		assert.Equal(t, "2.2.8", lockfile.Spec("rack").Version)
+		assert.Nil(t, lockfile.Spec("missing"))
+
+		web := lockfile.Spec("web")
+		if assert.Len(t, web.Dependencies, 1) {
+			assert.Equal(t, "rack", web.Dependencies[0].Name)
+		}
+	}
+}
+
+func TestGenerateLock_Unsatisfiable(t *testing.T) {
+	repo := newLockTestRepository(t)
+
+	_, err := repo.GenerateLock(context.Background(), map[string]string{
+		"app":  "",
+		"rack": ">= 3.0",
+	})
+	var unsatisfiable *UnsatisfiableError
+	if assert.True(t, errors.As(err, &unsatisfiable)) {
+		assert.Equal(t, "rack", unsatisfiable.Name)
+		assert.ElementsMatch(t, []LockConstraint{
+			{Requirement: ">= 3.0", RequiredBy: "Gemfile"},
+			{Requirement: "< 3", RequiredBy: "web 2.1.0"},
+		}, unsatisfiable.Constraints)
+		assert.Contains(t, err.Error(), `"< 3" (required by web 2.1.0)`)
+	}
+}
+
+func TestGenerateLock_InvalidRequirement(t *testing.T) {
+	repo := newLockTestRepository(t)
+
+	_, err := repo.GenerateLock(context.Background(), map[string]string{"app": "not a version"})
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+}
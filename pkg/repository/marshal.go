@@ -0,0 +1,284 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+)
+
+// Ruby Marshal格式的类型标记，参考 https://docs.ruby-lang.org/en/master/marshal_rdoc.html
+const (
+	marshalMajorVersion = 4
+	marshalMinorVersion = 8
+
+	marshalNil         = '0'
+	marshalTrue        = 'T'
+	marshalFalse       = 'F'
+	marshalFixnum      = 'i'
+	marshalSymbol      = ':'
+	marshalSymlink     = ';'
+	marshalObjectLink  = '@'
+	marshalInstanceVar = 'I'
+	marshalString      = '"'
+	marshalArray       = '['
+	marshalHash        = '{'
+	marshalFloat       = 'f'
+	marshalBignum      = 'l'
+)
+
+var errMarshalTruncated = errors.New("marshal data truncated")
+
+// marshalDecoder 是一个最小化的Ruby Marshal解码器，只支持bundler依赖接口会用到的类型：
+// nil、布尔、整数、符号、字符串、数组和哈希。字符串和符号都解码为string，
+// 哈希解码为以string为键的map，其它类型会返回错误
+type marshalDecoder struct {
+	data    []byte
+	pos     int
+	symbols []string
+	objects []interface{}
+}
+
+// unmarshalRuby 解码一段完整的Ruby Marshal数据
+func unmarshalRuby(data []byte) (interface{}, error) {
+	if len(data) < 2 {
+		return nil, errMarshalTruncated
+	}
+	if data[0] != marshalMajorVersion || data[1] > marshalMinorVersion {
+		return nil, fmt.Errorf("unsupported marshal version %d.%d", data[0], data[1])
+	}
+	decoder := &marshalDecoder{data: data, pos: 2}
+	return decoder.decode()
+}
+
+func (d *marshalDecoder) decode() (interface{}, error) {
+	tag, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tag {
+	case marshalNil:
+		return nil, nil
+	case marshalTrue:
+		return true, nil
+	case marshalFalse:
+		return false, nil
+	case marshalFixnum:
+		return d.readInt()
+	case marshalSymbol:
+		return d.readSymbol()
+	case marshalSymlink:
+		index, err := d.readInt()
+		if err != nil {
+			return nil, err
+		}
+		if index < 0 || index >= len(d.symbols) {
+			return nil, fmt.Errorf("marshal symlink %d out of range", index)
+		}
+		return d.symbols[index], nil
+	case marshalObjectLink:
+		index, err := d.readInt()
+		if err != nil {
+			return nil, err
+		}
+		if index < 0 || index >= len(d.objects) {
+			return nil, fmt.Errorf("marshal object link %d out of range", index)
+		}
+		return d.objects[index], nil
+	case marshalInstanceVar:
+		// 带实例变量的对象，通常是带编码信息的字符串，实例变量本身被忽略
+		value, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		count, _, err := d.readCount()
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < count; i++ {
+			if _, err := d.decode(); err != nil {
+				return nil, err
+			}
+			if _, err := d.decode(); err != nil {
+				return nil, err
+			}
+		}
+		return value, nil
+	case marshalString, marshalFloat:
+		bytes, err := d.readBytes()
+		if err != nil {
+			return nil, err
+		}
+		value := string(bytes)
+		d.objects = append(d.objects, value)
+		return value, nil
+	case marshalArray:
+		count, capacity, err := d.readCount()
+		if err != nil {
+			return nil, err
+		}
+		index := len(d.objects)
+		d.objects = append(d.objects, nil)
+		array := make([]interface{}, 0, capacity)
+		for i := 0; i < count; i++ {
+			element, err := d.decode()
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, element)
+		}
+		d.objects[index] = array
+		return array, nil
+	case marshalHash:
+		count, capacity, err := d.readCount()
+		if err != nil {
+			return nil, err
+		}
+		hash := make(map[string]interface{}, capacity)
+		d.objects = append(d.objects, hash)
+		for i := 0; i < count; i++ {
+			key, err := d.decode()
+			if err != nil {
+				return nil, err
+			}
+			value, err := d.decode()
+			if err != nil {
+				return nil, err
+			}
+			hash[fmt.Sprint(key)] = value
+		}
+		return hash, nil
+	default:
+		return nil, fmt.Errorf("unsupported marshal type %q at offset %d", tag, d.pos-1)
+	}
+}
+
+func (d *marshalDecoder) readByte() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, errMarshalTruncated
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b, nil
+}
+
+// readInt 读取Marshal的变长整数编码
+func (d *marshalDecoder) readInt() (int, error) {
+	b, err := d.readByte()
+	if err != nil {
+		return 0, err
+	}
+	c := int(int8(b))
+	switch {
+	case c == 0:
+		return 0, nil
+	case c > 4:
+		return c - 5, nil
+	case c < -4:
+		return c + 5, nil
+	case c > 0:
+		n := 0
+		for i := 0; i < c; i++ {
+			b, err := d.readByte()
+			if err != nil {
+				return 0, err
+			}
+			n |= int(b) << (8 * i)
+		}
+		return n, nil
+	default:
+		n := -1
+		for i := 0; i < -c; i++ {
+			b, err := d.readByte()
+			if err != nil {
+				return 0, err
+			}
+			n &= ^(0xff << (8 * i))
+			n |= int(b) << (8 * i)
+		}
+		return n, nil
+	}
+}
+
+// readCount 读取数组、哈希或实例变量的元素个数，负数视为数据损坏
+// 每个元素至少占一个字节，预分配的容量不超过剩余的字节数，避免损坏的长度导致一次分配大量内存
+func (d *marshalDecoder) readCount() (count int, capacity int, err error) {
+	count, err = d.readInt()
+	if err != nil {
+		return 0, 0, err
+	}
+	if count < 0 {
+		return 0, 0, fmt.Errorf("negative marshal length %d at offset %d", count, d.pos)
+	}
+	capacity = count
+	if remaining := len(d.data) - d.pos; capacity > remaining {
+		capacity = remaining
+	}
+	return count, capacity, nil
+}
+
+func (d *marshalDecoder) readBytes() ([]byte, error) {
+	length, err := d.readInt()
+	if err != nil {
+		return nil, err
+	}
+	if length < 0 || d.pos+length > len(d.data) {
+		return nil, errMarshalTruncated
+	}
+	bytes := d.data[d.pos : d.pos+length]
+	d.pos += length
+	return bytes, nil
+}
+
+func (d *marshalDecoder) readSymbol() (string, error) {
+	bytes, err := d.readBytes()
+	if err != nil {
+		return "", err
+	}
+	symbol := string(bytes)
+	d.symbols = append(d.symbols, symbol)
+	return symbol, nil
+}
+
+// unmarshalMarshalDependencies 把bundler依赖接口的Marshal响应转换为依赖列表
+// 响应是一个数组，每个元素描述一个gem的一个版本：
+//
+//	{:name => "rails", :number => "7.0.5", :platform => "ruby", :dependencies => [["actionpack", "= 7.0.5"], ...]}
+//
+// 每个版本的每条依赖转换为一个DependencyInfo，bundler接口只返回运行时依赖
+func unmarshalMarshalDependencies(data []byte) ([]*models.DependencyInfo, error) {
+	value, err := unmarshalRuby(data)
+	if err != nil {
+		return nil, err
+	}
+	specs, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected marshal dependencies payload: %T", value)
+	}
+
+	dependencies := make([]*models.DependencyInfo, 0)
+	for _, spec := range specs {
+		hash, ok := spec.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected marshal dependency spec: %T", spec)
+		}
+		name, _ := hash["name"].(string)
+		pairs, _ := hash["dependencies"].([]interface{})
+		for _, pair := range pairs {
+			fields, ok := pair.([]interface{})
+			if !ok || len(fields) < 2 {
+				return nil, fmt.Errorf("unexpected marshal dependency of %s: %v", name, pair)
+			}
+			dependentName, _ := fields[0].(string)
+			requirements, _ := fields[1].(string)
+			dependencies = append(dependencies, &models.DependencyInfo{
+				Name:          name,
+				DependentName: dependentName,
+				Requirements:  requirements,
+				DependentType: models.DependencyTypeRuntime,
+			})
+		}
+	}
+	return dependencies, nil
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// bundler依赖接口的Marshal响应样例，对应:
+//
+//	Marshal.dump([
+//	  {name: "rack", number: "2.2.7", platform: "ruby", dependencies: []},
+//	  {name: "rails", number: "7.0.5", platform: "ruby", dependencies: [["actionpack", "= 7.0.5"], ["bundler", ">= 1.15.0"]]},
+//	])
+const marshalDependenciesSample = "\x04\x08\x5b\x07\x7b\x09\x3a\x09\x6e\x61\x6d\x65\x49\x22\x09\x72\x61\x63\x6b\x06\x3a\x06\x45\x54" +
+	"\x3a\x0b\x6e\x75\x6d\x62\x65\x72\x49\x22\x0a\x32\x2e\x32\x2e\x37\x06\x3b\x06\x54\x3a\x0d\x70\x6c" +
+	"\x61\x74\x66\x6f\x72\x6d\x49\x22\x09\x72\x75\x62\x79\x06\x3b\x06\x54\x3a\x11\x64\x65\x70\x65\x6e" +
+	"\x64\x65\x6e\x63\x69\x65\x73\x5b\x00\x7b\x09\x3b\x00\x49\x22\x0a\x72\x61\x69\x6c\x73\x06\x3b\x06" +
+	"\x54\x3b\x07\x49\x22\x0a\x37\x2e\x30\x2e\x35\x06\x3b\x06\x54\x3b\x08\x49\x22\x09\x72\x75\x62\x79" +
+	"\x06\x3b\x06\x54\x3b\x09\x5b\x07\x5b\x07\x49\x22\x0f\x61\x63\x74\x69\x6f\x6e\x70\x61\x63\x6b\x06" +
+	"\x3b\x06\x54\x49\x22\x0c\x3d\x20\x37\x2e\x30\x2e\x35\x06\x3b\x06\x54\x5b\x07\x49\x22\x0c\x62\x75" +
+	"\x6e\x64\x6c\x65\x72\x06\x3b\x06\x54\x49\x22\x0e\x3e\x3d\x20\x31\x2e\x31\x35\x2e\x30\x06\x3b\x06" +
+	"\x54"
+
+func TestUnmarshalRuby(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     string
+		expected interface{}
+	}{
+		{"nil", "\x04\x080", nil},
+		{"true", "\x04\x08T", true},
+		{"小整数", "\x04\x08i\x0a", 5},
+		{"多字节整数", "\x04\x08i\x02\xe8\x03", 1000},
+		{"负数", "\x04\x08i\xfa", -1},
+		{"多字节负数", "\x04\x08i\xfe\x18\xfc", -1000},
+		{"对象引用", "\x04\x08[\x07I\"\x06a\x06:\x06ET@\x06", []interface{}{"a", "a"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			value, err := unmarshalRuby([]byte(tc.data))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, value)
+		})
+	}
+
+	_, err := unmarshalRuby([]byte("\x04\x08[\x07"))
+	assert.Error(t, err)
+	_, err = unmarshalRuby([]byte("\x03\x00"))
+	assert.Error(t, err)
+}
+
+func TestRepository_GetDependencies_Marshal(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/dependencies", r.URL.Path)
+		assert.Equal(t, "rack,rails", r.URL.Query().Get("gems"))
+		assert.Equal(t, "application/octet-stream", r.Header.Get("Accept"))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write([]byte(marshalDependenciesSample))
+	}))
+	defer server.Close()
+
+	repo := NewRepository(NewOptions().
+		SetServerURL(server.URL).
+		SetDependencyFormat(DependencyFormatMarshal).
+		DisableRetry())
+
+	dependencies, err := repo.GetDependencies(context.Background(), "rack", "rails")
+	assert.NoError(t, err)
+	assert.Equal(t, []*models.DependencyInfo{
+		{Name: "rails", DependentName: "actionpack", Requirements: "= 7.0.5", DependentType: "runtime"},
+		{Name: "rails", DependentName: "bundler", Requirements: ">= 1.15.0", DependentType: "runtime"},
+	}, dependencies)
+}
+
+func TestUnmarshalRuby_Malformed(t *testing.T) {
+	testCases := []struct {
+		name string
+		data string
+	}{
+		{"负数长度的数组", "\x04\x08[\xfa"},
+		{"负数长度的哈希", "\x04\x08{\xfa"},
+		{"负数个数的实例变量", "\x04\x08I\"\x06a\xfa"},
+		// 4字节长度声明了约20亿个元素，实际没有数据
+		{"超大长度的数组", "\x04\x08[\x04\xff\xff\xff\x7f"},
+		{"超大长度的哈希", "\x04\x08{\x04\xff\xff\xff\x7f"},
+		{"超大个数的实例变量", "\x04\x08I\"\x06a\x04\xff\xff\xff\x7f"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := unmarshalRuby([]byte(tc.data))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRepository_GetDependencies_MarshalMalformed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write([]byte("\x04\x08[\xfa"))
+	}))
+	defer server.Close()
+
+	repo := NewRepository(NewOptions().
+		SetServerURL(server.URL).
+		SetDependencyFormat(DependencyFormatMarshal).
+		DisableRetry())
+
+	_, err := repo.GetDependencies(context.Background(), "rack")
+	assert.Error(t, err)
+}
@@ -1,5 +1,13 @@
 package repository
 
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+)
+
 // ------------------------------------------------- --------------------------------------------------------------------
 
 const ServerURLRubyChina = "https://gems.ruby-china.com"
@@ -33,3 +41,235 @@ const ServerURLAliYun = "https://mirrors.aliyun.com/rubygems"
 func NewAliYunRepository() Repository {
 	return NewRepository(NewOptions().SetServerURL(ServerURLAliYun))
 }
+
+// ------------------------------------------------- --------------------------------------------------------------------
+
+// VersionLag 比较镜像仓库与官方仓库中同一个gem的版本列表，用来判断镜像的同步是否落后
+// 返回镜像缺少的版本数量以及缺少的版本，缺少的版本保持官方仓库版本列表中的顺序（通常最新的在前）。
+// 非ruby平台的版本带上平台后缀，例如 "1.15.4-x86_64-linux"。
+// 只在镜像中存在的版本不计入落后数量；镜像中还没有这个gem（返回ErrNotFound）时官方仓库的全部版本都算作缺少
+func VersionLag(ctx context.Context, official, mirror Repository, gemName string) (lag int, missing []string, err error) {
+	officialVersions, err := official.GetGemVersions(ctx, gemName)
+	if err != nil {
+		return 0, nil, fmt.Errorf("official versions of %s: %w", gemName, err)
+	}
+	mirrorVersions, err := mirror.GetGemVersions(ctx, gemName)
+	if err != nil && !IsNotFound(err) {
+		return 0, nil, fmt.Errorf("mirror versions of %s: %w", gemName, err)
+	}
+
+	synced := make(map[string]bool, len(mirrorVersions))
+	for _, version := range mirrorVersions {
+		if version != nil {
+			synced[versionKey(version)] = true
+		}
+	}
+
+	missing = make([]string, 0)
+	for _, version := range officialVersions {
+		if version == nil {
+			continue
+		}
+		if key := versionKey(version); !synced[key] {
+			missing = append(missing, key)
+		}
+	}
+	return len(missing), missing, nil
+}
+
+// versionKey 用版本号和平台标识一个版本，ruby平台省略平台后缀
+func versionKey(version *models.Version) string {
+	if version.Platform == "" || version.Platform == "ruby" {
+		return version.Number
+	}
+	return version.Number + "-" + version.Platform
+}
+
+// ------------------------------------------------- --------------------------------------------------------------------
+
+// MirrorFallbackRepository 优先从镜像仓库读取，镜像返回资源不存在时再向官方仓库请求一次
+// 镜像的同步通常有延迟，刚发布的gem或版本可能只在官方仓库中存在。只有IsNotFound的错误才会回退，
+// 镜像的限流、5xx等其它错误直接返回，不会把所有流量都转到官方仓库；
+// 官方仓库同样返回资源不存在时说明gem确实不存在，返回的错误仍然满足IsNotFound。
+// GemExists在镜像返回不存在时也会回退到官方仓库
+type MirrorFallbackRepository struct {
+	mirror   Repository
+	official Repository
+}
+
+var _ Repository = (*MirrorFallbackRepository)(nil)
+
+// NewMirrorWithOfficialFallback 创建优先使用mirror、缺少数据时回退到官方仓库（rubygems.org）的仓库
+// officialOptions是官方仓库使用的选项，没有传入时：mirror是NewRepository创建的仓库则复制它的选项，
+// 只把ServerURL换成官方仓库地址，这样Token、代理、超时、中间件和重试设置在回退时仍然生效；否则使用默认选项
+func NewMirrorWithOfficialFallback(mirror Repository, officialOptions ...*Options) Repository {
+	var options *Options
+	if len(officialOptions) > 0 && officialOptions[0] != nil {
+		options = officialOptions[0]
+	} else if impl, ok := mirror.(*RepositoryImpl); ok {
+		options = impl.options.clone()
+		options.ServerURL = DefaultServerURL
+	}
+	return newMirrorFallbackRepository(mirror, NewRepository(options))
+}
+
+func newMirrorFallbackRepository(mirror, official Repository) *MirrorFallbackRepository {
+	return &MirrorFallbackRepository{mirror: mirror, official: official}
+}
+
+// withOfficialFallback 在镜像上调用fn，镜像返回资源不存在时改为在官方仓库上调用
+func withOfficialFallback[T any](ctx context.Context, m *MirrorFallbackRepository, fn func(Repository) (T, error)) (T, error) {
+	value, err := fn(m.mirror)
+	if err == nil || !IsNotFound(err) || ctx.Err() != nil {
+		return value, err
+	}
+	value, err = fn(m.official)
+	if err != nil && IsNotFound(err) {
+		return value, fmt.Errorf("not found in mirror or official repository: %w", err)
+	}
+	return value, err
+}
+
+// GetPackage implements the Repository interface
+func (m *MirrorFallbackRepository) GetPackage(ctx context.Context, gemName string) (*models.PackageInformation, error) {
+	return withOfficialFallback(ctx, m, func(repo Repository) (*models.PackageInformation, error) {
+		return repo.GetPackage(ctx, gemName)
+	})
+}
+
+// GemExists implements the Repository interface
+// 镜像中不存在时再检查官方仓库
+func (m *MirrorFallbackRepository) GemExists(ctx context.Context, gemName string) (bool, error) {
+	exists, err := m.mirror.GemExists(ctx, gemName)
+	if err != nil || exists {
+		return exists, err
+	}
+	return m.official.GemExists(ctx, gemName)
+}
+
+// Search implements the Repository interface
+func (m *MirrorFallbackRepository) Search(ctx context.Context, query string, page int) ([]*models.PackageInformation, error) {
+	return withOfficialFallback(ctx, m, func(repo Repository) ([]*models.PackageInformation, error) {
+		return repo.Search(ctx, query, page)
+	})
+}
+
+// SearchByLicense implements the Repository interface
+func (m *MirrorFallbackRepository) SearchByLicense(ctx context.Context, license string, page int) ([]*models.PackageInformation, error) {
+	return withOfficialFallback(ctx, m, func(repo Repository) ([]*models.PackageInformation, error) {
+		return repo.SearchByLicense(ctx, license, page)
+	})
+}
+
+// GetGemVersions implements the Repository interface
+func (m *MirrorFallbackRepository) GetGemVersions(ctx context.Context, gemName string) ([]*models.Version, error) {
+	return withOfficialFallback(ctx, m, func(repo Repository) ([]*models.Version, error) {
+		return repo.GetGemVersions(ctx, gemName)
+	})
+}
+
+// GetGemLatestVersion implements the Repository interface
+func (m *MirrorFallbackRepository) GetGemLatestVersion(ctx context.Context, gemName string) (*models.LatestVersion, error) {
+	return withOfficialFallback(ctx, m, func(repo Repository) (*models.LatestVersion, error) {
+		return repo.GetGemLatestVersion(ctx, gemName)
+	})
+}
+
+// GetGemVersion implements the Repository interface
+// 镜像中还没有同步的版本会从官方仓库获取
+func (m *MirrorFallbackRepository) GetGemVersion(ctx context.Context, gemName, version string) (*models.Version, error) {
+	return withOfficialFallback(ctx, m, func(repo Repository) (*models.Version, error) {
+		return repo.GetGemVersion(ctx, gemName, version)
+	})
+}
+
+// FindVersionMatching implements the Repository interface
+// 镜像中没有满足约束的版本时，从官方仓库中查找
+func (m *MirrorFallbackRepository) FindVersionMatching(ctx context.Context, gemName, constraint string) (*models.Version, error) {
+	return withOfficialFallback(ctx, m, func(repo Repository) (*models.Version, error) {
+		return repo.FindVersionMatching(ctx, gemName, constraint)
+	})
+}
+
+// GetTimeFrameVersions implements the Repository interface
+func (m *MirrorFallbackRepository) GetTimeFrameVersions(ctx context.Context, from, to time.Time) ([]*models.Version, error) {
+	return withOfficialFallback(ctx, m, func(repo Repository) ([]*models.Version, error) {
+		return repo.GetTimeFrameVersions(ctx, from, to)
+	})
+}
+
+// Downloads implements the Repository interface
+func (m *MirrorFallbackRepository) Downloads(ctx context.Context) (*models.RepositoryDownloadCount, error) {
+	return withOfficialFallback(ctx, m, func(repo Repository) (*models.RepositoryDownloadCount, error) {
+		return repo.Downloads(ctx)
+	})
+}
+
+// VersionDownloads implements the Repository interface
+func (m *MirrorFallbackRepository) VersionDownloads(ctx context.Context, gemName, gemVersion string) (*models.VersionDownloadCount, error) {
+	return withOfficialFallback(ctx, m, func(repo Repository) (*models.VersionDownloadCount, error) {
+		return repo.VersionDownloads(ctx, gemName, gemVersion)
+	})
+}
+
+// GetDependencies implements the Repository interface
+// 依赖接口对不存在的gem返回空结果而不是404，所以只有镜像整体返回资源不存在时才会回退
+func (m *MirrorFallbackRepository) GetDependencies(ctx context.Context, gemNames ...string) ([]*models.DependencyInfo, error) {
+	return withOfficialFallback(ctx, m, func(repo Repository) ([]*models.DependencyInfo, error) {
+		return repo.GetDependencies(ctx, gemNames...)
+	})
+}
+
+// LatestGems implements the Repository interface
+func (m *MirrorFallbackRepository) LatestGems(ctx context.Context) ([]*models.PackageInformation, error) {
+	return withOfficialFallback(ctx, m, func(repo Repository) ([]*models.PackageInformation, error) {
+		return repo.LatestGems(ctx)
+	})
+}
+
+// JustUpdatedGems implements the Repository interface
+func (m *MirrorFallbackRepository) JustUpdatedGems(ctx context.Context) ([]*models.PackageInformation, error) {
+	return withOfficialFallback(ctx, m, func(repo Repository) ([]*models.PackageInformation, error) {
+		return repo.JustUpdatedGems(ctx)
+	})
+}
+
+// GetReverseDependencies implements the Repository interface
+func (m *MirrorFallbackRepository) GetReverseDependencies(ctx context.Context, gemName string) ([]string, error) {
+	return withOfficialFallback(ctx, m, func(repo Repository) ([]string, error) {
+		return repo.GetReverseDependencies(ctx, gemName)
+	})
+}
+
+// BulkGetPackages implements the Repository interface
+// 每个包单独回退
+func (m *MirrorFallbackRepository) BulkGetPackages(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[*models.PackageInformation] {
+	return bulkExecute(ctx, gemNames, options, m.GetPackage)
+}
+
+// BulkGetVersions implements the Repository interface
+func (m *MirrorFallbackRepository) BulkGetVersions(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[[]*models.Version] {
+	return bulkExecute(ctx, gemNames, options, m.GetGemVersions)
+}
+
+// BulkGetDependencies implements the Repository interface
+func (m *MirrorFallbackRepository) BulkGetDependencies(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[[]*models.DependencyInfo] {
+	return bulkGetDependencies(ctx, gemNames, options, m.GetDependencies)
+}
+
+// BulkGetReverseDependencies implements the Repository interface
+func (m *MirrorFallbackRepository) BulkGetReverseDependencies(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[[]string] {
+	return bulkExecute(ctx, gemNames, options, m.GetReverseDependencies)
+}
+
+// BulkGemExists implements the Repository interface
+func (m *MirrorFallbackRepository) BulkGemExists(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[bool] {
+	return bulkExecute(ctx, gemNames, options, m.GemExists)
+}
+
+// BulkSearch implements the Repository interface
+func (m *MirrorFallbackRepository) BulkSearch(ctx context.Context, queries []string, page int, options *BulkOptions) []*BulkResult[[]*models.PackageInformation] {
+	return bulkExecute(ctx, queries, options, func(ctx context.Context, query string) ([]*models.PackageInformation, error) {
+		return m.Search(ctx, query, page)
+	})
+}
@@ -2,9 +2,13 @@ package repository
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -117,3 +121,136 @@ func TestLiveAPI_MirrorFunctionality(t *testing.T) {
 		}
 	})
 }
+
+func TestVersionLag(t *testing.T) {
+	official := newMockRepository()
+	official.delay = 0
+	official.mockVersions["nokogiri"] = []*models.Version{
+		{Number: "1.15.5", Platform: "ruby"},
+		{Number: "1.15.5", Platform: "x86_64-linux"},
+		{Number: "1.15.4", Platform: "ruby"},
+		{Number: "1.15.3", Platform: "ruby"},
+	}
+
+	mirror := newMockRepository()
+	mirror.delay = 0
+	mirror.mockVersions["nokogiri"] = []*models.Version{
+		{Number: "1.15.4", Platform: "ruby"},
+		{Number: "1.15.3", Platform: "ruby"},
+	}
+
+	lag, missing, err := VersionLag(context.Background(), official, mirror, "nokogiri")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, lag)
+	assert.Equal(t, []string{"1.15.5", "1.15.5-x86_64-linux"}, missing)
+
+	// 同步完整的镜像没有落后
+	lag, missing, err = VersionLag(context.Background(), official, official, "nokogiri")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, lag)
+	assert.Empty(t, missing)
+
+	// 镜像中还没有这个gem时全部版本都算作落后
+	mirror.setFailOn("nokogiri", ErrNotFound)
+	lag, missing, err = VersionLag(context.Background(), official, mirror, "nokogiri")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, lag)
+	assert.Len(t, missing, 4)
+
+	// 任意一方请求失败时返回错误
+	mirror.setFailOn("nokogiri", ErrServerError)
+	_, _, err = VersionLag(context.Background(), official, mirror, "nokogiri")
+	assert.ErrorIs(t, err, ErrServerError)
+}
+
+func TestMirrorWithOfficialFallback(t *testing.T) {
+	mirror := newTestRepository(t, map[string]string{
+		"/api/v1/gems/rails.json":     `{"name": "rails", "version": "7.1.1"}`,
+		"/api/v1/versions/rails.json": `[{"number": "7.1.1"}, {"number": "7.1.0"}]`,
+		"/api/v1/gems/broken.json":    `not json`,
+	})
+	official := newTestRepository(t, map[string]string{
+		"/api/v1/gems/rails.json":       `{"name": "rails", "version": "7.1.2"}`,
+		"/api/v1/gems/brand-new.json":   `{"name": "brand-new", "version": "0.1.0"}`,
+		"/api/v1/versions/rails.json":   `[{"number": "7.1.2"}, {"number": "7.1.1"}, {"number": "7.1.0"}]`,
+		"/api/v1/gems/broken.json":      `{"name": "broken"}`,
+		"/api/v1/versions/sinatra.json": `[{"number": "4.0.0"}]`,
+	})
+	repo := newMirrorFallbackRepository(mirror, official)
+	ctx := context.Background()
+
+	// 镜像中有的gem直接使用镜像的数据
+	pkg, err := repo.GetPackage(ctx, "rails")
+	assert.NoError(t, err)
+	assert.Equal(t, "7.1.1", pkg.Version)
+
+	// 镜像中还没有同步的gem从官方仓库获取
+	pkg, err = repo.GetPackage(ctx, "brand-new")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.1.0", pkg.Version)
+	exists, err := repo.GemExists(ctx, "brand-new")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	// 镜像中没有满足约束的版本时从官方仓库查找
+	version, err := repo.FindVersionMatching(ctx, "rails", ">= 7.1.2")
+	assert.NoError(t, err)
+	assert.Equal(t, "7.1.2", version.Number)
+
+	// 两边都不存在时仍然是资源不存在
+	_, err = repo.GetPackage(ctx, "no-such-gem")
+	assert.True(t, IsNotFound(err))
+	assert.ErrorIs(t, err, ErrNotFound)
+	exists, err = repo.GemExists(ctx, "no-such-gem")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	// 镜像的其它错误不会回退
+	_, err = repo.GetPackage(ctx, "broken")
+	assert.Error(t, err)
+	assert.False(t, IsNotFound(err))
+
+	// 批量操作中每个包单独回退
+	results := repo.BulkGetVersions(ctx, []string{"rails", "sinatra", "no-such-gem"}, nil)
+	assert.Len(t, results[0].Value, 2)
+	assert.Len(t, results[1].Value, 1)
+	assert.True(t, IsNotFound(results[2].Error))
+}
+
+func TestMirrorWithOfficialFallback_KeepsOptions(t *testing.T) {
+	// 官方仓库的请求通过URLRewriter转到测试服务器，检查回退时仍然带着调用方的Token
+	var authorization string
+	official := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`{"name": "sinatra", "version": "3.1.0"}`))
+	}))
+	defer official.Close()
+	mirrorServer := httptest.NewServer(http.NotFoundHandler())
+	defer mirrorServer.Close()
+
+	mirrorOptions := NewOptions().
+		SetServerURL(mirrorServer.URL).
+		SetToken("secret").
+		SetTimeout(5 * time.Second).
+		SetURLRewriter(func(u string) string {
+			return strings.Replace(u, DefaultServerURL, official.URL, 1)
+		})
+	repo := NewMirrorWithOfficialFallback(NewRepository(mirrorOptions))
+
+	pkg, err := repo.GetPackage(context.Background(), "sinatra")
+	assert.NoError(t, err)
+	assert.Equal(t, "3.1.0", pkg.Version)
+	assert.Equal(t, "Bearer secret", authorization)
+
+	fallback := repo.(*MirrorFallbackRepository).official.(*RepositoryImpl)
+	assert.Equal(t, DefaultServerURL, fallback.options.ServerURL)
+	assert.Equal(t, 5*time.Second, fallback.options.Timeout)
+	assert.Same(t, mirrorOptions.RetryOptions, fallback.options.RetryOptions)
+	// 镜像自己的选项不受影响
+	assert.Equal(t, mirrorServer.URL, mirrorOptions.ServerURL)
+
+	// 显式传入的选项优先
+	officialOptions := NewOptions().SetProxy("http://127.0.0.1:7890")
+	repo = NewMirrorWithOfficialFallback(NewRepository(mirrorOptions), officialOptions)
+	assert.Same(t, officialOptions, repo.(*MirrorFallbackRepository).official.(*RepositoryImpl).options)
+}
@@ -1,8 +1,56 @@
 package repository
 
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"time"
+)
+
 // DefaultServerURL 默认的仓库地址，直接连接到官方仓库
 const DefaultServerURL = "https://rubygems.org"
 
+// DependencyFormat 表示依赖接口响应的格式
+type DependencyFormat string
+
+const (
+	// DependencyFormatJSON 请求JSON格式的依赖信息，这是默认格式
+	DependencyFormatJSON DependencyFormat = "json"
+
+	// DependencyFormatMarshal 请求bundler使用的Ruby Marshal格式，用于只提供这种格式的bundler风格镜像
+	DependencyFormatMarshal DependencyFormat = "marshal"
+)
+
+// 操作名称，用于在Options.Timeouts中为单个操作配置超时时间
+const (
+	OperationGetPackage             = "GetPackage"
+	OperationGemExists              = "GemExists"
+	OperationGetPackageAtVersion    = "GetPackageAtVersion"
+	OperationGetPackageSummary      = "GetPackageSummary"
+	OperationSearch                 = "Search"
+	OperationGetGemVersions         = "GetGemVersions"
+	OperationGetGemLatestVersion    = "GetGemLatestVersion"
+	OperationGetGemVersion          = "GetGemVersion"
+	OperationGetTimeFrameVersions   = "GetTimeFrameVersions"
+	OperationDownloads              = "Downloads"
+	OperationVersionDownloads       = "VersionDownloads"
+	OperationGetDependencies        = "GetDependencies"
+	OperationLatestGems             = "LatestGems"
+	OperationJustUpdatedGems        = "JustUpdatedGems"
+	OperationGetReverseDependencies = "GetReverseDependencies"
+	OperationGetProvenance          = "GetProvenance"
+	OperationGetOwners              = "GetOwners"
+	OperationGetGemsByOwner         = "GetGemsByOwner"
+	OperationGetChangelog           = "GetChangelog"
+	OperationDownloadGem            = "DownloadGem"
+	OperationRefreshIndex           = "RefreshIndex"
+	OperationGetAllGemNames         = "GetAllGemNames"
+	OperationGetCompactInfo         = "GetCompactInfo"
+	OperationPushGem                = "PushGem"
+	OperationYankGem                = "YankGem"
+)
+
 type Options struct {
 
 	// 仓库的服务器地址
@@ -17,15 +65,87 @@ type Options struct {
 
 	// 请求重试选项
 	RetryOptions *RetryOptions
+
+	// 依赖接口使用的响应格式，默认为JSON
+	DependencyFormat DependencyFormat
+
+	// 检查GetPackage等接口返回的包名与请求的包名是否一致（不区分大小写），不一致时返回ErrResponseMismatch
+	// 用于防范配置错误的镜像或者缓存代理把其它gem的数据当作请求的gem返回
+	VerifyResponseGemName bool
+
+	// 全局超时时间，每个操作（包括重试）都需要在这个时间内完成，0表示不限制
+	Timeout time.Duration
+
+	// 按操作名称覆盖的超时时间，键为Operation开头的常量
+	// 没有配置的操作使用全局超时时间
+	Timeouts map[string]time.Duration
+
+	// 发送请求使用的Transport，为nil时使用默认的Transport
+	// 可以用来接入自定义的连接池、测试桩或者离线的响应源，设置后Proxy不再生效
+	Transport http.RoundTripper
+
+	// 请求中间件，按注册顺序在每个请求发送之前执行
+	RequestMiddlewares []RequestMiddleware
+
+	// 响应中间件，按注册顺序在解析每个响应之前执行
+	ResponseMiddlewares []ResponseMiddleware
+
+	// 在每个请求发送之前改写请求地址，为nil时不改写
+	// 可以把发往rubygems.org的请求转到本地的缓存服务器，而不需要修改ServerURL
+	URLRewriter func(string) string
+
+	// 随机数来源，用于重试退避的随机抖动等需要随机性的功能，为nil时使用安全随机种子初始化的来源
+	// 测试中可以通过SetRandSeed固定种子以得到可重现的行为，设置后不要在其它地方并发使用同一个rand.Rand
+	Rand *rand.Rand
 }
 
+// RequestMiddleware 在请求发送之前执行，可以修改请求，例如添加请求头或者对请求签名
+// 返回错误时请求不会被发送
+type RequestMiddleware func(*http.Request) error
+
+// ResponseMiddleware 在响应被解析之前执行，可以检查响应，例如记录响应头或者拒绝不符合预期的响应
+// 返回错误时响应不会被解析，这个错误作为请求的错误返回（可能触发重试）
+type ResponseMiddleware func(*http.Response) error
+
 func NewOptions() *Options {
 	return &Options{
-		ServerURL:    DefaultServerURL,
-		Proxy:        "",
-		Token:        "",
-		RetryOptions: NewDefaultRetryOptions(),
+		ServerURL:        DefaultServerURL,
+		Proxy:            "",
+		Token:            "",
+		RetryOptions:     NewDefaultRetryOptions(),
+		DependencyFormat: DependencyFormatJSON,
+	}
+}
+
+// clone 复制选项，Timeouts和中间件列表会被复制，修改副本不会影响原来的选项
+// Rand不能被两个仓库同时使用，副本中为nil，使用安全随机种子初始化新的来源
+func (x *Options) clone() *Options {
+	cloned := *x
+	if x.Timeouts != nil {
+		cloned.Timeouts = make(map[string]time.Duration, len(x.Timeouts))
+		for operation, timeout := range x.Timeouts {
+			cloned.Timeouts[operation] = timeout
+		}
+	}
+	cloned.RequestMiddlewares = append([]RequestMiddleware(nil), x.RequestMiddlewares...)
+	cloned.ResponseMiddlewares = append([]ResponseMiddleware(nil), x.ResponseMiddlewares...)
+	cloned.Rand = nil
+	return &cloned
+}
+
+// Validate 检查选项是否可用，目前会校验ServerURL必须是http或https协议的绝对地址
+func (x *Options) Validate() error {
+	serverURL, err := url.Parse(x.ServerURL)
+	if err != nil {
+		return fmt.Errorf("%w: server url %q: %v", ErrInvalidRequest, x.ServerURL, err)
 	}
+	if !serverURL.IsAbs() || serverURL.Host == "" {
+		return fmt.Errorf("%w: server url %q is not an absolute url", ErrInvalidRequest, x.ServerURL)
+	}
+	if serverURL.Scheme != "http" && serverURL.Scheme != "https" {
+		return fmt.Errorf("%w: server url %q has unsupported scheme %q", ErrInvalidRequest, x.ServerURL, serverURL.Scheme)
+	}
+	return nil
 }
 
 func (x *Options) SetServerURL(serverUrl string) *Options {
@@ -48,6 +168,79 @@ func (x *Options) SetRetryOptions(retryOptions *RetryOptions) *Options {
 	return x
 }
 
+// SetTransport 设置发送请求使用的Transport
+func (x *Options) SetTransport(transport http.RoundTripper) *Options {
+	x.Transport = transport
+	return x
+}
+
+// AddRequestMiddleware 追加一个请求中间件
+// 中间件只作用于发往仓库的请求，不会作用于changelog等第三方地址
+func (x *Options) AddRequestMiddleware(middleware RequestMiddleware) *Options {
+	x.RequestMiddlewares = append(x.RequestMiddlewares, middleware)
+	return x
+}
+
+// AddResponseMiddleware 追加一个响应中间件
+func (x *Options) AddResponseMiddleware(middleware ResponseMiddleware) *Options {
+	x.ResponseMiddlewares = append(x.ResponseMiddlewares, middleware)
+	return x
+}
+
+// SetURLRewriter 设置请求地址的改写函数，所有发出的请求（包括changelog等第三方地址）都会经过它
+// 只需要处理地址时比请求中间件更简单，例如把地址规范化或者指向本地代理
+func (x *Options) SetURLRewriter(rewriter func(string) string) *Options {
+	x.URLRewriter = rewriter
+	return x
+}
+
+// SetRand 设置随机数来源
+func (x *Options) SetRand(r *rand.Rand) *Options {
+	x.Rand = r
+	return x
+}
+
+// SetRandSeed 使用固定的种子创建随机数来源，相同种子的仓库会产生相同的随机序列
+func (x *Options) SetRandSeed(seed int64) *Options {
+	return x.SetRand(rand.New(rand.NewSource(seed)))
+}
+
+// SetTimeout 设置全局超时时间
+func (x *Options) SetTimeout(timeout time.Duration) *Options {
+	x.Timeout = timeout
+	return x
+}
+
+// SetOperationTimeout 为指定操作设置超时时间，覆盖全局超时时间
+// 例如为大包的反向依赖查询设置更长的超时：SetOperationTimeout(OperationGetReverseDependencies, time.Minute)
+func (x *Options) SetOperationTimeout(operation string, timeout time.Duration) *Options {
+	if x.Timeouts == nil {
+		x.Timeouts = make(map[string]time.Duration)
+	}
+	x.Timeouts[operation] = timeout
+	return x
+}
+
+// TimeoutFor 返回指定操作的超时时间，没有单独配置时返回全局超时时间
+func (x *Options) TimeoutFor(operation string) time.Duration {
+	if timeout, ok := x.Timeouts[operation]; ok {
+		return timeout
+	}
+	return x.Timeout
+}
+
+// SetDependencyFormat 设置依赖接口使用的响应格式
+func (x *Options) SetDependencyFormat(format DependencyFormat) *Options {
+	x.DependencyFormat = format
+	return x
+}
+
+// SetVerifyResponseGemName 设置是否检查响应中的包名与请求的包名一致
+func (x *Options) SetVerifyResponseGemName(verify bool) *Options {
+	x.VerifyResponseGemName = verify
+	return x
+}
+
 // DisableRetry 禁用重试功能
 func (x *Options) DisableRetry() *Options {
 	x.RetryOptions = nil
@@ -1,6 +1,11 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,6 +20,7 @@ func TestNewOptions(t *testing.T) {
 	assert.Equal(t, "", options.Proxy)
 	assert.Equal(t, "", options.Token)
 	assert.NotNil(t, options.RetryOptions)
+	assert.Equal(t, DependencyFormatJSON, options.DependencyFormat)
 }
 
 func TestOptions_SetServerURL(t *testing.T) {
@@ -28,6 +34,16 @@ func TestOptions_SetServerURL(t *testing.T) {
 	assert.Equal(t, "https://custom-rubygems.org", options.ServerURL)
 }
 
+func TestOptions_Validate(t *testing.T) {
+	assert.NoError(t, NewOptions().Validate())
+	assert.NoError(t, NewOptions().SetServerURL("http://127.0.0.1:8808").Validate())
+
+	for _, serverURL := range []string{"", "rubygems.org", "/api/v1", "ftp://rubygems.org", "https://"} {
+		err := NewOptions().SetServerURL(serverURL).Validate()
+		assert.ErrorIs(t, err, ErrInvalidRequest, serverURL)
+	}
+}
+
 func TestOptions_SetProxy(t *testing.T) {
 	options := NewOptions()
 
@@ -84,3 +100,122 @@ func TestOptions_DisableRetry(t *testing.T) {
 	// Verify retry was disabled
 	assert.Nil(t, options.RetryOptions)
 }
+
+func TestOptions_TimeoutFor(t *testing.T) {
+	options := NewOptions()
+	assert.Equal(t, time.Duration(0), options.TimeoutFor(OperationGetPackage))
+
+	result := options.SetTimeout(5*time.Second).SetOperationTimeout(OperationGetReverseDependencies, time.Minute)
+	assert.Same(t, options, result)
+
+	assert.Equal(t, 5*time.Second, options.TimeoutFor(OperationGetPackage))
+	assert.Equal(t, time.Minute, options.TimeoutFor(OperationGetReverseDependencies))
+}
+
+func TestOptions_Middlewares(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 验证中间件设置的请求头和执行顺序
+		if r.Header.Get("X-Signature") != "signed" || strings.Join(r.Header.Values("X-Trace"), ",") != "first,second" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("X-Server", "fixture")
+		_, _ = w.Write([]byte(`{"version": "7.1.2"}`))
+	}))
+	defer server.Close()
+
+	var serverHeader string
+	options := NewOptions().SetServerURL(server.URL).DisableRetry().
+		AddRequestMiddleware(func(request *http.Request) error {
+			request.Header.Set("X-Signature", "signed")
+			request.Header.Add("X-Trace", "first")
+			return nil
+		}).
+		AddRequestMiddleware(func(request *http.Request) error {
+			request.Header.Add("X-Trace", "second")
+			return nil
+		}).
+		AddResponseMiddleware(func(response *http.Response) error {
+			serverHeader = response.Header.Get("X-Server")
+			return nil
+		})
+	assert.Len(t, options.RequestMiddlewares, 2)
+	assert.Len(t, options.ResponseMiddlewares, 1)
+
+	latest, err := NewRepository(options).GetGemLatestVersion(context.Background(), "rails")
+	assert.NoError(t, err)
+	if assert.NotNil(t, latest) {
+		assert.Equal(t, "7.1.2", latest.Version)
+	}
+	assert.Equal(t, "fixture", serverHeader)
+
+	// 中间件返回错误时请求失败
+	rejected := errors.New("rejected by middleware")
+	options.AddResponseMiddleware(func(response *http.Response) error {
+		return rejected
+	})
+	_, err = NewRepository(options).GetGemLatestVersion(context.Background(), "rails")
+	assert.ErrorIs(t, err, rejected)
+}
+
+func TestOptions_SetURLRewriter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/versions/rails/latest.json" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"version": "7.1.2"}`))
+	}))
+	defer server.Close()
+
+	// ServerURL保持为官方地址，请求被改写到本地服务器
+	var rewritten []string
+	options := NewOptions().DisableRetry()
+	result := options.SetURLRewriter(func(targetUrl string) string {
+		rewritten = append(rewritten, targetUrl)
+		return strings.Replace(targetUrl, DefaultServerURL, server.URL, 1)
+	})
+	assert.Same(t, options, result)
+	assert.Equal(t, DefaultServerURL, options.ServerURL)
+
+	latest, err := NewRepository(options).GetGemLatestVersion(context.Background(), "rails")
+	assert.NoError(t, err)
+	if assert.NotNil(t, latest) {
+		assert.Equal(t, "7.1.2", latest.Version)
+	}
+	assert.Equal(t, []string{DefaultServerURL + "/api/v1/versions/rails/latest.json"}, rewritten)
+}
+
+func TestOptions_SetVerifyResponseGemName(t *testing.T) {
+	routes := map[string]string{
+		"/api/v1/gems/rails.json":                    `{"name": "rack", "version": "3.0.8"}`,
+		"/api/v1/gems/Rack.json":                     `{"name": "rack", "version": "3.0.8"}`,
+		"/api/v2/rubygems/rails/versions/7.1.2.json": `{"name": "rack", "version": "3.0.8"}`,
+	}
+
+	// 默认不检查
+	repo := newTestRepository(t, routes)
+	pkg, err := repo.GetPackage(context.Background(), "rails")
+	assert.NoError(t, err)
+	assert.Equal(t, "rack", pkg.Name)
+
+	options := NewOptions()
+	assert.Same(t, options, options.SetVerifyResponseGemName(true))
+	assert.True(t, options.VerifyResponseGemName)
+
+	repo = newTestRepository(t, routes)
+	repo.options.VerifyResponseGemName = true
+	_, err = repo.GetPackage(context.Background(), "rails")
+	assert.ErrorIs(t, err, ErrResponseMismatch)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `"rails"`)
+		assert.Contains(t, err.Error(), `"rack"`)
+	}
+	_, err = repo.GetPackageAtVersion(context.Background(), "rails", "7.1.2")
+	assert.ErrorIs(t, err, ErrResponseMismatch)
+
+	// 包名不区分大小写
+	pkg, err = repo.GetPackage(context.Background(), "Rack")
+	assert.NoError(t, err)
+	assert.Equal(t, "rack", pkg.Name)
+}
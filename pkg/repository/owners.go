@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+)
+
+// GetOwners 获取有权限发布gem新版本的所有者，可以用来审计供应链中谁能推送某个依赖
+// 部分gem限制了所有者的可见性，这时接口返回403，对应的错误满足IsUnauthorized
+// GET - /api/v1/gems/[GEM NAME]/owners.json
+func (x *RepositoryImpl) GetOwners(ctx context.Context, gemName string) ([]*models.Owner, error) {
+	targetUrl := fmt.Sprintf("%s/api/v1/gems/%s/owners.json", x.options.ServerURL, url.PathEscape(gemName))
+	return getJson[[]*models.Owner](ctx, x, OperationGetOwners, targetUrl)
+}
+
+// GetGemsByOwner 获取某个用户作为所有者的所有gem，可以用来监控与自己组织相近的仿冒包
+// 用户没有任何gem时返回空切片而不是错误，与Search的行为一致
+// GET - /api/v1/owners/[USER HANDLE]/gems.json
+func (x *RepositoryImpl) GetGemsByOwner(ctx context.Context, handle string) ([]*models.PackageInformation, error) {
+	targetUrl := fmt.Sprintf("%s/api/v1/owners/%s/gems.json", x.options.ServerURL, url.PathEscape(handle))
+	gems, err := getJson[[]*models.PackageInformation](ctx, x, OperationGetGemsByOwner, targetUrl)
+	if err != nil {
+		return nil, err
+	}
+	if gems == nil {
+		gems = []*models.PackageInformation{}
+	}
+	return gems, nil
+}
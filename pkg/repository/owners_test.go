@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_GetOwners(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/gems/rails/owners.json":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`[
+				{"id": 1, "handle": "dhh", "email": null},
+				{"id": 42, "handle": "rafaelfranca", "email": "rafael@example.com"}
+			]`))
+		case "/api/v1/gems/private/owners.json":
+			http.Error(w, "You do not have permission to view owners of this gem", http.StatusForbidden)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	repo := NewRepository(NewOptions().SetServerURL(server.URL).DisableRetry())
+
+	owners, err := repo.GetOwners(context.Background(), "rails")
+	assert.NoError(t, err)
+	if assert.Len(t, owners, 2) {
+		assert.Equal(t, 1, owners[0].ID)
+		assert.Equal(t, "dhh", owners[0].Handle)
+		assert.Empty(t, owners[0].Email)
+		assert.Equal(t, "rafael@example.com", owners[1].Email)
+	}
+
+	// 限制了可见性的gem返回ErrUnauthorized，而不是响应体的解析错误
+	_, err = repo.GetOwners(context.Background(), "private")
+	assert.True(t, IsUnauthorized(err))
+	var apiErr *APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, ErrUnauthorized, apiErr.Cause)
+	}
+
+	_, err = repo.GetOwners(context.Background(), "missing")
+	assert.True(t, IsNotFound(err))
+}
+
+func TestRepository_GetGemsByOwner(t *testing.T) {
+	repo := newTestRepository(t, map[string]string{
+		"/api/v1/owners/tenderlove/gems.json": `[
+			{"name": "psych", "version": "5.1.2", "downloads": 1000},
+			{"name": "nokogiri", "version": "1.15.4", "downloads": 2000}
+		]`,
+		"/api/v1/owners/newcomer/gems.json": `[]`,
+		"/api/v1/owners/nobody/gems.json":   `null`,
+	})
+
+	gems, err := repo.GetGemsByOwner(context.Background(), "tenderlove")
+	assert.NoError(t, err)
+	if assert.Len(t, gems, 2) {
+		assert.Equal(t, "psych", gems[0].Name)
+		assert.Equal(t, "1.15.4", gems[1].Version)
+	}
+
+	// 没有gem的用户返回空切片
+	for _, handle := range []string{"newcomer", "nobody"} {
+		gems, err = repo.GetGemsByOwner(context.Background(), handle)
+		assert.NoError(t, err)
+		assert.NotNil(t, gems)
+		assert.Empty(t, gems)
+	}
+}
@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+)
+
+// GetPackageSummary 获取gem包的精简信息，只包含名称、版本和下载量
+// 与GetPackage请求相同的接口，但解码时直接跳过依赖、元数据等其它字段，减少内存分配
+// GET - /api/v1/gems/[GEM NAME].(json|yaml)
+func (x *RepositoryImpl) GetPackageSummary(ctx context.Context, gemName string) (*models.PackageSummary, error) {
+	targetUrl := fmt.Sprintf("%s/api/v1/gems/%s.json", x.options.ServerURL, url.PathEscape(gemName))
+	return getJson[*models.PackageSummary](ctx, x, OperationGetPackageSummary, targetUrl)
+}
+
+// BulkGetPackageSummaries 批量获取多个包的精简信息
+// 并发执行GetPackageSummary请求，适合只需要名称、版本和下载量的大规模统计
+// 参数:
+//   - ctx: 上下文，用于控制请求超时和取消
+//   - gemNames: 要获取的包名列表
+//   - options: 批量操作选项，控制并发数等
+//
+// 返回:
+//   - 包含每个包请求结果的切片，顺序与输入包名相同
+func (x *RepositoryImpl) BulkGetPackageSummaries(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[*models.PackageSummary] {
+	return bulkExecute(ctx, gemNames, options, x.GetPackageSummary)
+}
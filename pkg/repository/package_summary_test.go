@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// packageFixture 生成一个带有依赖和元数据的完整包信息响应
+func packageFixture(name string, dependencies int) string {
+	runtime := make([]string, 0, dependencies)
+	for i := 0; i < dependencies; i++ {
+		runtime = append(runtime, fmt.Sprintf(`{"name": "%s-dep-%d", "requirements": ">= 1.%d.0"}`, name, i, i))
+	}
+	return fmt.Sprintf(`{
+		"name": %q,
+		"downloads": 436090160,
+		"version": "7.0.5",
+		"version_created_at": "2023-05-24T19:21:28.229Z",
+		"version_downloads": 54428,
+		"platform": "ruby",
+		"authors": "David Heinemeier Hansson",
+		"info": "Ruby on Rails is a full-stack web framework optimized for programmer happiness and sustainable productivity.",
+		"licenses": ["MIT"],
+		"metadata": {"changelog_uri": "https://github.com/rails/rails/releases/tag/v7.0.5", "source_code_uri": "https://github.com/rails/rails/tree/v7.0.5"},
+		"sha": "57ef2baa4a1f5f954bc6e5a019b1fac8486ece36f79c1cf366e6de33210637fe",
+		"project_uri": "https://rubygems.org/gems/rails",
+		"gem_uri": "https://rubygems.org/gems/rails-7.0.5.gem",
+		"homepage_uri": "https://rubyonrails.org",
+		"dependencies": {"development": [], "runtime": [%s]}
+	}`, name, strings.Join(runtime, ","))
+}
+
+func TestRepository_GetPackageSummary(t *testing.T) {
+	repo := newTestRepository(t, map[string]string{
+		"/api/v1/gems/rails.json": packageFixture("rails", 3),
+		"/api/v1/gems/rack.json":  packageFixture("rack", 0),
+	})
+
+	summary, err := repo.GetPackageSummary(context.Background(), "rails")
+	assert.NoError(t, err)
+	assert.Equal(t, &models.PackageSummary{
+		Name:             "rails",
+		Version:          "7.0.5",
+		Downloads:        436090160,
+		VersionDownloads: 54428,
+	}, summary)
+
+	results := repo.BulkGetPackageSummaries(context.Background(), []string{"rails", "rack", "missing"}, nil)
+	assert.Len(t, results, 3)
+	assert.NoError(t, results[0].Error)
+	assert.Equal(t, "rack", results[1].Value.Name)
+	assert.Error(t, results[2].Error)
+}
+
+// 对比解码完整包信息和精简信息的内存分配，运行: go test -bench PackageDecode -benchmem
+func benchmarkPackageDecode[T any](b *testing.B) {
+	batch := make([][]byte, 0, 100)
+	for i := 0; i < cap(batch); i++ {
+		batch = append(batch, []byte(packageFixture(fmt.Sprintf("gem-%d", i), 12)))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		results := make([]T, 0, len(batch))
+		for _, body := range batch {
+			value, err := unmarshalJson[T](body)
+			if err != nil {
+				b.Fatal(err)
+			}
+			results = append(results, value)
+		}
+	}
+}
+
+func BenchmarkPackageDecode_Full(b *testing.B) {
+	benchmarkPackageDecode[*models.PackageInformation](b)
+}
+
+func BenchmarkPackageDecode_Summary(b *testing.B) {
+	benchmarkPackageDecode[*models.PackageSummary](b)
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"sync"
+)
+
+// paginate 依次请求从1开始的每一页，直到某一页为空，返回所有页的结果
+// 适用于以page参数翻页、用空列表表示尾页的接口，例如搜索接口。
+// 第一页用来确定每页的大小，之后每次最多同时请求window页，window大于1时fetchPage会被并发调用。
+// keep不为nil时只保留keep返回true的结果，例如用来去重；limit大于0时最多返回limit个保留下来的结果，
+// 拿到足够的结果后不再请求后面的页。
+// 某一页请求失败或者ctx被取消时停止翻页，返回已经获取的结果和对应的错误
+func paginate[T any](ctx context.Context, window, limit int, fetchPage func(page int) ([]T, error), keep func(T) bool) ([]T, error) {
+	if window < 1 {
+		window = 1
+	}
+
+	var all []T
+	pageSize := 0
+	for page := 1; ; {
+		if err := ctx.Err(); err != nil {
+			return all, err
+		}
+
+		// 知道每页的大小之后，按还需要的结果数决定同时请求几页
+		count := 1
+		if pageSize > 0 {
+			count = window
+			if limit > 0 {
+				if needed := (limit - len(all) + pageSize - 1) / pageSize; needed < count {
+					count = needed
+				}
+			}
+		}
+
+		pages := make([][]T, count)
+		errs := make([]error, count)
+		if count == 1 {
+			pages[0], errs[0] = fetchPage(page)
+		} else {
+			var wg sync.WaitGroup
+			for i := 0; i < count; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					pages[i], errs[i] = fetchPage(page + i)
+				}(i)
+			}
+			wg.Wait()
+		}
+
+		for i, items := range pages {
+			if errs[i] != nil {
+				return all, errs[i]
+			}
+			if len(items) == 0 {
+				return all, nil
+			}
+			if len(items) > pageSize {
+				pageSize = len(items)
+			}
+			for _, item := range items {
+				if keep != nil && !keep(item) {
+					continue
+				}
+				all = append(all, item)
+				if limit > 0 && len(all) >= limit {
+					return all, nil
+				}
+			}
+		}
+		page += count
+	}
+}
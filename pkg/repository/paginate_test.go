@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaginate(t *testing.T) {
+	pages := map[int][]string{1: {"a", "b"}, 2: {"c"}}
+
+	var requested []int
+	items, err := paginate(context.Background(), 1, 0, func(page int) ([]string, error) {
+		requested = append(requested, page)
+		return pages[page], nil
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, items)
+	// 第3页为空，翻页结束
+	assert.Equal(t, []int{1, 2, 3}, requested)
+
+	// 出错时返回已经获取的结果
+	failure := errors.New("page 2 failed")
+	items, err = paginate(context.Background(), 1, 0, func(page int) ([]string, error) {
+		if page == 2 {
+			return nil, failure
+		}
+		return pages[page], nil
+	}, nil)
+	assert.ErrorIs(t, err, failure)
+	assert.Equal(t, []string{"a", "b"}, items)
+
+	// ctx取消后不再请求下一页
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	items, err = paginate(ctx, 1, 0, func(page int) ([]string, error) {
+		calls++
+		cancel()
+		return []string{"x"}, nil
+	}, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []string{"x"}, items)
+	assert.Equal(t, 1, calls)
+}
+
+func TestPaginate_WindowAndLimit(t *testing.T) {
+	pages := map[int][]string{1: {"a", "b"}, 2: {"b", "c"}, 3: {"d", "e"}, 4: {"f", "g"}, 5: {"h"}}
+
+	var mu sync.Mutex
+	var requested []int
+	fetchPage := func(page int) ([]string, error) {
+		mu.Lock()
+		requested = append(requested, page)
+		mu.Unlock()
+		return pages[page], nil
+	}
+	unique := func() func(string) bool {
+		seen := make(map[string]bool)
+		return func(item string) bool {
+			if seen[item] {
+				return false
+			}
+			seen[item] = true
+			return true
+		}
+	}
+
+	// 同时请求多页时结果仍然按页的顺序排列
+	items, err := paginate(context.Background(), 3, 0, fetchPage, unique())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d", "e", "f", "g", "h"}, items)
+	sort.Ints(requested)
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7}, requested)
+
+	// 第一页之后还需要3个结果，每页2个，只需要再请求2页
+	requested = nil
+	items, err = paginate(context.Background(), 3, 5, fetchPage, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "b", "c", "d"}, items)
+	sort.Ints(requested)
+	assert.Equal(t, []int{1, 2, 3}, requested)
+}
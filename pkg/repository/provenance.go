@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+)
+
+// sigstore为Fulcio证书定义的扩展，见 https://github.com/sigstore/fulcio/blob/main/docs/oid-info.md
+var (
+	oidFulcioIssuer           = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidFulcioIssuerV2         = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+	oidFulcioSourceRepository = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 12}
+	oidFulcioSourceDigest     = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 13}
+)
+
+// GetProvenance 获取gem包某个版本的构建来源信息
+// 信息来自rubygems.org为可信发布（trusted publishing）保存的sigstore证明，
+// 签名身份取自签名证书，源码仓库和提交优先取自证明中的SLSA构建描述。
+// 注意这里只做解析，不校验签名，需要校验时请使用sigstore的工具。
+// 版本没有附带证明或者镜像不提供证明接口时返回ErrUnsupportedOperation
+// GET - /api/v1/attestations/[GEM NAME]-[GEM VERSION].json
+func (x *RepositoryImpl) GetProvenance(ctx context.Context, gemName, version string) (*models.Provenance, error) {
+	targetUrl := fmt.Sprintf("%s/api/v1/attestations/%s-%s.json", x.options.ServerURL, url.PathEscape(gemName), url.PathEscape(version))
+	bytes, err := x.getBytes(ctx, OperationGetProvenance, targetUrl)
+	if IsNotFound(err) {
+		return nil, fmt.Errorf("%w: no attestation for %s-%s", ErrUnsupportedOperation, gemName, version)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 响应解析不了或者只有null同样说明没有可用的证明
+	bundles, err := unmarshalJson[[]*sigstoreBundle](bytes)
+	if err == nil {
+		for _, bundle := range bundles {
+			if bundle != nil {
+				return parseProvenance(bundle)
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: no attestation for %s-%s", ErrUnsupportedOperation, gemName, version)
+}
+
+// sigstoreBundle 是sigstore bundle中与来源信息有关的部分，兼容v0.2和v0.3两种格式
+type sigstoreBundle struct {
+	VerificationMaterial struct {
+		Certificate *struct {
+			RawBytes string `json:"rawBytes"`
+		} `json:"certificate"`
+		X509CertificateChain *struct {
+			Certificates []struct {
+				RawBytes string `json:"rawBytes"`
+			} `json:"certificates"`
+		} `json:"x509CertificateChain"`
+	} `json:"verificationMaterial"`
+	DsseEnvelope *struct {
+		Payload     string `json:"payload"`
+		PayloadType string `json:"payloadType"`
+	} `json:"dsseEnvelope"`
+}
+
+// slsaStatement 是in-toto声明中SLSA v1构建描述的相关字段
+type slsaStatement struct {
+	Predicate struct {
+		BuildDefinition struct {
+			ExternalParameters struct {
+				Workflow struct {
+					Repository string `json:"repository"`
+				} `json:"workflow"`
+			} `json:"externalParameters"`
+			ResolvedDependencies []struct {
+				URI    string            `json:"uri"`
+				Digest map[string]string `json:"digest"`
+			} `json:"resolvedDependencies"`
+		} `json:"buildDefinition"`
+	} `json:"predicate"`
+}
+
+// parseProvenance 从sigstore bundle中提取来源信息
+func parseProvenance(bundle *sigstoreBundle) (*models.Provenance, error) {
+	certificate, err := bundle.certificate()
+	if err != nil {
+		return nil, err
+	}
+
+	provenance := &models.Provenance{}
+	if len(certificate.URIs) > 0 {
+		provenance.SignerIdentity = certificate.URIs[0].String()
+	} else if len(certificate.EmailAddresses) > 0 {
+		provenance.SignerIdentity = certificate.EmailAddresses[0]
+	}
+	for _, extension := range certificate.Extensions {
+		switch {
+		case extension.Id.Equal(oidFulcioIssuerV2):
+			provenance.Issuer = decodeDERString(extension.Value)
+		case extension.Id.Equal(oidFulcioIssuer):
+			if provenance.Issuer == "" {
+				// 旧版扩展直接保存原始字符串
+				provenance.Issuer = string(extension.Value)
+			}
+		case extension.Id.Equal(oidFulcioSourceRepository):
+			provenance.SourceRepository = decodeDERString(extension.Value)
+		case extension.Id.Equal(oidFulcioSourceDigest):
+			provenance.SourceCommit = decodeDERString(extension.Value)
+		}
+	}
+
+	// 证明中的构建描述比证书扩展更详细，有的话优先使用
+	if statement := bundle.statement(); statement != nil {
+		definition := statement.Predicate.BuildDefinition
+		if repository := definition.ExternalParameters.Workflow.Repository; repository != "" {
+			provenance.SourceRepository = repository
+		}
+		for _, dependency := range definition.ResolvedDependencies {
+			if commit := dependency.Digest["gitCommit"]; commit != "" {
+				provenance.SourceCommit = commit
+				break
+			}
+		}
+	}
+
+	if provenance.SignerIdentity == "" {
+		return nil, fmt.Errorf("%w: attestation certificate has no signer identity", ErrUnsupportedOperation)
+	}
+	return provenance, nil
+}
+
+// certificate 返回bundle中的签名证书
+func (b *sigstoreBundle) certificate() (*x509.Certificate, error) {
+	rawBytes := ""
+	if b.VerificationMaterial.Certificate != nil {
+		rawBytes = b.VerificationMaterial.Certificate.RawBytes
+	} else if chain := b.VerificationMaterial.X509CertificateChain; chain != nil && len(chain.Certificates) > 0 {
+		rawBytes = chain.Certificates[0].RawBytes
+	}
+	if rawBytes == "" {
+		return nil, fmt.Errorf("%w: attestation has no signing certificate", ErrUnsupportedOperation)
+	}
+
+	der, err := base64.StdEncoding.DecodeString(rawBytes)
+	if err != nil {
+		return nil, fmt.Errorf("decode attestation certificate: %w", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse attestation certificate: %w", err)
+	}
+	return certificate, nil
+}
+
+// statement 解析DSSE信封中的in-toto声明，不存在或无法解析时返回nil
+func (b *sigstoreBundle) statement() *slsaStatement {
+	if b.DsseEnvelope == nil || !strings.Contains(b.DsseEnvelope.PayloadType, "in-toto") {
+		return nil
+	}
+	payload, err := base64.StdEncoding.DecodeString(b.DsseEnvelope.Payload)
+	if err != nil {
+		return nil
+	}
+	statement := &slsaStatement{}
+	if err := json.Unmarshal(payload, statement); err != nil {
+		return nil
+	}
+	return statement
+}
+
+// decodeDERString 解码DER编码的字符串扩展值，解码失败时按原始字符串处理
+func decodeDERString(value []byte) string {
+	var s string
+	if _, err := asn1.Unmarshal(value, &s); err != nil {
+		return string(value)
+	}
+	return s
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newProvenanceCertificate 生成一个带有Fulcio扩展的自签名证书，返回base64编码的DER
+func newProvenanceCertificate(t *testing.T, identity, issuer string) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	identityURI, err := url.Parse(identity)
+	assert.NoError(t, err)
+	issuerValue, err := asn1.MarshalWithParams(issuer, "utf8")
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		URIs:         []*url.URL{identityURI},
+		ExtraExtensions: []pkix.Extension{
+			{Id: oidFulcioIssuerV2, Value: issuerValue},
+		},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(der)
+}
+
+func TestRepository_GetProvenance(t *testing.T) {
+	certificate := newProvenanceCertificate(t,
+		"https://github.com/example/demo/.github/workflows/release.yml@refs/tags/v1.0.0",
+		"https://token.actions.githubusercontent.com")
+	statement := base64.StdEncoding.EncodeToString([]byte(`{
+		"_type": "https://in-toto.io/Statement/v1",
+		"predicateType": "https://slsa.dev/provenance/v1",
+		"predicate": {
+			"buildDefinition": {
+				"externalParameters": {"workflow": {"repository": "https://github.com/example/demo", "path": ".github/workflows/release.yml"}},
+				"resolvedDependencies": [{"uri": "git+https://github.com/example/demo@refs/tags/v1.0.0", "digest": {"gitCommit": "0123456789abcdef"}}]
+			}
+		}
+	}`))
+
+	repo := newTestRepository(t, map[string]string{
+		"/api/v1/attestations/demo-1.0.0.json": fmt.Sprintf(`[{
+			"mediaType": "application/vnd.dev.sigstore.bundle.v0.3+json",
+			"verificationMaterial": {"certificate": {"rawBytes": %q}},
+			"dsseEnvelope": {"payload": %q, "payloadType": "application/vnd.in-toto+json"}
+		}]`, certificate, statement),
+		"/api/v1/attestations/demo-1.1.0.json": `[null]`,
+	})
+
+	provenance, err := repo.GetProvenance(context.Background(), "demo", "1.0.0")
+	assert.NoError(t, err)
+	if assert.NotNil(t, provenance) {
+		assert.Equal(t, "https://github.com/example/demo/.github/workflows/release.yml@refs/tags/v1.0.0", provenance.SignerIdentity)
+		assert.Equal(t, "https://token.actions.githubusercontent.com", provenance.Issuer)
+		assert.Equal(t, "https://github.com/example/demo", provenance.SourceRepository)
+		assert.Equal(t, "0123456789abcdef", provenance.SourceCommit)
+	}
+
+	// 没有证明的版本
+	_, err = repo.GetProvenance(context.Background(), "demo", "0.9.0")
+	assert.True(t, errors.Is(err, ErrUnsupportedOperation))
+
+	// 证明列表里只有null
+	_, err = repo.GetProvenance(context.Background(), "demo", "1.1.0")
+	assert.True(t, errors.Is(err, ErrUnsupportedOperation))
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"github.com/crawler-go-go-go/go-requests"
+)
+
+// Publisher 是支持发布和撤回gem包的仓库，需要配置有发布权限的Token
+// 发布类请求会修改仓库，不会自动重试，避免超时后重复发布
+type Publisher interface {
+	// PushGem 发布一个gem包文件，返回服务端的提示信息，例如 "Successfully registered gem: rails (7.1.2)"
+	// POST - /api/v1/gems
+	PushGem(ctx context.Context, gem io.Reader) (string, error)
+
+	// YankGem 撤回gem包的一个版本
+	// DELETE - /api/v1/gems/yank
+	YankGem(ctx context.Context, gemName, version string) error
+}
+
+var _ Publisher = (*RepositoryImpl)(nil)
+
+// PushGem 发布一个gem包文件，返回服务端的提示信息
+// POST - /api/v1/gems
+func (x *RepositoryImpl) PushGem(ctx context.Context, gem io.Reader) (string, error) {
+	body, err := io.ReadAll(gem)
+	if err != nil {
+		return "", err
+	}
+	request := &apiRequest{
+		operation: OperationPushGem,
+		method:    http.MethodPost,
+		url:       fmt.Sprintf("%s/api/v1/gems", x.options.ServerURL),
+		body:      body,
+		settings: []requests.RequestSetting{func(client *http.Client, request *http.Request) error {
+			request.Header.Set("Content-Type", "application/octet-stream")
+			return nil
+		}},
+	}
+	message, err := doRequest(ctx, x, request, bodyResponseHandler)
+	if err != nil {
+		return "", err
+	}
+	return string(message), nil
+}
+
+// YankGem 撤回gem包的一个版本
+// DELETE - /api/v1/gems/yank
+func (x *RepositoryImpl) YankGem(ctx context.Context, gemName, version string) error {
+	form := url.Values{"gem_name": {gemName}, "version": {version}}
+	request := &apiRequest{
+		operation: OperationYankGem,
+		method:    http.MethodDelete,
+		url:       fmt.Sprintf("%s/api/v1/gems/yank", x.options.ServerURL),
+		body:      []byte(form.Encode()),
+		settings: []requests.RequestSetting{func(client *http.Client, request *http.Request) error {
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			return nil
+		}},
+	}
+	_, err := doRequest(ctx, x, request, bodyResponseHandler)
+	return err
+}
+
+// pushedGemPattern 匹配发布成功时的提示信息，例如 "Successfully registered gem: rails (7.1.2)"
+var pushedGemPattern = regexp.MustCompile(`registered gem:\s*(\S+)\s*\(`)
+
+// pushedGemName 从发布成功的提示信息中取出包名，无法识别时返回空字符串
+func pushedGemName(message string) string {
+	matches := pushedGemPattern.FindStringSubmatch(message)
+	if matches == nil {
+		return ""
+	}
+	return matches[1]
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_PushAndYankGem(t *testing.T) {
+	var pushed, yanked string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/gems":
+			body, _ := io.ReadAll(r.Body)
+			pushed = string(body)
+			_, _ = w.Write([]byte("Successfully registered gem: tiny (0.1.0)"))
+		case r.Method == http.MethodDelete && r.URL.Path == "/api/v1/gems/yank":
+			// ParseForm不解析DELETE请求的请求体
+			body, _ := io.ReadAll(r.Body)
+			form, _ := url.ParseQuery(string(body))
+			yanked = form.Get("gem_name") + "-" + form.Get("version")
+			_, _ = w.Write([]byte("Successfully deleted gem: tiny (0.1.0)"))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	repo := NewRepository(NewOptions().SetServerURL(server.URL).SetToken("secret"))
+
+	message, err := repo.PushGem(context.Background(), strings.NewReader("gem-bytes"))
+	assert.NoError(t, err)
+	assert.Equal(t, "gem-bytes", pushed)
+	assert.Equal(t, "tiny", pushedGemName(message))
+
+	assert.NoError(t, repo.YankGem(context.Background(), "tiny", "0.1.0"))
+	assert.Equal(t, "tiny-0.1.0", yanked)
+
+	err = NewRepository(NewOptions().SetServerURL(server.URL).SetToken("wrong")).YankGem(context.Background(), "tiny", "0.1.0")
+	assert.Error(t, err)
+	assert.True(t, IsUnauthorized(err))
+	assert.Equal(t, "", pushedGemName("Repushing of gem versions is not allowed."))
+}
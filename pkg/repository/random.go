@@ -0,0 +1,42 @@
+package repository
+
+import (
+	cryptorand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// defaultRand 没有关联仓库时（例如直接调用SendRequestWithRetry）使用的随机数来源
+var defaultRand = newLockedRand(nil)
+
+// lockedRand 给rand.Rand加上互斥锁，rand.Rand本身不能被多个goroutine同时使用
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+// newLockedRand 包装r，r为nil时使用安全随机数作为种子创建新的来源
+func newLockedRand(r *rand.Rand) *lockedRand {
+	if r == nil {
+		r = rand.New(rand.NewSource(secureSeed()))
+	}
+	return &lockedRand{r: r}
+}
+
+// Int63n 返回[0, n)之间的随机数，n必须大于0
+func (l *lockedRand) Int63n(n int64) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Int63n(n)
+}
+
+// secureSeed 从crypto/rand读取种子，读取失败时退回到当前时间
+func secureSeed() int64 {
+	var buf [8]byte
+	if _, err := cryptorand.Read(buf[:]); err != nil {
+		return time.Now().UnixNano()
+	}
+	return int64(binary.LittleEndian.Uint64(buf[:]))
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// GetGemRank 估算gem在仓库中按总下载量计算的排名和百分位
+// RubyGems没有提供排名接口，这里用latest和just_updated两个动态中的gem作为样本：
+// rank是样本中下载量高于目标gem的数量加一，percentile是样本中下载量不高于目标gem的比例（0-100）。
+// 这只是近似值：样本只有最近发布过版本的几十个gem，偏向活跃的gem，
+// 适合比较一组依赖之间的相对重要性，不能当作整个仓库中的真实排名
+func (x *RepositoryImpl) GetGemRank(ctx context.Context, gemName string) (rank int, percentile float64, err error) {
+	pkg, err := x.GetPackage(ctx, gemName)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	latest, err := x.LatestActivity(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	updated, err := x.JustUpdatedActivity(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	rank = 1
+	sampled := 0
+	notAbove := 0
+	for _, gem := range recentlyActive(append(latest, updated...), time.Now(), 0, 0) {
+		if gem.Name == pkg.Name {
+			continue
+		}
+		sampled++
+		if gem.Downloads > pkg.Downloads {
+			rank++
+		} else {
+			notAbove++
+		}
+	}
+	if sampled == 0 {
+		return 1, 100, nil
+	}
+	return rank, float64(notAbove) * 100 / float64(sampled), nil
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetGemRank(t *testing.T) {
+	repo := newTestRepository(t, map[string]string{
+		"/api/v1/gems/rack.json": `{"name": "rack", "version": "3.0.8", "downloads": 700000000}`,
+		"/api/v1/gems/tiny.json": `{"name": "tiny", "version": "0.0.1", "downloads": 10}`,
+		"/api/v1/activity/latest.json": `[
+			{"name": "bundler", "version": "2.4.22", "downloads": 900000000},
+			{"name": "rack", "version": "3.0.8", "downloads": 700000000},
+			{"name": "sidekiq", "version": "7.2.0", "downloads": 250000000}
+		]`,
+		"/api/v1/activity/just_updated.json": `[
+			{"name": "sidekiq", "version": "7.1.6", "downloads": 250000000},
+			{"name": "new-gem", "version": "0.1.0", "downloads": 100},
+			{"name": "small-gem", "version": "0.2.0", "downloads": 5000}
+		]`,
+	})
+
+	// 样本（不含rack自身）：bundler、sidekiq、new-gem、small-gem，只有bundler比rack多
+	rank, percentile, err := repo.GetGemRank(context.Background(), "rack")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, rank)
+	assert.InDelta(t, 75.0, percentile, 0.001)
+
+	// tiny的下载量低于样本中的全部5个gem
+	rank, percentile, err = repo.GetGemRank(context.Background(), "tiny")
+	assert.NoError(t, err)
+	assert.Equal(t, 6, rank)
+	assert.InDelta(t, 0.0, percentile, 0.001)
+
+	_, _, err = repo.GetGemRank(context.Background(), "missing")
+	assert.Error(t, err)
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+)
+
+// ChangelogEntry 是根据版本信息生成的一条发布记录
+type ChangelogEntry struct {
+	// 版本号
+	Version string `json:"version"`
+
+	// 发布时间
+	ReleasedAt time.Time `json:"released_at"`
+
+	// 这个版本的简介和描述
+	Summary     string `json:"summary"`
+	Description string `json:"description"`
+
+	// 与上一个版本相比新增和移除的运行时依赖，只比较依赖的名称，按字典序排序
+	AddedDeps   []string `json:"added_deps,omitempty"`
+	RemovedDeps []string `json:"removed_deps,omitempty"`
+}
+
+// BuildChangelog 为gem的所有版本生成发布记录，用于生成发布说明
+// 每个版本的依赖通过GetPackageAtVersion并发获取，options控制并发数。
+// 返回的记录按发布时间升序排列，依赖的变化来自与前一条记录的比较，第一条记录的依赖都算作新增。
+// 同一个版本号的多个平台只保留一条记录，任何一个版本获取失败时返回错误
+func (x *RepositoryImpl) BuildChangelog(ctx context.Context, gemName string, options *BulkOptions) ([]*ChangelogEntry, error) {
+	versions, err := x.GetGemVersions(ctx, gemName)
+	if err != nil {
+		return nil, err
+	}
+
+	byNumber := make(map[string]*models.Version)
+	var numbers []string
+	for _, version := range versions {
+		if version == nil {
+			continue
+		}
+		existing, ok := byNumber[version.Number]
+		if !ok {
+			numbers = append(numbers, version.Number)
+		}
+		// 优先使用ruby平台的版本信息
+		if !ok || (existing.Platform != "" && existing.Platform != "ruby" && (version.Platform == "" || version.Platform == "ruby")) {
+			byNumber[version.Number] = version
+		}
+	}
+
+	results := bulkExecute(ctx, numbers, options, func(ctx context.Context, number string) (*models.PackageInformation, error) {
+		return x.GetPackageAtVersion(ctx, gemName, number)
+	})
+
+	entries := make([]*ChangelogEntry, 0, len(numbers))
+	dependencies := make(map[string][]string, len(numbers))
+	for i, result := range results {
+		if result == nil {
+			return nil, fmt.Errorf("get %s %s: %w", gemName, numbers[i], context.Canceled)
+		}
+		if result.Error != nil {
+			return nil, fmt.Errorf("get %s %s: %w", gemName, numbers[i], result.Error)
+		}
+		version := byNumber[numbers[i]]
+		entries = append(entries, &ChangelogEntry{
+			Version:     version.Number,
+			ReleasedAt:  version.CreatedAt,
+			Summary:     version.Summary,
+			Description: version.Description,
+		})
+		if result.Value != nil {
+			for _, dependency := range result.Value.Dependencies.Runtime {
+				dependencies[version.Number] = append(dependencies[version.Number], dependency.Name)
+			}
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].ReleasedAt.Equal(entries[j].ReleasedAt) {
+			return entries[i].ReleasedAt.Before(entries[j].ReleasedAt)
+		}
+		return models.CompareVersions(entries[i].Version, entries[j].Version) < 0
+	})
+
+	var previous []string
+	for _, entry := range entries {
+		current := dependencies[entry.Version]
+		entry.AddedDeps = stringsNotIn(current, previous)
+		entry.RemovedDeps = stringsNotIn(previous, current)
+		previous = current
+	}
+	return entries, nil
+}
+
+// stringsNotIn 返回values中不在others里的元素，去重后按字典序排序
+func stringsNotIn(values, others []string) []string {
+	seen := make(map[string]bool, len(others))
+	for _, value := range others {
+		seen[value] = true
+	}
+	var missing []string
+	for _, value := range values {
+		if !seen[value] {
+			missing = append(missing, value)
+			seen[value] = true
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_BuildChangelog(t *testing.T) {
+	repo := newTestRepository(t, map[string]string{
+		// 版本列表按版本号降序，1.1.1是维护分支上比2.0.0晚发布的版本
+		"/api/v1/versions/widget.json": `[
+			{"number": "2.0.0", "platform": "ruby", "created_at": "2023-03-01T00:00:00.000Z", "summary": "Widgets v2"},
+			{"number": "1.1.1", "platform": "ruby", "created_at": "2023-04-01T00:00:00.000Z", "summary": "Security fix"},
+			{"number": "1.1.0", "platform": "java", "created_at": "2022-06-01T00:00:00.000Z", "summary": "Widgets for JRuby"},
+			{"number": "1.1.0", "platform": "ruby", "created_at": "2022-06-01T00:00:00.000Z", "summary": "Widgets", "description": "Reusable widgets"},
+			{"number": "1.0.0", "platform": "ruby", "created_at": "2022-01-01T00:00:00.000Z", "summary": "Widgets"}
+		]`,
+		"/api/v2/rubygems/widget/versions/1.0.0.json": `{"name": "widget", "version": "1.0.0", "dependencies": {"runtime": [
+			{"name": "rack", "requirements": ">= 2.0"}
+		]}}`,
+		"/api/v2/rubygems/widget/versions/1.1.0.json": `{"name": "widget", "version": "1.1.0", "dependencies": {"runtime": [
+			{"name": "rack", "requirements": ">= 2.0"},
+			{"name": "json", "requirements": "~> 2.6"}
+		]}}`,
+		"/api/v2/rubygems/widget/versions/2.0.0.json": `{"name": "widget", "version": "2.0.0", "dependencies": {"runtime": [
+			{"name": "json", "requirements": "~> 2.6"},
+			{"name": "zeitwerk", "requirements": "~> 2.6"}
+		]}}`,
+		"/api/v2/rubygems/widget/versions/1.1.1.json": `{"name": "widget", "version": "1.1.1", "dependencies": {"runtime": [
+			{"name": "rack", "requirements": ">= 2.2.8"},
+			{"name": "json", "requirements": "~> 2.6"}
+		]}}`,
+	})
+
+	entries, err := repo.BuildChangelog(context.Background(), "widget", NewBulkOptions().WithMaxConcurrency(2))
+	assert.NoError(t, err)
+	if !assert.Len(t, entries, 4) {
+		return
+	}
+
+	assert.Equal(t, "1.0.0", entries[0].Version)
+	assert.Equal(t, []string{"rack"}, entries[0].AddedDeps)
+	assert.Empty(t, entries[0].RemovedDeps)
+
+	// 多个平台只保留ruby平台的记录
+	assert.Equal(t, "1.1.0", entries[1].Version)
+	assert.Equal(t, "Reusable widgets", entries[1].Description)
+	assert.Equal(t, time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC), entries[1].ReleasedAt)
+	assert.Equal(t, []string{"json"}, entries[1].AddedDeps)
+
+	assert.Equal(t, "2.0.0", entries[2].Version)
+	assert.Equal(t, []string{"zeitwerk"}, entries[2].AddedDeps)
+	assert.Equal(t, []string{"rack"}, entries[2].RemovedDeps)
+
+	// 按发布时间而不是版本号排序
+	assert.Equal(t, "1.1.1", entries[3].Version)
+	assert.Equal(t, []string{"rack"}, entries[3].AddedDeps)
+	assert.Equal(t, []string{"zeitwerk"}, entries[3].RemovedDeps)
+
+	_, err = repo.BuildChangelog(context.Background(), "missing", nil)
+	assert.Error(t, err)
+}
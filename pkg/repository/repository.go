@@ -3,10 +3,14 @@
 package repository
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -24,21 +28,40 @@ type Repository interface {
 	// 如果包不存在，将返回NotFound错误
 	GetPackage(ctx context.Context, gemName string) (*models.PackageInformation, error)
 
+	// GemExists 判断指定的包是否存在
+	// 只发送HEAD请求，不下载包信息，比GetPackage更轻量；包不存在时返回(false, nil)
+	GemExists(ctx context.Context, gemName string) (bool, error)
+
 	// Search 根据查询字符串搜索包
 	// query参数可以是包名的一部分
 	// 返回的结果按照相关性和流行度排序
 	// 如果找不到匹配的包，将返回空切片而不是错误
 	Search(ctx context.Context, query string, page int) ([]*models.PackageInformation, error)
 
+	// SearchByLicense 搜索声明了给定许可证的包
+	// API不支持按许可证搜索，这里用许可证名称作为查询，在客户端过滤出许可证规范化后匹配的包，
+	// 所以结果只是近似的：没有在名称、简介或描述中提到许可证的包不会被找到。
+	// 一页过滤后可能为空，但后面的页仍然可能有结果
+	SearchByLicense(ctx context.Context, license string, page int) ([]*models.PackageInformation, error)
+
 	// GetGemVersions 获取指定包的所有版本信息
 	// 返回的版本按照发布时间降序排列（最新的版本在前）
-	// 如果包不存在，将返回空切片而不是错误
+	// 如果包不存在，返回包装了ErrNotFound的*APIError，可以用IsNotFound判断
 	GetGemVersions(ctx context.Context, gemName string) ([]*models.Version, error)
 
 	// GetGemLatestVersion 获取给定包的最新版本
 	// GET - /api/v1/versions/[GEM NAME]/latest.json
 	GetGemLatestVersion(ctx context.Context, gemName string) (*models.LatestVersion, error)
 
+	// GetGemVersion 获取给定包的某一个版本的详细信息，包括sha、构建时间和metadata
+	// GET - /api/v1/versions/[GEM NAME]/[VERSION].json
+	// 包或者版本不存在时返回ErrNotFound
+	GetGemVersion(ctx context.Context, gemName, version string) (*models.Version, error)
+
+	// FindVersionMatching 返回满足版本约束的最新版本，约束支持 =、!=、>、<、>=、<=、~> 以及逗号分隔的组合
+	// 只有约束显式引用预发布版本时才会考虑预发布版本，没有满足约束的版本时返回ErrNotFound
+	FindVersionMatching(ctx context.Context, gemName, constraint string) (*models.Version, error)
+
 	// GetTimeFrameVersions 获取特定时间段内的版本信息
 	// GET - /api/v1/timeframe_versions.json
 	// 时间格式样例: 2019-01-18T21:24:29Z
@@ -55,12 +78,17 @@ type Repository interface {
 
 	// GetDependencies 获取指定gem包的依赖
 	// GET - /api/v1/dependencies?gems=[COMMA DELIMITED GEM NAMES]
+	// 这个接口只返回运行时依赖，需要开发依赖时使用GetPackage中的Dependencies.Development
 	GetDependencies(ctx context.Context, gemsNames ...string) ([]*models.DependencyInfo, error)
 
 	// LatestGems 获取仓库上最新发布的gem包
 	// GET - /api/v1/activity/latest.json
 	LatestGems(ctx context.Context) ([]*models.PackageInformation, error)
 
+	// JustUpdatedGems 获取仓库上最近更新的gem包，包括已有gem包发布的新版本
+	// GET - /api/v1/activity/just_updated.json
+	JustUpdatedGems(ctx context.Context) ([]*models.PackageInformation, error)
+
 	// GetReverseDependencies 获取依赖于指定gem包的所有包
 	// GET - /api/v1/gems/[GEM NAME]/reverse_dependencies.json
 	GetReverseDependencies(ctx context.Context, gemName string) ([]string, error)
@@ -74,33 +102,162 @@ type Repository interface {
 	BulkGetVersions(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[[]*models.Version]
 
 	// BulkGetDependencies 批量获取多个包的依赖信息
-	// 并发执行GetDependencies请求，提高大规模数据获取效率
+	// 多个包合并为一个GetDependencies请求，合并的数量由BulkOptions.DependencyBatchSize控制
 	BulkGetDependencies(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[[]*models.DependencyInfo]
 
 	// BulkGetReverseDependencies 批量获取多个包的反向依赖信息
 	// 并发执行GetReverseDependencies请求，提高大规模数据获取效率
 	BulkGetReverseDependencies(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[[]string]
+
+	// BulkGemExists 批量判断多个包是否存在
+	// 并发执行GemExists请求，包不存在时对应结果的Value为false
+	BulkGemExists(ctx context.Context, gemNames []string, options *BulkOptions) []*BulkResult[bool]
+
+	// BulkSearch 批量执行多个搜索查询
+	// 并发执行Search请求，结果以查询字符串为键
+	BulkSearch(ctx context.Context, queries []string, page int, options *BulkOptions) []*BulkResult[[]*models.PackageInformation]
 }
 
+// RepositoryImpl 是Repository的默认实现，直接请求RubyGems API
+// 同一个RepositoryImpl可以被任意多个goroutine同时使用，批量方法也依赖这一点：
+// 创建之后options和optionsErr只读，请求过程中会变化的状态（例如重试统计）都通过atomic或互斥锁保护，
+// 新增可变状态时也需要遵守这个约定。创建之后不要再修改传入的Options
 type RepositoryImpl struct {
 	options *Options
+
+	// optionsErr 创建仓库时校验选项发现的错误，之后的每个请求都会直接返回这个错误
+	optionsErr error
+
+	// retries 记录这个仓库发出的请求累计的重试统计
+	retries retryRecorder
+
+	// rand 这个仓库使用的随机数来源，由Options.Rand创建，可以被多个goroutine同时使用
+	rand *lockedRand
+
+	// index 最近一次通过RefreshIndex获取的compact index
+	index compactIndexCache
 }
 
+var _ Repository = (*RepositoryImpl)(nil)
+
 // NewRepository 创建一个仓库，gem都是存放在仓库中的
+// 直接构造的Options{}没有设置ServerURL时会使用DefaultServerURL，
+// ServerURL不是合法的绝对地址时，仓库上的每个请求都会返回Options.Validate的错误
 func NewRepository(options ...*Options) *RepositoryImpl {
-	if len(options) == 0 {
-		options = append(options, NewOptions())
+	if len(options) == 0 || options[0] == nil {
+		options = []*Options{NewOptions()}
+	}
+	repositoryOptions := options[0]
+	if strings.TrimSpace(repositoryOptions.ServerURL) == "" {
+		// 在副本上设置默认地址，不修改调用方的Options，同一个Options可能被多个仓库共用
+		repositoryOptions = repositoryOptions.clone()
+		repositoryOptions.ServerURL = DefaultServerURL
 	}
 	return &RepositoryImpl{
-		options: options[0],
+		options:    repositoryOptions,
+		optionsErr: repositoryOptions.Validate(),
+		rand:       newLockedRand(options[0].Rand),
 	}
 }
 
+// RetryStats 返回这个仓库创建以来累计的重试统计，可以在并发请求的同时调用
+func (x *RepositoryImpl) RetryStats() RetryStats {
+	return x.retries.snapshot()
+}
+
 // GetPackage 获取gem包的基础信息
 // GetPackage GET - /api/v1/gems/[GEM NAME].(json|yaml)
 func (x *RepositoryImpl) GetPackage(ctx context.Context, gemName string) (*models.PackageInformation, error) {
-	targetUrl := fmt.Sprintf("%s/api/v1/gems/%s.json", x.options.ServerURL, gemName)
-	return getJson[*models.PackageInformation](ctx, x, targetUrl)
+	targetUrl := fmt.Sprintf("%s/api/v1/gems/%s.json", x.options.ServerURL, url.PathEscape(gemName))
+	pkg, err := getPackageJson(ctx, x, OperationGetPackage, targetUrl)
+	if err != nil {
+		return nil, err
+	}
+	return pkg, x.verifyGemName(gemName, pkg)
+}
+
+// GetPackageWithRaw 与GetPackage相同，同时返回服务端响应的原始内容，便于审计或者向上游报告解析结果与响应不一致的问题
+// 响应无法解析时仍然返回原始内容和解析错误
+func (x *RepositoryImpl) GetPackageWithRaw(ctx context.Context, gemName string) (*models.PackageInformation, []byte, error) {
+	targetUrl := fmt.Sprintf("%s/api/v1/gems/%s.json", x.options.ServerURL, url.PathEscape(gemName))
+	raw, err := x.getBytes(ctx, OperationGetPackage, targetUrl)
+	if err != nil {
+		return nil, nil, err
+	}
+	pkg, err := unmarshalPackageJson(raw)
+	if err != nil {
+		return nil, raw, err
+	}
+	return pkg, raw, x.verifyGemName(gemName, pkg)
+}
+
+// GetPackageAtVersion 获取gem包在指定版本时的基础信息，包括这个版本声明的依赖
+// GET - /api/v2/rubygems/[GEM NAME]/versions/[VERSION NUMBER].(json|yaml)
+func (x *RepositoryImpl) GetPackageAtVersion(ctx context.Context, gemName, version string) (*models.PackageInformation, error) {
+	targetUrl := fmt.Sprintf("%s/api/v2/rubygems/%s/versions/%s.json", x.options.ServerURL, url.PathEscape(gemName), url.PathEscape(version))
+	pkg, err := getPackageJson(ctx, x, OperationGetPackageAtVersion, targetUrl)
+	if err != nil {
+		return nil, err
+	}
+	return pkg, x.verifyGemName(gemName, pkg)
+}
+
+// verifyGemName 开启了Options.VerifyResponseGemName时，检查返回的包名与请求的包名是否一致
+// 不一致时同时返回包信息和ErrResponseMismatch，调用方可以自行决定是否使用
+func (x *RepositoryImpl) verifyGemName(gemName string, pkg *models.PackageInformation) error {
+	if !x.options.VerifyResponseGemName || pkg == nil || strings.EqualFold(pkg.Name, gemName) {
+		return nil
+	}
+	return fmt.Errorf("%w: requested gem %q, got %q", ErrResponseMismatch, gemName, pkg.Name)
+}
+
+// GemExists 判断gem包是否存在，只发送HEAD请求而不下载包信息
+// 部分镜像和缓存代理不支持HEAD请求（返回405或501），这时改用GET请求，只根据状态码判断而不解析响应内容
+// HEAD - /api/v1/gems/[GEM NAME].json
+func (x *RepositoryImpl) GemExists(ctx context.Context, gemName string) (bool, error) {
+	request := &apiRequest{
+		operation: OperationGemExists,
+		method:    http.MethodHead,
+		url:       fmt.Sprintf("%s/api/v1/gems/%s.json", x.options.ServerURL, url.PathEscape(gemName)),
+	}
+	result, err := doRequest(ctx, x, request, existsResponseHandler)
+	if err != nil {
+		return false, err
+	}
+	if result == gemExistenceUnknown {
+		request.method = http.MethodGet
+		if result, err = doRequest(ctx, x, request, existsResponseHandler); err != nil {
+			return false, err
+		}
+	}
+	return result == gemExists, nil
+}
+
+// gemExistence 是存在性检查的结果
+type gemExistence int
+
+const (
+	gemMissing gemExistence = iota
+	gemExists
+
+	// 服务器不支持HEAD请求，需要用GET请求再判断一次
+	gemExistenceUnknown
+)
+
+// existsResponseHandler 把存在性检查的状态码转换为是否存在，404表示不存在
+// HEAD请求返回405或501时表示服务器不支持HEAD，其它非200状态码视为错误
+func existsResponseHandler(resp *http.Response) (gemExistence, error) {
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return gemExists, resp.Body.Close()
+	case resp.StatusCode == http.StatusNotFound:
+		return gemMissing, resp.Body.Close()
+	case resp.Request.Method == http.MethodHead &&
+		(resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented):
+		return gemExistenceUnknown, resp.Body.Close()
+	default:
+		return gemMissing, responseStatusError(resp)
+	}
 }
 
 // Search 在整个仓库中搜索符合条件的包，使用page参数翻页，如果响应列表为空则说明翻到了尾页
@@ -109,33 +266,73 @@ func (x *RepositoryImpl) Search(ctx context.Context, query string, page int) ([]
 	if page <= 0 {
 		page = 1
 	}
-	targetUrl := fmt.Sprintf("%s/api/v1/search.json?query=%s&page=%d", x.options.ServerURL, query, page)
-	return getJson[[]*models.PackageInformation](ctx, x, targetUrl)
+	targetUrl := fmt.Sprintf("%s/api/v1/search.json?query=%s&page=%d", x.options.ServerURL, url.QueryEscape(query), page)
+	return getJson[[]*models.PackageInformation](ctx, x, OperationSearch, targetUrl)
+}
+
+// searchAllConcurrency SearchAll同时请求的最大页数，避免对API造成太大压力
+const searchAllConcurrency = 3
+
+// SearchAll 从第一页开始翻页，返回搜索的全部结果，结果按包名去重并保持搜索返回的顺序
+// maxResults大于0时最多返回maxResults个结果，拿到足够的结果后不再请求后面的页；小于等于0时获取全部结果。
+// 第一页之后每次最多同时请求searchAllConcurrency页，某一页为空时翻页结束。
+// 某一页请求失败或者ctx被取消时返回已经获取的结果和对应的错误
+func (x *RepositoryImpl) SearchAll(ctx context.Context, query string, maxResults int) ([]*models.PackageInformation, error) {
+	seen := make(map[string]bool)
+	return paginate(ctx, searchAllConcurrency, maxResults, func(page int) ([]*models.PackageInformation, error) {
+		return x.Search(ctx, query, page)
+	}, func(pkg *models.PackageInformation) bool {
+		if pkg == nil || seen[pkg.Name] {
+			return false
+		}
+		seen[pkg.Name] = true
+		return true
+	})
 }
 
 // GetGemVersions 获取指定的gem包的所有版本都有哪些
 // GET - /api/v1/versions/[GEM NAME].(json|yaml)
 func (x *RepositoryImpl) GetGemVersions(ctx context.Context, gemName string) ([]*models.Version, error) {
-	targetUrl := fmt.Sprintf("%s/api/v1/versions/%s.json", x.options.ServerURL, gemName)
-	return getJson[[]*models.Version](ctx, x, targetUrl)
+	bytes, err := x.getGemVersionsBytes(ctx, gemName)
+	if err != nil {
+		return nil, err
+	}
+	return models.UnmarshalVersions(bytes)
+}
+
+// getGemVersionsBytes 获取版本列表的原始响应，需要自己解析版本列表时使用
+func (x *RepositoryImpl) getGemVersionsBytes(ctx context.Context, gemName string) ([]byte, error) {
+	targetUrl := fmt.Sprintf("%s/api/v1/versions/%s.json", x.options.ServerURL, url.PathEscape(gemName))
+	return x.getBytes(ctx, OperationGetGemVersions, targetUrl)
 }
 
 // GetGemLatestVersion 获取给定包的最新版本
 // GET - /api/v1/versions/[GEM NAME]/latest.json
 func (x *RepositoryImpl) GetGemLatestVersion(ctx context.Context, gemName string) (*models.LatestVersion, error) {
-	targetUrl := fmt.Sprintf("%s/api/v1/versions/%s/latest.json", x.options.ServerURL, gemName)
-	return getJson[*models.LatestVersion](ctx, x, targetUrl)
+	targetUrl := fmt.Sprintf("%s/api/v1/versions/%s/latest.json", x.options.ServerURL, url.PathEscape(gemName))
+	return getJson[*models.LatestVersion](ctx, x, OperationGetGemLatestVersion, targetUrl)
+}
+
+// GetGemVersion 获取给定包的某一个版本的详细信息
+// GET - /api/v1/versions/[GEM NAME]/[VERSION].json
+// 不存在的版本返回404，调用方可以用IsNotFound判断版本是否存在
+func (x *RepositoryImpl) GetGemVersion(ctx context.Context, gemName, version string) (*models.Version, error) {
+	targetUrl := fmt.Sprintf("%s/api/v1/versions/%s/%s.json", x.options.ServerURL, url.PathEscape(gemName), url.PathEscape(version))
+	return getJson[*models.Version](ctx, x, OperationGetGemVersion, targetUrl)
 }
 
 // GetTimeFrameVersions 获取特定时间段内的版本信息
 // GET - /api/v1/timeframe_versions.json
 // 时间格式样例: 2019-01-18T21:24:29Z
+// 接口限制了单次查询的时间跨度，超过MaxTimeFrameSpan的时间段会被拆分为多次请求，结果按请求顺序合并。
+// 服务端的限制更严格时（例如某些镜像）按服务端返回的最大跨度重新拆分，仍然失败时返回TimeframeTooLargeError
 func (x *RepositoryImpl) GetTimeFrameVersions(ctx context.Context, from, to time.Time) ([]*models.Version, error) {
-	// 格式化时间为RFC3339格式
-	fromStr := from.Format(time.RFC3339)
-	toStr := to.Format(time.RFC3339)
-	targetUrl := fmt.Sprintf("%s/api/v1/timeframe_versions.json?from=%s&to=%s", x.options.ServerURL, fromStr, toStr)
-	return getJson[[]*models.Version](ctx, x, targetUrl)
+	versions, err := x.getTimeFrameVersionsSplit(ctx, from, to, MaxTimeFrameSpan)
+	var tooLarge *TimeframeTooLargeError
+	if errors.As(err, &tooLarge) && tooLarge.MaxSpan > 0 && tooLarge.MaxSpan < MaxTimeFrameSpan {
+		return x.getTimeFrameVersionsSplit(ctx, from, to, tooLarge.MaxSpan)
+	}
+	return versions, err
 }
 
 // Downloads 获取这个仓库中的包总共被下载了多少次
@@ -143,39 +340,151 @@ func (x *RepositoryImpl) GetTimeFrameVersions(ctx context.Context, from, to time
 // Returns an object containing the total number of downloads on RubyGems.
 func (x *RepositoryImpl) Downloads(ctx context.Context) (*models.RepositoryDownloadCount, error) {
 	targetUrl := fmt.Sprintf("%s/api/v1/downloads.json", x.options.ServerURL)
-	return getJson[*models.RepositoryDownloadCount](ctx, x, targetUrl)
+	return getJson[*models.RepositoryDownloadCount](ctx, x, OperationDownloads, targetUrl)
 }
 
 // VersionDownloads 获取给定的包的给定版本总共被下载了多少次
 // GET - /api/v1/downloads/[GEM NAME]-[GEM VERSION].(json|yaml)
+// 注意：包名、版本号和平台在URL中都用"-"连接，而"-"在版本号中又表示预发布（1.0.0-rc1），
+// 所以把平台直接拼进版本号（例如 "1.15.4-x86_64-linux"）时，服务端无法可靠地区分版本和平台，
+// 包名本身带"-"时也一样。原生平台的版本请使用VersionDownloadsForPlatform
 func (x *RepositoryImpl) VersionDownloads(ctx context.Context, gemName, gemVersion string) (*models.VersionDownloadCount, error) {
-	targetUrl := fmt.Sprintf("%s/api/v1/downloads/%s-%s.json", x.options.ServerURL, gemName, gemVersion)
-	return getJson[*models.VersionDownloadCount](ctx, x, targetUrl)
+	targetUrl := fmt.Sprintf("%s/api/v1/downloads/%s-%s.json", x.options.ServerURL, url.PathEscape(gemName), url.PathEscape(gemVersion))
+	return getJson[*models.VersionDownloadCount](ctx, x, OperationVersionDownloads, targetUrl)
+}
+
+// VersionDownloadsForPlatform 获取给定包在给定版本和平台下的下载量
+// 平台为空或者为"ruby"时等价于纯Ruby版本；其它平台按RubyGems的全名规则拼成 名称-版本-平台，
+// 每一部分单独做URL转义，例如 ("nokogiri", "1.15.4", "x86_64-linux") => /api/v1/downloads/nokogiri-1.15.4-x86_64-linux.json
+// GET - /api/v1/downloads/[GEM NAME]-[GEM VERSION]-[PLATFORM].(json|yaml)
+func (x *RepositoryImpl) VersionDownloadsForPlatform(ctx context.Context, gemName, gemVersion, platform string) (*models.VersionDownloadCount, error) {
+	targetUrl := fmt.Sprintf("%s/api/v1/downloads/%s.json", x.options.ServerURL, gemFullName(gemName, gemVersion, platform))
+	return getJson[*models.VersionDownloadCount](ctx, x, OperationVersionDownloads, targetUrl)
+}
+
+// gemFullName 返回RubyGems中gem文件的全名并做URL路径转义，与Gem::Specification#full_name一致
+func gemFullName(gemName, gemVersion, platform string) string {
+	parts := []string{url.PathEscape(gemName), url.PathEscape(gemVersion)}
+	if platform = strings.TrimSpace(platform); platform != "" && platform != "ruby" {
+		parts = append(parts, url.PathEscape(platform))
+	}
+	return strings.Join(parts, "-")
 }
 
 // GetDependencies 获取指定gem包的依赖
 // GET - /api/v1/dependencies?gems=[COMMA DELIMITED GEM NAMES]
+// 这个接口只返回运行时依赖，同时需要开发依赖时使用GetAllDependencies
+// Options.DependencyFormat为DependencyFormatMarshal时按bundler的方式请求并解析Marshal格式的响应
 func (x *RepositoryImpl) GetDependencies(ctx context.Context, gemsNames ...string) ([]*models.DependencyInfo, error) {
-	targetUrl := fmt.Sprintf("%s/api/v1/dependencies?gems=%s", x.options.ServerURL, strings.Join(gemsNames, ","))
-	return getJson[[]*models.DependencyInfo](ctx, x, targetUrl)
+	// 每个包名单独转义，分隔包名的逗号保持原样
+	escaped := make([]string, len(gemsNames))
+	for i, gemName := range gemsNames {
+		escaped[i] = url.QueryEscape(gemName)
+	}
+	targetUrl := fmt.Sprintf("%s/api/v1/dependencies?gems=%s", x.options.ServerURL, strings.Join(escaped, ","))
+	if x.options.DependencyFormat == DependencyFormatMarshal {
+		bytes, err := x.getBytes(ctx, OperationGetDependencies, targetUrl, func(client *http.Client, request *http.Request) error {
+			request.Header.Set("Accept", "application/octet-stream")
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return unmarshalMarshalDependencies(bytes)
+	}
+	return getJson[[]*models.DependencyInfo](ctx, x, OperationGetDependencies, targetUrl)
+}
+
+// GetAllDependencies 获取gem包最新版本的运行时依赖和开发依赖
+// 与只返回运行时依赖的GetDependencies不同，依赖信息来自GetPackage返回的包信息
+// GET - /api/v1/gems/[GEM NAME].json
+func (x *RepositoryImpl) GetAllDependencies(ctx context.Context, gemName string) (runtime, development []*models.Dependency, err error) {
+	pkg, err := x.GetPackage(ctx, gemName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pkg.Dependencies.Runtime, pkg.Dependencies.Development, nil
 }
 
 // LatestGems 获取仓库上最新发布的gem包
 // GET - /api/v1/activity/latest.json
 func (x *RepositoryImpl) LatestGems(ctx context.Context) ([]*models.PackageInformation, error) {
+	items, err := x.LatestActivity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return activityPackages(items), nil
+}
+
+// LatestActivity 获取仓库上最新发布的gem包动态，保留动态项本身的字段
+// GET - /api/v1/activity/latest.json
+func (x *RepositoryImpl) LatestActivity(ctx context.Context) ([]*models.ActivityItem, error) {
 	targetUrl := fmt.Sprintf("%s/api/v1/activity/latest.json", x.options.ServerURL)
-	return getJson[[]*models.PackageInformation](ctx, x, targetUrl)
+	return getJson[[]*models.ActivityItem](ctx, x, OperationLatestGems, targetUrl)
 }
 
 // GetReverseDependencies 获取依赖于指定gem包的所有包
 // GET - /api/v1/gems/[GEM NAME]/reverse_dependencies.json
 func (x *RepositoryImpl) GetReverseDependencies(ctx context.Context, gemName string) ([]string, error) {
-	targetUrl := fmt.Sprintf("%s/api/v1/gems/%s/reverse_dependencies.json", x.options.ServerURL, gemName)
-	return getJson[[]string](ctx, x, targetUrl)
+	targetUrl := fmt.Sprintf("%s/api/v1/gems/%s/reverse_dependencies.json", x.options.ServerURL, url.PathEscape(gemName))
+	return getJson[[]string](ctx, x, OperationGetReverseDependencies, targetUrl)
 }
 
-func getJson[T any](ctx context.Context, repository *RepositoryImpl, targetUrl string) (T, error) {
-	bytes, err := repository.getBytes(ctx, targetUrl)
+// GetReverseDependenciesForVersion 获取依赖于指定gem包某个具体版本的包
+// RubyGems没有按版本查询反向依赖的接口，这里先获取gem级别的反向依赖，
+// 再逐个读取依赖方最新版本声明的版本要求（运行时依赖和开发依赖），只保留要求能被version满足的包。
+// 因此结果反映的是各个依赖方当前的最新版本，依赖方较多时会发出较多请求
+// GET - /api/v1/gems/[GEM NAME]/reverse_dependencies.json
+// GET - /api/v1/gems/[DEPENDENT NAME].json
+func (x *RepositoryImpl) GetReverseDependenciesForVersion(ctx context.Context, gemName, version string) ([]string, error) {
+	dependents, err := x.GetReverseDependencies(ctx, gemName)
+	if err != nil {
+		return nil, err
+	}
+
+	results := bulkExecute(ctx, dependents, nil, x.GetPackage)
+	matched := make([]string, 0)
+	for _, result := range results {
+		if result == nil {
+			return nil, ctx.Err()
+		}
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		ok, err := requiresVersion(result.Value, gemName, version)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matched = append(matched, result.Key)
+		}
+	}
+	return matched, nil
+}
+
+// requiresVersion 判断包声明的对gemName的依赖是否接受version
+func requiresVersion(pkg *models.PackageInformation, gemName, version string) (bool, error) {
+	if pkg == nil {
+		return false, nil
+	}
+	dependencies := append(append([]*models.Dependency{}, pkg.Dependencies.Runtime...), pkg.Dependencies.Development...)
+	for _, dependency := range dependencies {
+		if dependency == nil || dependency.Name != gemName {
+			continue
+		}
+		requirement, err := models.ParseRequirement(dependency.Requirements)
+		if err != nil {
+			return false, fmt.Errorf("%s depends on %s: %w", pkg.Name, gemName, err)
+		}
+		if requirement.Satisfies(version) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func getJson[T any](ctx context.Context, repository *RepositoryImpl, operation, targetUrl string) (T, error) {
+	bytes, err := repository.getBytes(ctx, operation, targetUrl)
 	if err != nil {
 		var zero T
 		return zero, err
@@ -183,6 +492,27 @@ func getJson[T any](ctx context.Context, repository *RepositoryImpl, targetUrl s
 	return unmarshalJson[T](bytes)
 }
 
+// getPackageJson 获取包信息并统一顶层与metadata中的链接字段
+func getPackageJson(ctx context.Context, repository *RepositoryImpl, operation, targetUrl string) (*models.PackageInformation, error) {
+	bytes, err := repository.getBytes(ctx, operation, targetUrl)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalPackageJson(bytes)
+}
+
+// unmarshalPackageJson 解析包信息并统一顶层与metadata中的链接字段
+func unmarshalPackageJson(bytes []byte) (*models.PackageInformation, error) {
+	pkg, err := unmarshalJson[*models.PackageInformation](bytes)
+	if err != nil {
+		return nil, err
+	}
+	if pkg != nil {
+		pkg.Normalize()
+	}
+	return pkg, nil
+}
+
 func unmarshalJson[T any](bytes []byte) (T, error) {
 	var r T
 	err := json.Unmarshal(bytes, &r)
@@ -193,29 +523,179 @@ func unmarshalJson[T any](bytes []byte) (T, error) {
 	return r, nil
 }
 
+// apiRequest 描述一次请求，由doRequest统一加上代理、认证、超时和重试等设置后发送
+type apiRequest struct {
+	// 发起请求的操作名称，用于查找该操作的超时时间
+	operation string
+
+	// 请求方法，为空时使用GET
+	method string
+
+	// 请求地址
+	url string
+
+	// 对单个请求的额外设置，例如添加请求头
+	settings []requests.RequestSetting
+
+	// 请求体，为空时不发送请求体
+	body []byte
+
+	// 请求的是仓库以外的地址，不携带Token，避免把Token泄露给第三方
+	external bool
+
+	// 响应会被直接写入调用方，无法重放，因此不做任何重试
+	streaming bool
+
+	// 显式声明请求是幂等的，重复发送不会产生额外的副作用
+	// GET、HEAD等安全方法总是视为幂等；POST、DELETE等修改类请求（例如发布、撤回gem）
+	// 只有在调用方确认可以安全重放时才应该设置，否则超时重试可能导致重复发布
+	idempotent bool
+}
+
+// bearerTokenMiddleware 为请求加上API Token认证
+func bearerTokenMiddleware(token string) RequestMiddleware {
+	return func(request *http.Request) error {
+		request.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+}
+
+// withResponseMiddlewares 在handler处理响应之前依次执行响应中间件，任意一个返回错误时不再处理响应
+func withResponseMiddlewares[T any](handler requests.ResponseHandler[T], middlewares []ResponseMiddleware) requests.ResponseHandler[T] {
+	if len(middlewares) == 0 {
+		return handler
+	}
+	return func(resp *http.Response) (T, error) {
+		for _, middleware := range middlewares {
+			if err := middleware(resp); err != nil {
+				var zero T
+				return zero, err
+			}
+		}
+		return handler(resp)
+	}
+}
+
+// retriable 判断请求失败后是否可以重试，只有幂等的请求才会重试
+func (r *apiRequest) retriable() bool {
+	if r.streaming {
+		return false
+	}
+	switch r.method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return r.idempotent
+	}
+}
+
 // 内部使用统一的方法来请求
-func (x *RepositoryImpl) getBytes(ctx context.Context, targetUrl string) ([]byte, error) {
-	options := requests.NewOptions[any, []byte](targetUrl, requests.BytesResponseHandler())
+// operation是发起请求的操作名称，用于查找该操作的超时时间
+// settings用于对单个请求做额外设置，例如添加请求头
+func (x *RepositoryImpl) getBytes(ctx context.Context, operation, targetUrl string, settings ...requests.RequestSetting) ([]byte, error) {
+	request := &apiRequest{operation: operation, url: targetUrl, settings: settings}
+	return doRequest(ctx, x, request, bodyResponseHandler)
+}
+
+// bodyResponseHandler 读取200响应的内容，其它状态码通过responseStatusError转换为APIError，
+// 这样404返回的是ErrNotFound而不是错误页面的JSON解析错误，调用方可以用IsNotFound等函数判断。
+// 响应声明了Content-Length时按长度一次分配缓冲区，版本列表这样的大响应不需要在读取过程中反复扩容
+func bodyResponseHandler(resp *http.Response) ([]byte, error) {
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseStatusError(resp)
+	}
+	if resp.ContentLength <= 0 {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("response status code: %d, read body error: %s", resp.StatusCode, err.Error())
+		}
+		return body, nil
+	}
+	buffer := bytes.NewBuffer(make([]byte, 0, resp.ContentLength+bytes.MinRead))
+	if _, err := buffer.ReadFrom(resp.Body); err != nil {
+		return nil, fmt.Errorf("response status code: %d, read body error: %s", resp.StatusCode, err.Error())
+	}
+	return buffer.Bytes(), nil
+}
+
+// responseStatusError 把非200的响应转换为APIError，错误原因按状态码由statusCause决定
+func responseStatusError(resp *http.Response) error {
+	defer resp.Body.Close()
+	// 错误响应只保留开头的一部分，避免读取过大的响应体
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return NewAPIError(resp, body, statusCause(resp.StatusCode))
+}
+
+// doRequest 为请求加上代理、认证等通用设置后发送，响应由handler处理
+// 配置了超时时间时，整个操作（包括重试）都需要在超时时间内完成
+func doRequest[T any](ctx context.Context, x *RepositoryImpl, request *apiRequest, handler requests.ResponseHandler[T]) (T, error) {
+	if x.optionsErr != nil && !request.external {
+		var zero T
+		return zero, x.optionsErr
+	}
+
+	if timeout := x.options.TimeoutFor(request.operation); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	targetUrl := request.url
+	if x.options.URLRewriter != nil {
+		targetUrl = x.options.URLRewriter(targetUrl)
+	}
+
+	// 关闭go-requests内部的重试，重试统一由sendRequestWithRetry负责，
+	// 这样ShouldRetry、幂等性判断、退避和重试统计才能覆盖实际发送的每一次请求
+	options := requests.NewOptions[any, T](targetUrl, withResponseMiddlewares(handler, x.options.ResponseMiddlewares)).WithMaxTryTimes(1)
+	if request.method != "" {
+		options.WithMethod(request.method)
+	}
+	if len(request.body) > 0 {
+		options.WithBody(request.body)
+	}
+	for _, setting := range request.settings {
+		options.AppendRequestSetting(setting)
+	}
 
 	// 设置代理
 	if x.options.Proxy != "" {
 		options.AppendRequestSetting(requests.RequestSettingProxy(x.options.Proxy))
 	}
 
-	// 设置Token认证
-	if x.options.Token != "" {
-		// 使用匿名函数方式设置HTTP头
+	// 设置自定义的Transport，放在代理之后以免代理设置修改调用方的Transport
+	if x.options.Transport != nil {
 		options.AppendRequestSetting(func(client *http.Client, request *http.Request) error {
-			request.Header.Set("Authorization", "Bearer "+x.options.Token)
+			client.Transport = x.options.Transport
 			return nil
 		})
 	}
 
+	// 依次执行请求中间件，Token认证是第一个中间件，调用方注册的中间件可以覆盖它设置的请求头
+	// 请求仓库以外的地址时不执行，避免把Token或签名泄露给第三方
+	if !request.external {
+		middlewares := x.options.RequestMiddlewares
+		if x.options.Token != "" {
+			middlewares = append([]RequestMiddleware{bearerTokenMiddleware(x.options.Token)}, middlewares...)
+		}
+		for _, middleware := range middlewares {
+			middleware := middleware
+			options.AppendRequestSetting(func(client *http.Client, request *http.Request) error {
+				return middleware(request)
+			})
+		}
+	}
+
+	// 流式请求和非幂等请求只发送一次
+	if !request.retriable() {
+		return requests.SendRequest[any, T](ctx, options)
+	}
+
 	// 如果启用了重试，使用带重试的请求
 	if x.options.RetryOptions != nil {
-		return SendRequestWithRetry(ctx, options, x.options.RetryOptions)
+		return sendRequestWithRetry(ctx, options, x.options.RetryOptions, &x.retries, x.rand)
 	}
 
 	// 否则直接发送请求
-	return requests.SendRequest[any, []byte](ctx, options)
+	return requests.SendRequest[any, T](ctx, options)
 }
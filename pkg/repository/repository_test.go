@@ -2,11 +2,152 @@ package repository
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// newTestRepository 启动一个返回固定响应的测试服务器，并创建一个指向它且不重试的仓库
+// routes的键可以是带查询参数的完整请求URI，也可以只是路径；找不到对应响应时返回404
+func newTestRepository(t *testing.T, routes map[string]string) *RepositoryImpl {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := routes[r.URL.RequestURI()]
+		if !ok {
+			body, ok = routes[r.URL.Path]
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	return NewRepository(NewOptions().SetServerURL(server.URL).DisableRetry())
+}
+
+func TestNewRepository_ZeroValueOptions(t *testing.T) {
+	// 直接构造的Options{}使用默认仓库地址
+	options := &Options{}
+	repo := NewRepository(options)
+	assert.Equal(t, DefaultServerURL, repo.options.ServerURL)
+	assert.NoError(t, repo.optionsErr)
+	// 默认地址设置在副本上，调用方的Options保持不变
+	assert.Empty(t, options.ServerURL)
+
+	// 零值Options也能正常请求
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"name": "rails", "version": "7.1.0"}`))
+	}))
+	defer server.Close()
+	pkg, err := NewRepository(&Options{ServerURL: server.URL}).GetPackage(context.Background(), "rails")
+	assert.NoError(t, err)
+	if assert.NotNil(t, pkg) {
+		assert.Equal(t, "7.1.0", pkg.Version)
+	}
+
+	// 不是绝对地址时在发出请求之前就返回明确的错误
+	_, err = NewRepository(&Options{ServerURL: "rubygems.org"}).GetPackage(context.Background(), "rails")
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+	assert.Contains(t, err.Error(), "not an absolute url")
+}
+
+func TestRepository_GemExists(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		switch r.URL.Path {
+		case "/api/v1/gems/rails.json":
+			w.WriteHeader(http.StatusOK)
+		case "/api/v1/gems/broken.json":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	repo := NewRepository(NewOptions().SetServerURL(server.URL).DisableRetry())
+
+	exists, err := repo.GemExists(context.Background(), "rails")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = repo.GemExists(context.Background(), "no-such-gem")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	_, err = repo.GemExists(context.Background(), "broken")
+	var apiErr *APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+		assert.Equal(t, ErrServerError, apiErr.Cause)
+	}
+
+	// 只发送HEAD请求
+	for _, method := range methods {
+		assert.Equal(t, http.MethodHead, method)
+	}
+}
+
+func TestRepository_GemExists_HeadNotAllowed(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if r.URL.Path == "/api/v1/gems/rails.json" {
+			_, _ = w.Write([]byte(`{"name": "rails"}`))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+	repo := NewRepository(NewOptions().SetServerURL(server.URL).DisableRetry())
+
+	exists, err := repo.GemExists(context.Background(), "rails")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, []string{http.MethodHead, http.MethodGet}, methods)
+
+	exists, err = repo.GemExists(context.Background(), "no-such-gem")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestRepository_GetAllDependencies(t *testing.T) {
+	repo := newTestRepository(t, map[string]string{
+		"/api/v1/gems/sinatra.json": `{
+			"name": "sinatra",
+			"version": "3.1.0",
+			"dependencies": {
+				"development": [{"name": "rake", "requirements": ">= 0"}, {"name": "rspec", "requirements": "~> 3.0"}],
+				"runtime": [{"name": "rack", "requirements": "~> 2.2, >= 2.2.4"}]
+			}
+		}`,
+	})
+
+	runtime, development, err := repo.GetAllDependencies(context.Background(), "sinatra")
+	assert.NoError(t, err)
+	if assert.Len(t, runtime, 1) {
+		assert.Equal(t, "rack", runtime[0].Name)
+		assert.Equal(t, "~> 2.2, >= 2.2.4", runtime[0].Requirements)
+	}
+	if assert.Len(t, development, 2) {
+		assert.Equal(t, "rspec", development[1].Name)
+	}
+
+	_, _, err = repo.GetAllDependencies(context.Background(), "missing")
+	assert.Error(t, err)
+}
+
 func TestRepository_GetPackage(t *testing.T) {
 	// Skip in short mode
 	if testing.Short() {
@@ -21,6 +162,154 @@ func TestRepository_GetPackage(t *testing.T) {
 	}
 }
 
+func TestRepository_GetPackage_NormalizesURIs(t *testing.T) {
+	repo := newTestRepository(t, map[string]string{
+		"/api/v1/gems/demo.json": `{
+			"name": "demo",
+			"homepage_uri": "https://demo.example.com",
+			"source_code_uri": "https://github.com/example/demo",
+			"documentation_uri": "",
+			"changelog_uri": null,
+			"wiki_uri": null,
+			"metadata": {
+				"homepage_uri": "https://old.example.com",
+				"source_code_uri": "https://github.com/example/demo/tree/v1.0.0",
+				"documentation_uri": "https://docs.example.com/demo",
+				"changelog_uri": "https://github.com/example/demo/blob/main/CHANGELOG.md",
+				"wiki_uri": "https://github.com/example/demo/wiki"
+			}
+		}`,
+	})
+
+	pkg, err := repo.GetPackage(context.Background(), "demo")
+	assert.NoError(t, err)
+	if assert.NotNil(t, pkg) {
+		// 顶层非空时优先使用顶层的值
+		assert.Equal(t, "https://demo.example.com", pkg.HomepageURI)
+		assert.Equal(t, "https://github.com/example/demo", pkg.SourceCodeURI)
+		// 顶层为空时回退到metadata
+		assert.Equal(t, "https://docs.example.com/demo", pkg.DocumentationURI)
+		assert.Equal(t, "https://github.com/example/demo/blob/main/CHANGELOG.md", pkg.ChangelogURI)
+		assert.Equal(t, "https://github.com/example/demo/wiki", pkg.WikiURI)
+		// metadata本身保持不变
+		assert.Equal(t, "https://old.example.com", pkg.Metadata.HomepageURI)
+	}
+}
+
+func TestRepository_GetPackageWithRaw(t *testing.T) {
+	body := `{"name": "demo", "version": "1.0.0", "downloads": 42, "homepage_uri": "", "metadata": {"homepage_uri": "https://demo.example.com"}, "unknown_field": true}`
+	repo := newTestRepository(t, map[string]string{
+		"/api/v1/gems/demo.json":   body,
+		"/api/v1/gems/broken.json": `{"name": `,
+	})
+
+	pkg, raw, err := repo.GetPackageWithRaw(context.Background(), "demo")
+	assert.NoError(t, err)
+	assert.Equal(t, body, string(raw))
+	if assert.NotNil(t, pkg) {
+		assert.Equal(t, "demo", pkg.Name)
+		assert.Equal(t, "https://demo.example.com", pkg.HomepageURI)
+
+		// 原始内容解析后与返回的结构体一致
+		decoded, err := unmarshalPackageJson(raw)
+		assert.NoError(t, err)
+		assert.Equal(t, pkg, decoded)
+
+		// 与GetPackage的结果一致
+		expected, err := repo.GetPackage(context.Background(), "demo")
+		assert.NoError(t, err)
+		assert.Equal(t, expected, pkg)
+	}
+
+	// 解析失败时仍然返回原始内容
+	pkg, raw, err = repo.GetPackageWithRaw(context.Background(), "broken")
+	assert.Error(t, err)
+	assert.Nil(t, pkg)
+	assert.Equal(t, `{"name": `, string(raw))
+
+	// 请求失败时没有原始内容
+	_, raw, err = repo.GetPackageWithRaw(context.Background(), "missing")
+	assert.True(t, IsNotFound(err))
+	assert.Nil(t, raw)
+}
+
+func TestRepository_OperationTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 所有接口都很慢
+		time.Sleep(100 * time.Millisecond)
+		_, _ = w.Write([]byte(`["rails"]`))
+	}))
+	defer server.Close()
+
+	repo := NewRepository(NewOptions().
+		SetServerURL(server.URL).
+		DisableRetry().
+		SetTimeout(20*time.Millisecond).
+		SetOperationTimeout(OperationGetReverseDependencies, 5*time.Second))
+
+	// 反向依赖使用单独配置的较长超时，可以正常完成
+	dependents, err := repo.GetReverseDependencies(context.Background(), "activesupport")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"rails"}, dependents)
+
+	// 其它操作使用全局超时
+	_, err = repo.GetGemVersions(context.Background(), "activesupport")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestRepository_LatestGems_ActivityFeed(t *testing.T) {
+	repo := newTestRepository(t, map[string]string{
+		"/api/v1/activity/latest.json": `[{
+			"name": "sidekiq",
+			"version": "7.1.2",
+			"version_downloads": 128,
+			"spec_sha": "9a8b7c6d",
+			"homepage_uri": null,
+			"metadata": {"homepage_uri": "https://sidekiq.org"}
+		}]`,
+	})
+
+	items, err := repo.LatestActivity(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, items, 1) {
+		assert.Equal(t, "9a8b7c6d", items[0].SpecSha)
+	}
+
+	gems, err := repo.LatestGems(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, gems, 1) {
+		assert.Equal(t, "7.1.2", gems[0].Version)
+		assert.Equal(t, "https://sidekiq.org", gems[0].HomepageURI)
+	}
+}
+
+func TestRepository_VersionDownloadsForPlatform(t *testing.T) {
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.EscapedPath())
+		_, _ = w.Write([]byte(`{"total_downloads": 100, "version_downloads": 10}`))
+	}))
+	defer server.Close()
+
+	repo := NewRepository(NewOptions().SetServerURL(server.URL).DisableRetry())
+
+	downloads, err := repo.VersionDownloadsForPlatform(context.Background(), "nokogiri", "1.15.4", "x86_64-linux")
+	assert.NoError(t, err)
+	assert.NotNil(t, downloads)
+
+	_, err = repo.VersionDownloadsForPlatform(context.Background(), "nokogiri", "1.15.4", "ruby")
+	assert.NoError(t, err)
+
+	_, err = repo.VersionDownloadsForPlatform(context.Background(), "my gem", "1.0.0", "x64-mingw-ucrt")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"/api/v1/downloads/nokogiri-1.15.4-x86_64-linux.json",
+		"/api/v1/downloads/nokogiri-1.15.4.json",
+		"/api/v1/downloads/my%20gem-1.0.0-x64-mingw-ucrt.json",
+	}, requested)
+}
+
 func TestRepository_Search(t *testing.T) {
 	// Skip in short mode
 	if testing.Short() {
@@ -95,3 +384,45 @@ func TestRepository_VersionDownloads(t *testing.T) {
 		assert.True(t, downloads.TotalDownloads > 0)
 	}
 }
+
+func TestRepository_StatusErrors(t *testing.T) {
+	statuses := map[string]int{
+		"missing":   http.StatusNotFound,
+		"throttled": http.StatusTooManyRequests,
+		"private":   http.StatusForbidden,
+		"invalid":   http.StatusUnprocessableEntity,
+		"broken":    http.StatusInternalServerError,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimSuffix(path.Base(r.URL.Path), ".json")
+		w.WriteHeader(statuses[name])
+		_, _ = w.Write([]byte("This rubygem could not be found."))
+	}))
+	defer server.Close()
+	repo := NewRepository(NewOptions().SetServerURL(server.URL).DisableRetry())
+
+	expected := map[string]error{
+		"missing":   ErrNotFound,
+		"throttled": ErrRateLimited,
+		"private":   ErrUnauthorized,
+		"invalid":   ErrInvalidRequest,
+		"broken":    ErrServerError,
+	}
+	for name, cause := range expected {
+		_, err := repo.GetPackage(context.Background(), name)
+		var apiErr *APIError
+		if assert.ErrorAs(t, err, &apiErr, name) {
+			assert.Equal(t, statuses[name], apiErr.StatusCode, name)
+			assert.Equal(t, cause, apiErr.Cause, name)
+			assert.Equal(t, "This rubygem could not be found.", apiErr.Response, name)
+		}
+	}
+
+	// 404返回ErrNotFound，而不是错误页面的JSON解析错误
+	_, err := repo.GetGemVersions(context.Background(), "missing")
+	assert.True(t, IsNotFound(err))
+	_, err = repo.GetPackage(context.Background(), "throttled")
+	assert.True(t, IsRateLimited(err))
+	_, err = repo.GetPackage(context.Background(), "private")
+	assert.True(t, IsUnauthorized(err))
+}
@@ -0,0 +1,63 @@
+package repository
+
+import "fmt"
+
+// Result 把单次调用返回的 (T, error) 包装成一个值，方便函数式风格的调用方串联处理，例如:
+//
+//	version := repository.Map(repository.Wrap(repo.GetPackage(ctx, "rails")), func(pkg *models.PackageInformation) string {
+//		return pkg.Version
+//	}).OrElse("unknown")
+//
+// 批量操作的结果请使用BulkResult
+type Result[T any] struct {
+	Value T     // 调用成功时的结果值
+	Err   error // 调用过程中发生的错误，为nil表示成功
+}
+
+// Wrap 把 (v, err) 包装成Result，可以直接传入多返回值的调用: Wrap(repo.GetPackage(ctx, name))
+func Wrap[T any](v T, err error) Result[T] {
+	return Result[T]{Value: v, Err: err}
+}
+
+// Ok 判断结果是否成功
+func (r Result[T]) Ok() bool {
+	return r.Err == nil
+}
+
+// Get 拆开结果，返回原始的 (T, error)
+func (r Result[T]) Get() (T, error) {
+	return r.Value, r.Err
+}
+
+// OrElse 成功时返回结果值，失败时返回fallback
+func (r Result[T]) OrElse(fallback T) T {
+	if r.Err != nil {
+		return fallback
+	}
+	return r.Value
+}
+
+// Must 成功时返回结果值，失败时panic，适合在测试或确定不会失败的场景中使用
+func (r Result[T]) Must() T {
+	if r.Err != nil {
+		panic(fmt.Sprintf("repository: Must called on failed result: %v", r.Err))
+	}
+	return r.Value
+}
+
+// Map 成功时用f转换结果值，失败时原样传递错误且不调用f
+// Go的方法不能有自己的类型参数，所以Map是函数而不是方法
+func Map[T, U any](r Result[T], f func(T) U) Result[U] {
+	if r.Err != nil {
+		return Result[U]{Err: r.Err}
+	}
+	return Result[U]{Value: f(r.Value)}
+}
+
+// Then 成功时用可能失败的f继续处理结果值，失败时原样传递错误且不调用f
+func Then[T, U any](r Result[T], f func(T) (U, error)) Result[U] {
+	if r.Err != nil {
+		return Result[U]{Err: r.Err}
+	}
+	return Wrap(f(r.Value))
+}
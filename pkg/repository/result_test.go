@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResult(t *testing.T) {
+	boom := errors.New("boom")
+
+	ok := Wrap(41, nil)
+	failed := Wrap(0, boom)
+	assert.True(t, ok.Ok())
+	assert.False(t, failed.Ok())
+
+	value, err := ok.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, 41, value)
+	_, err = failed.Get()
+	assert.Equal(t, boom, err)
+
+	// OrElse
+	assert.Equal(t, 41, ok.OrElse(-1))
+	assert.Equal(t, -1, failed.OrElse(-1))
+
+	// Must
+	assert.Equal(t, 41, ok.Must())
+	assert.Panics(t, func() { failed.Must() })
+
+	// Map只在成功时调用
+	called := false
+	increment := func(n int) string {
+		called = true
+		return strconv.Itoa(n + 1)
+	}
+	assert.Equal(t, "42", Map(ok, increment).Must())
+	called = false
+	mapped := Map(failed, increment)
+	assert.False(t, called)
+	assert.Equal(t, boom, mapped.Err)
+
+	// Then可以在链中引入新的错误
+	parsed := Then(Wrap("7.1.2", nil), func(s string) (int, error) { return strconv.Atoi(s) })
+	assert.Error(t, parsed.Err)
+	assert.Equal(t, 0, parsed.OrElse(0))
+	assert.Equal(t, 12, Then(Wrap("12", nil), strconv.Atoi).Must())
+	assert.Equal(t, boom, Then(Wrap("12", boom), strconv.Atoi).Err)
+}
+
+func TestResult_WrapRepositoryCall(t *testing.T) {
+	mockRepo := newMockRepository()
+	mockRepo.delay = 0
+	ctx := context.Background()
+
+	version := func(pkg *models.PackageInformation) string { return pkg.Version }
+	assert.Equal(t, "7.0.5", Map(Wrap(mockRepo.GetPackage(ctx, "rails")), version).OrElse("unknown"))
+	assert.Equal(t, "unknown", Map(Wrap(mockRepo.GetPackage(ctx, "missing")), version).OrElse("unknown"))
+}
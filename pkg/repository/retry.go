@@ -3,7 +3,10 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/crawler-go-go-go/go-requests"
@@ -34,6 +37,14 @@ type RetryOptions struct {
 	// 是否使用指数退避算法
 	UseExponentialBackoff bool
 
+	// 是否使用随机抖动，开启后每次实际等待[退避时间/2, 退避时间]之间的随机时长，
+	// 避免批量操作中大量goroutine在同一时刻重试。UseFullJitter同时开启时以UseFullJitter为准
+	UseJitter bool
+
+	// 是否使用完全随机抖动（full jitter），开启后每次实际等待[0, 退避时间]之间的随机时长，
+	// 避免大量客户端在同一时刻重试
+	UseFullJitter bool
+
 	// 自定义重试条件
 	ShouldRetry func(resp *http.Response, err error) bool
 }
@@ -45,6 +56,7 @@ func NewDefaultRetryOptions() *RetryOptions {
 		WaitTime:              DefaultRetryWaitTime,
 		MaxWaitTime:           DefaultRetryMaxWaitTime,
 		UseExponentialBackoff: true,
+		UseJitter:             true,
 		ShouldRetry: func(resp *http.Response, err error) bool {
 			// 如果有错误，总是重试
 			if err != nil {
@@ -92,17 +104,126 @@ func (o *RetryOptions) WithExponentialBackoff(use bool) *RetryOptions {
 	return o
 }
 
+// WithJitter 设置是否在退避时间上使用随机抖动，默认开启
+func (o *RetryOptions) WithJitter(use bool) *RetryOptions {
+	o.UseJitter = use
+	return o
+}
+
+// WithFullJitter 设置是否在退避时间上使用完全随机抖动
+func (o *RetryOptions) WithFullJitter(use bool) *RetryOptions {
+	o.UseFullJitter = use
+	return o
+}
+
+// backoff 计算第attempt次重试（从1开始）前需要等待的时间，开启随机抖动时从rnd中取随机数
+func (o *RetryOptions) backoff(attempt int, rnd *lockedRand) time.Duration {
+	waitTime := o.WaitTime
+
+	// 如果使用指数退避，则指数增加等待时间
+	if o.UseExponentialBackoff {
+		factor := 1 << uint(attempt-1)
+		waitTime = time.Duration(float64(waitTime) * float64(factor))
+		if waitTime > o.MaxWaitTime {
+			waitTime = o.MaxWaitTime
+		}
+	}
+
+	if waitTime <= 0 || (!o.UseFullJitter && !o.UseJitter) {
+		return waitTime
+	}
+	if rnd == nil {
+		rnd = defaultRand
+	}
+	if o.UseFullJitter {
+		return time.Duration(rnd.Int63n(int64(waitTime) + 1))
+	}
+	low := waitTime / 2
+	return low + time.Duration(rnd.Int63n(int64(waitTime-low)+1))
+}
+
 // WithShouldRetry 设置自定义重试条件
 func (o *RetryOptions) WithShouldRetry(shouldRetry func(resp *http.Response, err error) bool) *RetryOptions {
 	o.ShouldRetry = shouldRetry
 	return o
 }
 
+// retryable 判断失败的请求是否需要重试
+// 响应状态码转换成的*APIError按状态码交给ShouldRetry判断，默认只重试429和5xx，404这样确定的结果不会被重试；
+// 其它错误（例如网络错误）不带响应交给ShouldRetry。没有设置ShouldRetry时总是重试
+func (o *RetryOptions) retryable(err error) bool {
+	if o.ShouldRetry == nil {
+		return true
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode > 0 {
+		return o.ShouldRetry(&http.Response{StatusCode: apiErr.StatusCode}, nil)
+	}
+	return o.ShouldRetry(nil, err)
+}
+
+// RetryStats 是仓库累计的重试统计，用于观察瞬时故障的频率以便调整退避参数
+type RetryStats struct {
+	// 累计重试次数，不包括每个请求的第一次尝试
+	Retries int64
+
+	// 最近一次重试的原因，即触发重试的那次失败的错误信息
+	LastReason string
+
+	// 最近一次重试发生的时间，没有重试过时为零值
+	LastRetryAt time.Time
+}
+
+// retryRecorder 记录重试统计，可以被多个goroutine同时更新
+type retryRecorder struct {
+	retries int64 // 使用atomic读写
+
+	mu          sync.Mutex
+	lastReason  string
+	lastRetryAt time.Time
+}
+
+// record 记录一次因为err而发生的重试
+func (r *retryRecorder) record(err error) {
+	atomic.AddInt64(&r.retries, 1)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.lastReason = err.Error()
+	}
+	r.lastRetryAt = time.Now()
+}
+
+// snapshot 返回当前统计的副本
+func (r *retryRecorder) snapshot() RetryStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return RetryStats{
+		Retries:     atomic.LoadInt64(&r.retries),
+		LastReason:  r.lastReason,
+		LastRetryAt: r.lastRetryAt,
+	}
+}
+
 // SendRequestWithRetry 发送带重试功能的请求
+// 每次尝试只发送一次HTTP请求，options.MaxTryTimes会被忽略，重试次数完全由retryOptions决定
 func SendRequestWithRetry[Request any, Response any](
 	ctx context.Context,
 	options *requests.Options[Request, Response],
 	retryOptions *RetryOptions,
+) (Response, error) {
+	return sendRequestWithRetry(ctx, options, retryOptions, nil, defaultRand)
+}
+
+// sendRequestWithRetry 是SendRequestWithRetry的实现，recorder不为nil时记录每一次重试
+// rnd是计算随机抖动使用的随机数来源
+func sendRequestWithRetry[Request any, Response any](
+	ctx context.Context,
+	options *requests.Options[Request, Response],
+	retryOptions *RetryOptions,
+	recorder *retryRecorder,
+	rnd *lockedRand,
 ) (Response, error) {
 	var lastErr error
 	var lastResp Response
@@ -112,19 +233,15 @@ func SendRequestWithRetry[Request any, Response any](
 		retryOptions = NewDefaultRetryOptions()
 	}
 
+	// 每次尝试只发送一次请求，避免go-requests内部的重试绕过退避、ShouldRetry和重试统计
+	single := *options
+	single.MaxTryTimes = 1
+	options = &single
+
 	for attempt := 0; attempt < retryOptions.MaxAttempts; attempt++ {
 		// 如果不是第一次尝试，等待一段时间
 		if attempt > 0 {
-			waitTime := retryOptions.WaitTime
-
-			// 如果使用指数退避，则指数增加等待时间
-			if retryOptions.UseExponentialBackoff {
-				factor := 1 << uint(attempt-1)
-				waitTime = time.Duration(float64(waitTime) * float64(factor))
-				if waitTime > retryOptions.MaxWaitTime {
-					waitTime = retryOptions.MaxWaitTime
-				}
-			}
+			waitTime := retryOptions.backoff(attempt, rnd)
 
 			// 等待一段时间后重试
 			select {
@@ -135,33 +252,32 @@ func SendRequestWithRetry[Request any, Response any](
 				var zero Response
 				return zero, ctx.Err()
 			}
+
+			if recorder != nil {
+				recorder.record(lastErr)
+			}
 		}
 
 		// 执行请求
 		resp, err := requests.SendRequest[Request, Response](ctx, options)
-
-		// 检查是否需要重试
-		shouldRetry := false
-		if err != nil {
-			lastErr = err
-			shouldRetry = true
-		} else {
+		if err == nil {
 			// 请求成功，返回结果
 			return resp, nil
 		}
 
-		// 如果不需要重试，直接返回结果
-		if !shouldRetry {
-			return resp, nil
-		}
-
-		// 记录最后一次响应
+		// 记录最后一次的响应和错误
+		lastErr = err
 		lastResp = resp
+
+		// 不需要重试的错误（例如404）直接返回
+		if !retryOptions.retryable(err) {
+			return resp, err
+		}
 	}
 
-	// 达到最大重试次数，返回最后一次的错误
+	// 达到最大重试次数，返回最后一次的错误，保留错误链以便调用方用errors.Is/errors.As判断
 	if lastErr != nil {
-		return lastResp, errors.New("max retry attempts reached: " + lastErr.Error())
+		return lastResp, fmt.Errorf("max retry attempts reached: %w", lastErr)
 	}
 
 	return lastResp, nil
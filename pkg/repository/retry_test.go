@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -277,3 +280,309 @@ func sendWithMock(ctx context.Context, mock *mockRequestSender, retryOptions *Re
 	// 达到最大重试次数
 	return lastResp, errors.New("max retry attempts reached: " + lastErr.Error())
 }
+
+// 测试仓库的重试统计
+func TestRepository_RetryStats(t *testing.T) {
+	// 第一次请求失败，由外层重试
+	var requestCount int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&requestCount, 1) <= 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"name": "rails", "version": "7.1.2"}`))
+	}))
+	defer server.Close()
+
+	retryOptions := NewDefaultRetryOptions().
+		WithWaitTime(time.Millisecond).
+		WithExponentialBackoff(false)
+	repo := NewRepository(NewOptions().SetServerURL(server.URL).SetRetryOptions(retryOptions))
+
+	stats := repo.RetryStats()
+	assert.Equal(t, int64(0), stats.Retries)
+	assert.True(t, stats.LastRetryAt.IsZero())
+
+	pkg, err := repo.GetPackage(context.Background(), "rails")
+	assert.NoError(t, err)
+	if assert.NotNil(t, pkg) {
+		assert.Equal(t, "7.1.2", pkg.Version)
+	}
+
+	stats = repo.RetryStats()
+	assert.Equal(t, int64(1), stats.Retries)
+	assert.Contains(t, stats.LastReason, "503")
+	assert.False(t, stats.LastRetryAt.IsZero())
+
+	// 成功的请求不会增加重试次数
+	_, err = repo.GetPackage(context.Background(), "rails")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), repo.RetryStats().Retries)
+}
+
+// 测试重试统计与服务端实际收到的请求数一致：每次尝试只发送一次请求
+func TestRepository_RetryStatsMatchRequests(t *testing.T) {
+	var requestCount int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requestCount, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	retryOptions := NewDefaultRetryOptions().WithWaitTime(time.Millisecond)
+	repo := NewRepository(NewOptions().SetServerURL(server.URL).SetRetryOptions(retryOptions))
+
+	_, err := repo.GetPackage(context.Background(), "rails")
+	assert.Error(t, err)
+	assert.Equal(t, int64(retryOptions.MaxAttempts), atomic.LoadInt64(&requestCount))
+	assert.Equal(t, repo.RetryStats().Retries+1, atomic.LoadInt64(&requestCount))
+}
+
+// 测试状态码错误的重试：404不重试，5xx重试后仍然保留APIError
+func TestRepository_RetryStatusErrors(t *testing.T) {
+	var requestCount int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requestCount, 1)
+		if r.URL.Path == "/api/v1/gems/broken.json" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	retryOptions := NewDefaultRetryOptions().
+		WithMaxAttempts(2).
+		WithWaitTime(time.Millisecond).
+		WithExponentialBackoff(false)
+	repo := NewRepository(NewOptions().SetServerURL(server.URL).SetRetryOptions(retryOptions))
+
+	_, err := repo.GetPackage(context.Background(), "missing")
+	assert.True(t, IsNotFound(err))
+	assert.Equal(t, int64(0), repo.RetryStats().Retries)
+
+	_, err = repo.GetPackage(context.Background(), "broken")
+	assert.Contains(t, err.Error(), "max retry attempts reached")
+	var apiErr *APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+		assert.Equal(t, ErrServerError, apiErr.Cause)
+	}
+	assert.True(t, IsTransient(err))
+	assert.Equal(t, int64(1), repo.RetryStats().Retries)
+}
+
+// 测试404只发送一次请求：go-requests内部的重试被关闭，404也不会被外层重试
+func TestRepository_NotFoundSingleRequest(t *testing.T) {
+	var requestCount int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requestCount, 1)
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	for name, options := range map[string]*Options{
+		"default retry": NewOptions(),
+		"disable retry": NewOptions().DisableRetry(),
+	} {
+		atomic.StoreInt64(&requestCount, 0)
+		repo := NewRepository(options.SetServerURL(server.URL))
+
+		_, err := repo.GetPackage(context.Background(), "missing")
+		assert.True(t, IsNotFound(err), name)
+		assert.Equal(t, int64(1), atomic.LoadInt64(&requestCount), name)
+		assert.Equal(t, int64(0), repo.RetryStats().Retries, name)
+	}
+}
+
+// 测试同一个仓库被大量goroutine同时使用，配合 go test -race 检查数据竞争
+func TestRepository_ConcurrentUse(t *testing.T) {
+	// 每隔几个请求失败一次，让重试统计在并发请求中不断更新
+	var requestCount int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&requestCount, 1)%4 == 0 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{"name": "rails", "version": "7.1.2"}`))
+	}))
+	defer server.Close()
+
+	// 每次尝试只发送一次请求，多给几次重试机会，避免并发请求连续遇到失败
+	retryOptions := NewDefaultRetryOptions().
+		WithMaxAttempts(10).
+		WithWaitTime(time.Millisecond).
+		WithExponentialBackoff(false)
+	repo := NewRepository(NewOptions().SetServerURL(server.URL).SetRetryOptions(retryOptions))
+
+	const goroutines = 200
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := repo.GetPackage(context.Background(), "rails"); err != nil {
+				errs <- err
+			}
+			// 请求的同时读取统计
+			_ = repo.RetryStats()
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				_ = repo.RetryStats()
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(done)
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.GreaterOrEqual(t, atomic.LoadInt64(&requestCount), int64(goroutines))
+}
+
+// 测试只有幂等的请求才会重试
+func TestDoRequest_IdempotentRetry(t *testing.T) {
+	var requestCount int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requestCount, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	retryOptions := NewDefaultRetryOptions().
+		WithMaxAttempts(2).
+		WithWaitTime(time.Millisecond).
+		WithExponentialBackoff(false)
+	repo := NewRepository(NewOptions().SetServerURL(server.URL).SetRetryOptions(retryOptions))
+
+	send := func(request *apiRequest) int64 {
+		atomic.StoreInt64(&requestCount, 0)
+		_, err := doRequest(context.Background(), repo, request, requests.BytesResponseHandler())
+		assert.Error(t, err)
+		return atomic.LoadInt64(&requestCount)
+	}
+
+	// POST默认只发送一次
+	assert.Equal(t, int64(1), send(&apiRequest{operation: "Push", method: http.MethodPost, url: server.URL + "/api/v1/gems"}))
+
+	// 显式声明幂等后按重试选项重试
+	assert.Equal(t, int64(2), send(&apiRequest{operation: "Push", method: http.MethodPost, url: server.URL + "/api/v1/gems", idempotent: true}))
+
+	// GET默认重试
+	assert.Equal(t, int64(2), send(&apiRequest{operation: OperationGetPackage, url: server.URL + "/api/v1/gems/rails.json"}))
+}
+
+// 测试ShouldRetry拒绝重试时只发送一次请求
+func TestRepository_ShouldRetryRejected(t *testing.T) {
+	var requestCount int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requestCount, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	retryOptions := NewDefaultRetryOptions().
+		WithWaitTime(time.Millisecond).
+		WithShouldRetry(func(resp *http.Response, err error) bool {
+			return false
+		})
+	repo := NewRepository(NewOptions().SetServerURL(server.URL).SetRetryOptions(retryOptions))
+
+	_, err := repo.GetPackage(context.Background(), "rails")
+	var apiErr *APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	}
+	assert.Equal(t, int64(1), atomic.LoadInt64(&requestCount))
+	assert.Equal(t, int64(0), repo.RetryStats().Retries)
+}
+
+// 测试服务端观察到的请求间隔：每次请求之前都有带抖动的等待，不会出现连续发送的请求
+func TestRepository_JitteredRequestSpacing(t *testing.T) {
+	var mu sync.Mutex
+	var arrivals []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		arrivals = append(arrivals, time.Now())
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	const waitTime = 40 * time.Millisecond
+	retryOptions := NewDefaultRetryOptions().
+		WithMaxAttempts(4).
+		WithWaitTime(waitTime).
+		WithExponentialBackoff(false)
+	assert.True(t, retryOptions.UseJitter)
+	repo := NewRepository(NewOptions().SetServerURL(server.URL).SetRetryOptions(retryOptions))
+
+	_, err := repo.GetPackage(context.Background(), "rails")
+	assert.Error(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if assert.Len(t, arrivals, 4) {
+		for i := 1; i < len(arrivals); i++ {
+			gap := arrivals[i].Sub(arrivals[i-1])
+			assert.GreaterOrEqual(t, gap, waitTime/2, "第%d次请求与上一次请求的间隔", i+1)
+		}
+	}
+}
+
+func TestRetryOptions_FullJitterSeed(t *testing.T) {
+	retryOptions := NewDefaultRetryOptions().WithFullJitter(true)
+	assert.True(t, retryOptions.UseFullJitter)
+
+	// 相同种子的仓库产生相同的等待时间序列
+	waits := func(seed int64) []time.Duration {
+		repo := NewRepository(NewOptions().SetRandSeed(seed).SetRetryOptions(retryOptions))
+		sequence := make([]time.Duration, 0, 8)
+		for attempt := 1; attempt <= 8; attempt++ {
+			wait := retryOptions.backoff(attempt, repo.rand)
+			assert.GreaterOrEqual(t, wait, time.Duration(0))
+			assert.LessOrEqual(t, wait, retryOptions.MaxWaitTime)
+			sequence = append(sequence, wait)
+		}
+		return sequence
+	}
+	assert.Equal(t, waits(42), waits(42))
+	assert.NotEqual(t, waits(42), waits(7))
+
+	// 不使用随机抖动时等待时间是确定的
+	retryOptions.WithFullJitter(false).WithJitter(false)
+	assert.Equal(t, 2*time.Second, retryOptions.backoff(2, nil))
+}
+
+func TestRetryOptions_Jitter(t *testing.T) {
+	retryOptions := NewDefaultRetryOptions()
+	assert.True(t, retryOptions.UseJitter)
+	assert.False(t, retryOptions.UseFullJitter)
+
+	// 等待时间在[退避时间/2, 退避时间]之间，并且不是每次都相同
+	rnd := newLockedRand(nil)
+	for attempt, target := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 10: DefaultRetryMaxWaitTime} {
+		seen := make(map[time.Duration]bool)
+		for i := 0; i < 50; i++ {
+			wait := retryOptions.backoff(attempt, rnd)
+			assert.GreaterOrEqual(t, wait, target/2)
+			assert.LessOrEqual(t, wait, target)
+			seen[wait] = true
+		}
+		assert.Greater(t, len(seen), 1, "第%d次重试的等待时间应该是随机的", attempt)
+	}
+
+	// 关闭后恢复确定的等待时间
+	assert.Equal(t, 4*time.Second, retryOptions.WithJitter(false).backoff(3, rnd))
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+)
+
+// DependentRef 是反向索引中的一条记录：Name包的Version版本依赖某个gem
+type DependentRef struct {
+	// 依赖方的包名和建立索引时的版本
+	Name    string
+	Version string
+
+	// 依赖方声明的版本要求，例如 "~> 2.2, >= 2.2.4"
+	Requirements string
+
+	// 依赖类型，运行时依赖或开发依赖
+	Type models.DependencyType
+}
+
+// ReverseIndex 是一组gem的依赖关系倒排得到的反向依赖索引，建立之后的查询不需要再请求API
+// 索引只覆盖建立时给出的gem，适合在固定的gem集合上反复回答"谁依赖X"的问题；
+// 需要整个仓库中的反向依赖时请使用GetReverseDependencies
+type ReverseIndex struct {
+	dependents map[string][]DependentRef
+
+	// Failed 获取失败而没有进入索引的gem及对应的错误，只有BulkOptions.ContinueOnError为true时才会有内容
+	Failed map[string]error
+}
+
+// BuildReverseIndex 批量获取gemNames中每个包最新版本的运行时依赖和开发依赖，并倒排为反向依赖索引
+// options.ContinueOnError为true（默认）时跳过获取失败的包并记录在ReverseIndex.Failed中，
+// 否则返回遇到的第一个错误；ctx被取消时返回ctx的错误
+func (x *RepositoryImpl) BuildReverseIndex(ctx context.Context, gemNames []string, options *BulkOptions) (*ReverseIndex, error) {
+	return buildReverseIndex(ctx, gemNames, options, x.GetPackage)
+}
+
+func buildReverseIndex(ctx context.Context, gemNames []string, options *BulkOptions, getPackage func(context.Context, string) (*models.PackageInformation, error)) (*ReverseIndex, error) {
+	if options == nil {
+		options = NewBulkOptions()
+	}
+
+	index := &ReverseIndex{
+		dependents: make(map[string][]DependentRef),
+		Failed:     make(map[string]error),
+	}
+	for _, result := range bulkExecute(ctx, gemNames, options, getPackage) {
+		if result == nil {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if result.Error != nil {
+			if !options.ContinueOnError || ctx.Err() != nil {
+				return nil, fmt.Errorf("%s: %w", result.Key, result.Error)
+			}
+			index.Failed[result.Key] = result.Error
+			continue
+		}
+		if result.Value != nil {
+			index.add(result.Key, result.Value)
+		}
+	}
+
+	for _, refs := range index.dependents {
+		sort.Slice(refs, func(i, j int) bool {
+			if refs[i].Name != refs[j].Name {
+				return refs[i].Name < refs[j].Name
+			}
+			return refs[i].Type.IsRuntime() && !refs[j].Type.IsRuntime()
+		})
+	}
+	return index, nil
+}
+
+func (r *ReverseIndex) add(gemName string, pkg *models.PackageInformation) {
+	dependencies := []struct {
+		list []*models.Dependency
+		kind models.DependencyType
+	}{
+		{pkg.Dependencies.Runtime, models.DependencyTypeRuntime},
+		{pkg.Dependencies.Development, models.DependencyTypeDevelopment},
+	}
+	for _, group := range dependencies {
+		for _, dependency := range group.list {
+			if dependency == nil || dependency.Name == "" {
+				continue
+			}
+			r.dependents[dependency.Name] = append(r.dependents[dependency.Name], DependentRef{
+				Name:         gemName,
+				Version:      pkg.Version,
+				Requirements: dependency.Requirements,
+				Type:         group.kind,
+			})
+		}
+	}
+}
+
+// Dependents 返回索引中依赖gemName的包，按包名排序，同名时运行时依赖在前，没有依赖方时返回空切片
+// 同一个包同时以运行时依赖和开发依赖声明时会出现两次
+func (r *ReverseIndex) Dependents(gemName string) []DependentRef {
+	return append([]DependentRef{}, r.dependents[gemName]...)
+}
+
+// DependentsForVersion 返回版本要求接受gemName的version版本的依赖方，用于回答"谁依赖X的Y版本"
+// 版本要求无法解析的依赖方会被跳过
+func (r *ReverseIndex) DependentsForVersion(gemName, version string) []DependentRef {
+	matched := make([]DependentRef, 0)
+	for _, ref := range r.dependents[gemName] {
+		requirement, err := models.ParseRequirement(ref.Requirements)
+		if err == nil && requirement.Satisfies(version) {
+			matched = append(matched, ref)
+		}
+	}
+	return matched
+}
+
+// Gems 返回索引中至少有一个依赖方的gem，按字典序排序
+func (r *ReverseIndex) Gems() []string {
+	gems := make([]string, 0, len(r.dependents))
+	for gem := range r.dependents {
+		gems = append(gems, gem)
+	}
+	sort.Strings(gems)
+	return gems
+}
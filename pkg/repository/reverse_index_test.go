@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func newReverseIndexMock() *mockRepository {
+	mockRepo := newMockRepository()
+	mockRepo.delay = 0
+	mockRepo.mockPackages = map[string]*models.PackageInformation{
+		"rails": {Name: "rails", Version: "7.1.2", Dependencies: models.Dependencies{
+			Runtime: []*models.Dependency{
+				{Name: "actionpack", Requirements: "= 7.1.2"},
+				{Name: "rack", Requirements: ">= 2.2.4"},
+			},
+		}},
+		"actionpack": {Name: "actionpack", Version: "7.1.2", Dependencies: models.Dependencies{
+			Runtime: []*models.Dependency{
+				{Name: "rack", Requirements: ">= 2.2.4"},
+				{Name: "rack-test", Requirements: ">= 0.6.3"},
+			},
+			Development: []*models.Dependency{
+				{Name: "rack", Requirements: "~> 3.0"},
+			},
+		}},
+		"sinatra": {Name: "sinatra", Version: "3.2.0", Dependencies: models.Dependencies{
+			Runtime: []*models.Dependency{
+				{Name: "rack", Requirements: "~> 2.2, >= 2.2.4"},
+				nil,
+			},
+		}},
+		"rack": {Name: "rack", Version: "3.0.8"},
+	}
+	return mockRepo
+}
+
+func TestBuildReverseIndex(t *testing.T) {
+	mockRepo := newReverseIndexMock()
+
+	index, err := buildReverseIndex(context.Background(), []string{"rails", "actionpack", "sinatra", "rack"}, nil, mockRepo.GetPackage)
+	assert.NoError(t, err)
+	assert.Empty(t, index.Failed)
+	assert.Equal(t, []string{"actionpack", "rack", "rack-test"}, index.Gems())
+
+	assert.Equal(t, []DependentRef{
+		{Name: "actionpack", Version: "7.1.2", Requirements: ">= 2.2.4", Type: models.DependencyTypeRuntime},
+		{Name: "actionpack", Version: "7.1.2", Requirements: "~> 3.0", Type: models.DependencyTypeDevelopment},
+		{Name: "rails", Version: "7.1.2", Requirements: ">= 2.2.4", Type: models.DependencyTypeRuntime},
+		{Name: "sinatra", Version: "3.2.0", Requirements: "~> 2.2, >= 2.2.4", Type: models.DependencyTypeRuntime},
+	}, index.Dependents("rack"))
+	assert.Equal(t, []DependentRef{
+		{Name: "rails", Version: "7.1.2", Requirements: "= 7.1.2", Type: models.DependencyTypeRuntime},
+	}, index.Dependents("actionpack"))
+	assert.Empty(t, index.Dependents("sinatra"))
+
+	// 只有sinatra和开发依赖不接受rack 3
+	names := func(refs []DependentRef) []string {
+		result := make([]string, 0, len(refs))
+		for _, ref := range refs {
+			result = append(result, ref.Name+":"+string(ref.Type))
+		}
+		return result
+	}
+	assert.Equal(t, []string{"actionpack:runtime", "actionpack:development", "rails:runtime"}, names(index.DependentsForVersion("rack", "3.0.8")))
+	assert.Equal(t, []string{"actionpack:runtime", "rails:runtime", "sinatra:runtime"}, names(index.DependentsForVersion("rack", "2.2.8")))
+	assert.Empty(t, index.DependentsForVersion("rack", "1.6.0"))
+}
+
+func TestBuildReverseIndex_Failures(t *testing.T) {
+	mockRepo := newReverseIndexMock().setFailOn("sinatra", ErrServerError)
+	gemNames := []string{"rails", "sinatra", "missing"}
+
+	index, err := buildReverseIndex(context.Background(), gemNames, nil, mockRepo.GetPackage)
+	assert.NoError(t, err)
+	assert.Len(t, index.Failed, 2)
+	assert.ErrorIs(t, index.Failed["sinatra"], ErrServerError)
+	assert.Len(t, index.Dependents("rack"), 1)
+
+	_, err = buildReverseIndex(context.Background(), gemNames, NewBulkOptions().WithContinueOnError(false).WithMaxConcurrency(1), mockRepo.GetPackage)
+	assert.ErrorIs(t, err, ErrServerError)
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+)
+
+// SearchOptions 定义搜索查询的处理选项
+type SearchOptions struct {
+	// Normalize 发送请求和构造缓存键之前规范化查询字符串：去掉首尾空白，并把连续的空白合并为一个空格
+	// 这样 "  rails " 和 "rails" 会共享同一个缓存项
+	Normalize bool
+
+	// Lowercase 规范化时同时把查询转换为小写，只在Normalize为true时生效
+	// RubyGems的搜索不区分大小写，开启后可以进一步提高缓存命中率
+	Lowercase bool
+}
+
+// NewSearchOptions 创建具有默认值的搜索选项
+// 默认配置：不做任何规范化，查询原样发送
+func NewSearchOptions() *SearchOptions {
+	return &SearchOptions{}
+}
+
+// WithNormalize 设置是否规范化查询字符串
+// 返回选项对象自身，支持链式调用
+func (o *SearchOptions) WithNormalize(normalize bool) *SearchOptions {
+	o.Normalize = normalize
+	return o
+}
+
+// WithLowercase 设置规范化时是否转换为小写
+// 返回选项对象自身，支持链式调用
+func (o *SearchOptions) WithLowercase(lowercase bool) *SearchOptions {
+	o.Lowercase = lowercase
+	return o
+}
+
+// NormalizeQuery 按选项规范化查询字符串，选项为nil或者没有开启规范化时原样返回
+func (o *SearchOptions) NormalizeQuery(query string) string {
+	if o == nil || !o.Normalize {
+		return query
+	}
+	query = strings.Join(strings.Fields(query), " ")
+	if o.Lowercase {
+		query = strings.ToLower(query)
+	}
+	return query
+}
+
+// SearchIterator 按需逐页获取搜索结果，调用方不需要自己处理翻页
+//
+//	it := repo.SearchIterator(ctx, "rack")
+//	for it.Next() {
+//		fmt.Println(it.Package().Name)
+//	}
+//	if err := it.Err(); err != nil {
+//		// 处理错误
+//	}
+type SearchIterator struct {
+	ctx       context.Context
+	fetchPage func(page int) ([]*models.PackageInformation, error)
+
+	page    int
+	pending []*models.PackageInformation
+	current *models.PackageInformation
+	done    bool
+	err     error
+}
+
+// SearchIterator 创建遍历query全部搜索结果的迭代器，第一次调用Next时才会发出请求
+func (x *RepositoryImpl) SearchIterator(ctx context.Context, query string) *SearchIterator {
+	return &SearchIterator{
+		ctx: ctx,
+		fetchPage: func(page int) ([]*models.PackageInformation, error) {
+			return x.Search(ctx, query, page)
+		},
+	}
+}
+
+// Next 移动到下一个搜索结果，当前页用完时请求下一页
+// 某一页为空、请求失败或者ctx被取消时返回false，之后可以通过Err区分是正常结束还是出错
+func (it *SearchIterator) Next() bool {
+	it.current = nil
+	if it.done {
+		return false
+	}
+	for len(it.pending) == 0 {
+		if err := it.ctx.Err(); err != nil {
+			return it.stop(err)
+		}
+		it.page++
+		items, err := it.fetchPage(it.page)
+		if err != nil {
+			return it.stop(err)
+		}
+		if len(items) == 0 {
+			return it.stop(nil)
+		}
+		it.pending = items
+	}
+	if err := it.ctx.Err(); err != nil {
+		return it.stop(err)
+	}
+	it.current, it.pending = it.pending[0], it.pending[1:]
+	return true
+}
+
+// Package 返回Next移动到的搜索结果，Next返回false之后为nil
+func (it *SearchIterator) Package() *models.PackageInformation {
+	return it.current
+}
+
+// Err 返回导致迭代停止的错误，正常遍历完全部结果时为nil
+func (it *SearchIterator) Err() error {
+	return it.err
+}
+
+func (it *SearchIterator) stop(err error) bool {
+	it.done = true
+	it.err = err
+	it.pending = nil
+	return false
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchOptions_NormalizeQuery(t *testing.T) {
+	var nilOptions *SearchOptions
+	assert.Equal(t, "  Rails ", nilOptions.NormalizeQuery("  Rails "))
+	assert.Equal(t, "  Rails ", NewSearchOptions().NormalizeQuery("  Rails "))
+
+	normalize := NewSearchOptions().WithNormalize(true)
+	assert.Equal(t, "Active Record", normalize.NormalizeQuery(" Active \t  Record\n"))
+
+	lowercase := NewSearchOptions().WithNormalize(true).WithLowercase(true)
+	assert.Equal(t, "active record", lowercase.NormalizeQuery(" Active \t  Record\n"))
+
+	// Lowercase只在Normalize开启时生效
+	assert.Equal(t, " Rails", NewSearchOptions().WithLowercase(true).NormalizeQuery(" Rails"))
+}
+
+func TestRepository_SearchIterator(t *testing.T) {
+	repo := newTestRepository(t, map[string]string{
+		"/api/v1/search.json?query=rack&page=1": `[{"name": "rack"}, {"name": "rack-test"}]`,
+		"/api/v1/search.json?query=rack&page=2": `[{"name": "rack-cors"}]`,
+		"/api/v1/search.json?query=rack&page=3": `[]`,
+	})
+
+	var names []string
+	it := repo.SearchIterator(context.Background(), "rack")
+	for it.Next() {
+		names = append(names, it.Package().Name)
+	}
+	assert.NoError(t, it.Err())
+	assert.Equal(t, []string{"rack", "rack-test", "rack-cors"}, names)
+	assert.Nil(t, it.Package())
+	assert.False(t, it.Next())
+
+	// 搜索接口返回404时停止并返回错误
+	it = repo.SearchIterator(context.Background(), "missing")
+	assert.False(t, it.Next())
+	assert.Error(t, it.Err())
+
+	// 遍历中途取消ctx，剩下的结果不再返回
+	ctx, cancel := context.WithCancel(context.Background())
+	it = repo.SearchIterator(ctx, "rack")
+	assert.True(t, it.Next())
+	cancel()
+	assert.False(t, it.Next())
+	assert.ErrorIs(t, it.Err(), context.Canceled)
+}
+
+// 测试搜索词和包名在URL中被正确转义
+func TestRepository_EscapesURL(t *testing.T) {
+	repo := newTestRepository(t, map[string]string{
+		"/api/v1/search.json?query=foo+bar&page=1":   `[{"name": "foo-bar"}]`,
+		"/api/v1/search.json?query=a%26b%3Dc&page=1": `[{"name": "ab"}]`,
+		"/api/v1/gems/foo%2Fbar.json":                `{"name": "foo/bar"}`,
+		"/api/v1/versions/foo%20bar.json":            `[{"number": "1.0.0"}]`,
+	})
+	ctx := context.Background()
+
+	packages, err := repo.Search(ctx, "foo bar", 1)
+	assert.NoError(t, err)
+	if assert.Len(t, packages, 1) {
+		assert.Equal(t, "foo-bar", packages[0].Name)
+	}
+
+	// &和=不会被当作新的查询参数
+	packages, err = repo.Search(ctx, "a&b=c", 1)
+	assert.NoError(t, err)
+	assert.Len(t, packages, 1)
+
+	pkg, err := repo.GetPackage(ctx, "foo/bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo/bar", pkg.Name)
+
+	versions, err := repo.GetGemVersions(ctx, "foo bar")
+	assert.NoError(t, err)
+	assert.Len(t, versions, 1)
+}
+
+func TestRepository_SearchAll(t *testing.T) {
+	repo := newTestRepository(t, map[string]string{
+		"/api/v1/search.json?query=rack&page=1": `[{"name": "rack"}, {"name": "rack-test"}]`,
+		// 翻页期间有新的包发布时，同一个包可能出现在相邻的两页
+		"/api/v1/search.json?query=rack&page=2": `[{"name": "rack-test"}, {"name": "rack-cors"}]`,
+		"/api/v1/search.json?query=rack&page=3": `[{"name": "rack-attack"}]`,
+		"/api/v1/search.json?query=rack&page=4": `[]`,
+	})
+
+	results, err := repo.SearchAll(context.Background(), "rack", 0)
+	assert.NoError(t, err)
+	names := make([]string, 0, len(results))
+	for _, pkg := range results {
+		names = append(names, pkg.Name)
+	}
+	assert.Equal(t, []string{"rack", "rack-test", "rack-cors", "rack-attack"}, names)
+
+	results, err = repo.SearchAll(context.Background(), "rack", 3)
+	assert.NoError(t, err)
+	if assert.Len(t, results, 3) {
+		assert.Equal(t, "rack-cors", results[2].Name)
+	}
+
+	// 请求失败时返回已经获取的结果
+	repo = newTestRepository(t, map[string]string{
+		"/api/v1/search.json?query=rack&page=1": `[{"name": "rack"}]`,
+	})
+	results, err = repo.SearchAll(context.Background(), "rack", 0)
+	assert.Error(t, err)
+	assert.Len(t, results, 1)
+}
+
+func TestRepository_SearchAll_MaxResultsLimitsRequests(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Query().Get("page"))
+		mu.Unlock()
+		page := r.URL.Query().Get("page")
+		_, _ = w.Write([]byte(`[{"name": "gem-` + page + `-a"}, {"name": "gem-` + page + `-b"}]`))
+	}))
+	defer server.Close()
+	repo := NewRepository(NewOptions().SetServerURL(server.URL).DisableRetry())
+
+	// 每页2个结果，5个结果只需要请求3页
+	results, err := repo.SearchAll(context.Background(), "gem", 5)
+	assert.NoError(t, err)
+	assert.Len(t, results, 5)
+	sort.Strings(requested)
+	assert.Equal(t, []string{"1", "2", "3"}, requested)
+}
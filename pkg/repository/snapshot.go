@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+)
+
+// CaptureGemSnapshot 采集gem当前的完整状态：包信息、版本列表和反向依赖
+// 返回的快照可以序列化为JSON保存，之后通过GemSnapshot.Diff与新的快照比较，用于审计gem的变化
+func (x *RepositoryImpl) CaptureGemSnapshot(ctx context.Context, gemName string) (*models.GemSnapshot, error) {
+	pkg, err := x.GetPackage(ctx, gemName)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := x.GetGemVersions(ctx, gemName)
+	if err != nil {
+		return nil, err
+	}
+	reverseDependencies, err := x.GetReverseDependencies(ctx, gemName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.GemSnapshot{
+		Name:                gemName,
+		CapturedAt:          time.Now().UTC(),
+		Package:             pkg,
+		Versions:            versions,
+		ReverseDependencies: reverseDependencies,
+	}, nil
+}
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaptureGemSnapshot(t *testing.T) {
+	repo := newFixtureTestRepository().(*RepositoryImpl)
+
+	snapshot, err := repo.CaptureGemSnapshot(context.Background(), "rack")
+	assert.NoError(t, err)
+	if assert.NotNil(t, snapshot) {
+		assert.Equal(t, "rack", snapshot.Name)
+		assert.False(t, snapshot.CapturedAt.IsZero())
+		assert.Equal(t, "3.0.8", snapshot.Package.Version)
+		assert.Len(t, snapshot.Versions, 3)
+		assert.Contains(t, snapshot.ReverseDependencies, "puma")
+	}
+
+	// 缺少反向依赖的fixture时返回错误
+	_, err = repo.CaptureGemSnapshot(context.Background(), "rails")
+	assert.Error(t, err)
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+)
+
+// MaxTimeFrameSpan 是timeframe_versions接口允许的最大时间跨度，from和to需要相差小于这个值
+const MaxTimeFrameSpan = 7 * 24 * time.Hour
+
+// TimeFrame 表示一个时间段
+type TimeFrame struct {
+	From time.Time
+	To   time.Time
+}
+
+// SplitTimeFrame 把[from, to]拆分为多个跨度小于maxSpan的相邻时间段，相邻的时间段共享边界
+// 接口的时间参数精确到秒，每段的跨度比maxSpan少一秒；maxSpan不大于一秒或者时间段本身足够短时只返回一段
+func SplitTimeFrame(from, to time.Time, maxSpan time.Duration) []TimeFrame {
+	step := maxSpan - time.Second
+	if step <= 0 || to.Sub(from) <= step {
+		return []TimeFrame{{From: from, To: to}}
+	}
+	var frames []TimeFrame
+	for start := from; start.Before(to); start = start.Add(step) {
+		end := start.Add(step)
+		if end.After(to) {
+			end = to
+		}
+		frames = append(frames, TimeFrame{From: start, To: end})
+	}
+	return frames
+}
+
+// getTimeFrameVersionsSplit 按maxSpan拆分时间段后依次请求，合并结果
+// 相邻时间段共享边界，恰好在边界上发布的版本会被返回两次，这里按sha去重
+func (x *RepositoryImpl) getTimeFrameVersionsSplit(ctx context.Context, from, to time.Time, maxSpan time.Duration) ([]*models.Version, error) {
+	frames := SplitTimeFrame(from, to, maxSpan)
+	if len(frames) == 1 {
+		return x.getTimeFrameVersions(ctx, from, to)
+	}
+
+	var versions []*models.Version
+	seen := make(map[string]bool)
+	for _, frame := range frames {
+		chunk, err := x.getTimeFrameVersions(ctx, frame.From, frame.To)
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range chunk {
+			if version == nil {
+				continue
+			}
+			key := version.Sha
+			if key == "" {
+				key = fmt.Sprintf("%s-%s@%s", version.Number, version.Platform, version.CreatedAt.Format(time.RFC3339Nano))
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
+}
+
+// getTimeFrameVersions 请求一个时间段内的版本，不做拆分
+func (x *RepositoryImpl) getTimeFrameVersions(ctx context.Context, from, to time.Time) ([]*models.Version, error) {
+	// 格式化时间为RFC3339格式
+	fromStr := from.Format(time.RFC3339)
+	toStr := to.Format(time.RFC3339)
+	request := &apiRequest{
+		operation: OperationGetTimeFrameVersions,
+		url:       fmt.Sprintf("%s/api/v1/timeframe_versions.json?from=%s&to=%s", x.options.ServerURL, url.QueryEscape(fromStr), url.QueryEscape(toStr)),
+	}
+	bytes, err := doRequest(ctx, x, request, timeFrameResponseHandler)
+	if err != nil {
+		return nil, err
+	}
+	return models.UnmarshalVersions(bytes)
+}
+
+// timeFrameSpanPattern 匹配服务端拒绝过大时间跨度时的提示，例如 "the from and to params must be less than 7 days apart"
+var timeFrameSpanPattern = regexp.MustCompile(`(?i)less than (\d+) days? apart`)
+
+// timeFrameResponseHandler 在bodyResponseHandler的基础上识别时间跨度过大的错误
+func timeFrameResponseHandler(resp *http.Response) ([]byte, error) {
+	if resp.StatusCode != http.StatusBadRequest && resp.StatusCode != http.StatusUnprocessableEntity {
+		return bodyResponseHandler(resp)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	apiErr := NewAPIError(resp, body, ErrInvalidRequest)
+	match := timeFrameSpanPattern.FindSubmatch(body)
+	if match == nil {
+		return nil, apiErr
+	}
+	days, _ := strconv.Atoi(string(match[1]))
+	return nil, &TimeframeTooLargeError{MaxSpan: time.Duration(days) * 24 * time.Hour, Err: apiErr}
+}
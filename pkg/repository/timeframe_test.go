@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitTimeFrame(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	frames := SplitTimeFrame(from, from.Add(24*time.Hour), MaxTimeFrameSpan)
+	assert.Equal(t, []TimeFrame{{From: from, To: from.Add(24 * time.Hour)}}, frames)
+
+	frames = SplitTimeFrame(from, from.Add(20*24*time.Hour), MaxTimeFrameSpan)
+	if assert.Len(t, frames, 3) {
+		assert.Equal(t, from, frames[0].From)
+		assert.Equal(t, frames[0].To, frames[1].From)
+		assert.Equal(t, from.Add(20*24*time.Hour), frames[2].To)
+		for _, frame := range frames {
+			assert.Less(t, frame.To.Sub(frame.From), MaxTimeFrameSpan)
+		}
+	}
+}
+
+// newTimeFrameServer 模拟只接受跨度小于maxDays天的timeframe_versions接口，每天返回一个版本
+func newTimeFrameServer(t *testing.T, maxDays int, requests *int64) *RepositoryImpl {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(requests, 1)
+		from, _ := time.Parse(time.RFC3339, r.URL.Query().Get("from"))
+		to, _ := time.Parse(time.RFC3339, r.URL.Query().Get("to"))
+		if to.Sub(from) >= time.Duration(maxDays)*24*time.Hour {
+			http.Error(w, fmt.Sprintf("the from and to params must be less than %d days apart", maxDays), http.StatusBadRequest)
+			return
+		}
+		body := "["
+		for day := from.Truncate(24 * time.Hour); !day.After(to); day = day.Add(24 * time.Hour) {
+			if day.Before(from) {
+				continue
+			}
+			if body != "[" {
+				body += ","
+			}
+			body += fmt.Sprintf(`{"number": "0.0.%d", "sha": "sha-%d", "created_at": %q}`, day.YearDay(), day.YearDay(), day.Format(time.RFC3339))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body + "]"))
+	}))
+	t.Cleanup(server.Close)
+	return NewRepository(NewOptions().SetServerURL(server.URL).DisableRetry())
+}
+
+func TestRepository_GetTimeFrameVersions_Split(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(20 * 24 * time.Hour)
+
+	// 超过7天的时间段自动拆分，边界上的版本只返回一次
+	var requests int64
+	versions, err := newTimeFrameServer(t, 7, &requests).GetTimeFrameVersions(context.Background(), from, to)
+	assert.NoError(t, err)
+	assert.Len(t, versions, 21)
+	assert.Equal(t, int64(3), atomic.LoadInt64(&requests))
+
+	// 服务端的限制更严格时按返回的最大跨度重新拆分
+	requests = 0
+	versions, err = newTimeFrameServer(t, 3, &requests).GetTimeFrameVersions(context.Background(), from, to)
+	assert.NoError(t, err)
+	assert.Len(t, versions, 21)
+}
+
+func TestRepository_GetTimeFrameVersions_TooLarge(t *testing.T) {
+	var requests int64
+	repo := newTimeFrameServer(t, 0, &requests)
+
+	_, err := repo.GetTimeFrameVersions(context.Background(), time.Now().Add(-time.Hour), time.Now())
+	assert.True(t, errors.Is(err, ErrTimeframeTooLarge))
+	var tooLarge *TimeframeTooLargeError
+	if assert.ErrorAs(t, err, &tooLarge) {
+		assert.Equal(t, time.Duration(0), tooLarge.MaxSpan)
+		assert.Equal(t, http.StatusBadRequest, tooLarge.Err.StatusCode)
+	}
+
+	// 其它4xx错误不会被当作时间跨度过大
+	repo = newTestRepository(t, map[string]string{})
+	_, err = repo.GetTimeFrameVersions(context.Background(), time.Now().Add(-time.Hour), time.Now())
+	assert.False(t, errors.Is(err, ErrTimeframeTooLarge))
+	assert.True(t, IsNotFound(err))
+}
@@ -0,0 +1,362 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scagogogo/rubygems-crawler/pkg/models"
+)
+
+// MostDownloadedVersion 返回下载量最高的版本，可以用来识别被最广泛使用的"标准"版本
+// 下载量相同时保留列表中靠前的版本，列表为空时返回nil
+func MostDownloadedVersion(versions []*models.Version) *models.Version {
+	var most *models.Version
+	for _, version := range versions {
+		if version == nil {
+			continue
+		}
+		if most == nil || version.DownloadsCount > most.DownloadsCount {
+			most = version
+		}
+	}
+	return most
+}
+
+// GetMostDownloadedVersion 获取指定gem包历史上下载量最高的版本
+// 与PackageInformation.VersionDownloads不同，它比较的是所有版本而不仅是当前版本
+func (x *RepositoryImpl) GetMostDownloadedVersion(ctx context.Context, gemName string) (*models.Version, error) {
+	versions, err := x.GetGemVersions(ctx, gemName)
+	if err != nil {
+		return nil, err
+	}
+	most := MostDownloadedVersion(versions)
+	if most == nil {
+		return nil, ErrNotFound
+	}
+	return most, nil
+}
+
+// GetRecentVersions 获取gem包最近发布的n个版本，按发布时间从新到旧排列，版本不足n个时返回全部版本
+// 版本列表接口不支持分页或者限制数量，这里获取完整的版本列表后在本地排序并截断，
+// 所以只能减少调用方的处理，不能减少请求的数据量。缺少发布时间的版本排在最后，n小于等于0时返回全部版本
+func (x *RepositoryImpl) GetRecentVersions(ctx context.Context, gemName string, n int) ([]*models.Version, error) {
+	versions, err := x.GetGemVersions(ctx, gemName)
+	if err != nil {
+		return nil, err
+	}
+
+	recent := make([]*models.Version, 0, len(versions))
+	for _, version := range versions {
+		if version != nil {
+			recent = append(recent, version)
+		}
+	}
+	sort.SliceStable(recent, func(i, j int) bool {
+		return recent[i].CreatedAt.After(recent[j].CreatedAt)
+	})
+	if n > 0 && len(recent) > n {
+		recent = recent[:n]
+	}
+	return recent, nil
+}
+
+// FirstReleaseDate 获取gem包第一次发布的时间以及对应的版本，用于统计gem的"年龄"
+// 比较的是版本的CreatedAt而不是版本号，因为维护分支上的旧版本号可能比新版本号发布得更晚。
+// 缺少发布时间的版本（null、缺失或者无法解析）会被跳过，所有版本都没有发布时间时返回ErrNotFound。
+// 与GetGemVersions不同，个别版本的时间无法解析时不会导致整个版本列表失败
+func (x *RepositoryImpl) FirstReleaseDate(ctx context.Context, gemName string) (time.Time, *models.Version, error) {
+	bytes, err := x.getGemVersionsBytes(ctx, gemName)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	versions, err := models.UnmarshalVersions(bytes)
+	if err != nil {
+		// 整个列表解析失败时逐个版本解析，跳过解析不了的版本
+		versions, err = unmarshalVersionsSkippingInvalid(bytes)
+		if err != nil {
+			return time.Time{}, nil, err
+		}
+	}
+
+	var first *models.Version
+	for _, version := range versions {
+		if version == nil || version.CreatedAt.IsZero() {
+			continue
+		}
+		if first == nil || version.CreatedAt.Before(first.CreatedAt) {
+			first = version
+		}
+	}
+	if first == nil {
+		return time.Time{}, nil, fmt.Errorf("%w: no version of %s has a release date", ErrNotFound, gemName)
+	}
+	return first.CreatedAt, first, nil
+}
+
+// unmarshalVersionsSkippingInvalid 逐个解析版本列表中的版本，跳过解析失败的版本（例如时间格式无法识别）
+// 列表本身不是数组，或者没有一个版本能解析时返回第一个错误
+func unmarshalVersionsSkippingInvalid(data []byte) ([]*models.Version, error) {
+	elements, err := unmarshalJson[[]json.RawMessage](data)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]*models.Version, 0, len(elements))
+	var firstErr error
+	for _, element := range elements {
+		version := new(models.Version)
+		if err := json.Unmarshal(element, version); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		versions = append(versions, version)
+	}
+	if len(versions) == 0 && firstErr != nil {
+		return nil, firstErr
+	}
+	return versions, nil
+}
+
+// GetLatestStableVersion 获取gem包最新的正式版本，跳过所有预发布版本
+// GetGemLatestVersion返回的是RubyGems认为的最新版本，可能是预发布版本，锁定生产环境依赖时应该使用这个方法。
+// 版本按CompareVersions比较而不是按发布时间，gem只有预发布版本时返回ErrNotFound
+func (x *RepositoryImpl) GetLatestStableVersion(ctx context.Context, gemName string) (*models.Version, error) {
+	versions, err := x.GetGemVersions(ctx, gemName)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *models.Version
+	for _, version := range versions {
+		if version == nil || version.Prerelease || models.IsPrereleaseVersion(version.Number) {
+			continue
+		}
+		if latest == nil || models.CompareVersions(version.Number, latest.Number) > 0 {
+			latest = version
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("%w: %s has no stable version", ErrNotFound, gemName)
+	}
+	return latest, nil
+}
+
+// GetGemAuthors 获取发布过gem任意一个版本的所有作者，可以用来发现维护者随时间的变化
+// 每个版本的authors字段按逗号拆分，去掉首尾空白后去重，
+// 结果按在版本列表中第一次出现的顺序排列（版本列表从新到旧，所以当前的作者排在前面）
+func (x *RepositoryImpl) GetGemAuthors(ctx context.Context, gemName string) ([]string, error) {
+	versions, err := x.GetGemVersions(ctx, gemName)
+	if err != nil {
+		return nil, err
+	}
+
+	authors := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, version := range versions {
+		if version == nil {
+			continue
+		}
+		for _, author := range strings.Split(version.Authors, ",") {
+			author = strings.TrimSpace(author)
+			if author == "" || seen[author] {
+				continue
+			}
+			seen[author] = true
+			authors = append(authors, author)
+		}
+	}
+	return authors, nil
+}
+
+// GetDuplicateDependencies 获取包最新版本的信息，返回在运行时依赖和开发依赖中重复声明的依赖包名，
+// 用于帮助gem作者发现gemspec中的错误，没有重复时返回空切片。参见models.PackageInformation.DuplicateDependencies
+func (x *RepositoryImpl) GetDuplicateDependencies(ctx context.Context, gemName string) ([]string, error) {
+	pkg, err := x.GetPackage(ctx, gemName)
+	if err != nil {
+		return nil, err
+	}
+	return pkg.DuplicateDependencies(), nil
+}
+
+// FindVersionMatching 返回满足版本约束的最新版本，约束使用RubyGems的写法，例如 "~> 7.0" 或 ">= 1.2, < 2.0"
+// 版本列表接口不返回已撤回的版本，所以结果不会是已撤回的版本；与RubyGems一致，
+// 只有约束显式引用预发布版本时才会考虑预发布版本。
+// 约束无法解析时返回ErrInvalidRequest，没有满足约束的版本时返回ErrNotFound
+func (x *RepositoryImpl) FindVersionMatching(ctx context.Context, gemName, constraint string) (*models.Version, error) {
+	return findVersionMatching(ctx, gemName, constraint, x.GetGemVersions)
+}
+
+// findVersionMatching 是各个Repository实现共用的FindVersionMatching，版本列表通过getGemVersions获取
+func findVersionMatching(ctx context.Context, gemName, constraint string, getGemVersions func(context.Context, string) ([]*models.Version, error)) (*models.Version, error) {
+	parsed, err := models.ParseRequirement(constraint)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s requirement %q: %v", ErrInvalidRequest, gemName, constraint, err)
+	}
+
+	versions, err := getGemVersions(ctx, gemName)
+	if err != nil {
+		return nil, err
+	}
+	newest := newestSatisfying(versions, parsed, nil)
+	if newest == nil {
+		return nil, fmt.Errorf("%w: no version of %s satisfies %q", ErrNotFound, gemName, constraint)
+	}
+	return newest, nil
+}
+
+// ResolveLatestSatisfying 返回满足版本要求的最新版本，供依赖解析使用
+// repos按优先级排列，获取版本列表失败时依次尝试下一个仓库，所有仓库都失败时返回*MultiError。
+// 版本要求的处理与FindVersionMatching相同
+func ResolveLatestSatisfying(ctx context.Context, gemName, requirement string, repos ...Repository) (*models.Version, error) {
+	return NewFailoverRepository(repos...).FindVersionMatching(ctx, gemName, requirement)
+}
+
+// newestSatisfying 从版本列表中选出满足版本要求的最高版本
+// 与RubyGems一致，只有版本要求显式引用预发布版本时才会考虑预发布版本
+// accept可以进一步过滤候选版本，为nil时不过滤
+// 没有满足条件的版本时返回nil
+func newestSatisfying(versions []*models.Version, requirement *models.Requirement, accept func(*models.Version) bool) *models.Version {
+	allowPrerelease := requirement.IsPrerelease()
+
+	var newest *models.Version
+	for _, version := range versions {
+		if version == nil {
+			continue
+		}
+		if !allowPrerelease && (version.Prerelease || models.IsPrereleaseVersion(version.Number)) {
+			continue
+		}
+		if !requirement.Satisfies(version.Number) {
+			continue
+		}
+		if accept != nil && !accept(version) {
+			continue
+		}
+		if newest == nil || models.CompareVersions(version.Number, newest.Number) > 0 {
+			newest = version
+		}
+	}
+	return newest
+}
+
+// supportsRuby 判断某个版本声明的Ruby版本要求是否接受目标Ruby版本
+// 目标版本为空、版本没有声明要求或者要求无法解析时都视为兼容
+func supportsRuby(version *models.Version, targetRubyVersion string) bool {
+	if targetRubyVersion == "" || strings.TrimSpace(version.RubyVersion) == "" {
+		return true
+	}
+	requirement, err := models.ParseRequirement(version.RubyVersion)
+	if err != nil {
+		return true
+	}
+	return requirement.Satisfies(targetRubyVersion)
+}
+
+// MinimumRubyVersion 并发获取每个gem最新正式版本的详情，返回这组gem实际要求的最低Ruby版本，即各个gem最低要求中最高的一个，
+// 以及每个gem声明的原始ruby_version要求（没有声明时为空字符串）。
+// 要求中的 ">=", ">", "~>", "=" 约束给出下限，"<" 和 "!=" 不影响下限；没有声明、无法解析或者没有下限的要求会被跳过，
+// 所有gem都没有下限时返回空字符串。获取失败的gem不出现在返回的map中，它们的错误汇总为*MultiError返回，
+// 同时仍然返回根据其它gem计算出的结果
+func (x *RepositoryImpl) MinimumRubyVersion(ctx context.Context, gemNames []string, options *BulkOptions) (string, map[string]string, error) {
+	results := bulkExecute(ctx, gemNames, options, x.GetLatestStableVersion)
+
+	floor := ""
+	requirements := make(map[string]string, len(results))
+	var errs []error
+	for i, result := range results {
+		if result == nil {
+			// 没有开启ContinueOnError时，遇到错误后剩余的gem不会被处理
+			errs = append(errs, fmt.Errorf("%s: not processed", gemNames[i]))
+			continue
+		}
+		if result.Error != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", result.Key, result.Error))
+			continue
+		}
+
+		requirement := strings.TrimSpace(result.Value.RubyVersion)
+		requirements[result.Key] = requirement
+		if minimum := requirementFloor(requirement); minimum != "" && (floor == "" || models.CompareVersions(minimum, floor) > 0) {
+			floor = minimum
+		}
+	}
+
+	if len(errs) > 0 {
+		return floor, requirements, &MultiError{Errors: errs}
+	}
+	return floor, requirements, nil
+}
+
+// requirementFloor 返回版本要求允许的最低版本，即所有给出下限的约束中最高的版本
+// 要求为空、无法解析或者没有给出下限时返回空字符串
+func requirementFloor(requirement string) string {
+	if requirement == "" {
+		return ""
+	}
+	parsed, err := models.ParseRequirement(requirement)
+	if err != nil {
+		return ""
+	}
+
+	floor := ""
+	for _, constraint := range parsed.Constraints {
+		switch constraint.Operator {
+		case models.OperatorGreaterOrEqual, models.OperatorGreater, models.OperatorPessimistic, models.OperatorEqual:
+			if floor == "" || models.CompareVersions(constraint.Version, floor) > 0 {
+				floor = constraint.Version
+			}
+		}
+	}
+	return floor
+}
+
+// SourceURLChanged 比较gem两个版本声明的源码仓库地址，用于发现可疑的仓库迁移（例如包被他人接管）
+// 地址取自各个版本的source_code_uri（顶层为空时使用metadata中的值），没有声明时使用homepage_uri。
+// 比较时忽略协议、大小写、结尾的 "/" 和 ".git"，以及 /tree/、/blob/ 等指向具体分支或标签的部分，
+// 所以 https://github.com/rails/rails/tree/v7.0.8 与 https://github.com/rails/rails/tree/v7.1.2 视为同一个仓库。
+// 返回的oldURL和newURL是两个版本声明的原始地址
+func (x *RepositoryImpl) SourceURLChanged(ctx context.Context, gemName, fromVersion, toVersion string) (changed bool, oldURL, newURL string, err error) {
+	from, err := x.GetPackageAtVersion(ctx, gemName, fromVersion)
+	if err != nil {
+		return false, "", "", err
+	}
+	to, err := x.GetPackageAtVersion(ctx, gemName, toVersion)
+	if err != nil {
+		return false, "", "", err
+	}
+
+	oldURL, newURL = sourceURL(from), sourceURL(to)
+	return sourceRepository(oldURL) != sourceRepository(newURL), oldURL, newURL, nil
+}
+
+// sourceURL 返回包声明的源码地址，没有时使用主页地址
+func sourceURL(pkg *models.PackageInformation) string {
+	if pkg == nil {
+		return ""
+	}
+	if strings.TrimSpace(pkg.SourceCodeURI) != "" {
+		return strings.TrimSpace(pkg.SourceCodeURI)
+	}
+	return strings.TrimSpace(pkg.HomepageURI)
+}
+
+// sourceRepository 把源码地址规范化为仓库标识，例如 "github.com/rails/rails"
+func sourceRepository(uri string) string {
+	repository := strings.ToLower(strings.TrimSpace(uri))
+	for _, scheme := range []string{"https://", "http://", "git://"} {
+		repository = strings.TrimPrefix(repository, scheme)
+	}
+	repository = strings.TrimPrefix(repository, "www.")
+	for _, marker := range []string{"/tree/", "/blob/", "/-/", "/src/", "/releases/"} {
+		if i := strings.Index(repository, marker); i >= 0 {
+			repository = repository[:i]
+		}
+	}
+	repository = strings.TrimSuffix(repository, "/")
+	return strings.TrimSuffix(repository, ".git")
+}